@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/logging"
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+	"github.com/mechmind/life-goes-on/server"
+	"github.com/mechmind/life-goes-on/tui"
+)
+
+var listen = flag.String("listen", "", "start server on given address")
+var connect = flag.String("connect", "", "connect to server on giving address")
+var logfile = flag.String("log", "lgo.log", "log to that file")
+var logLevel = flag.String("log-level", "info", "log level: debug, info, warn or error")
+var logJSON = flag.Bool("log-json", false, "write log records as json")
+var logMaxSize = flag.Int64("log-max-size", 0, "rotate log when it grows over that many MB, 0 to never")
+var logKeep = flag.Int("log-keep", 5, "number of rotated log files to keep")
+
+var mainLog = logging.NewLogger("main")
+var standalone = flag.Bool("standalone", false, "run server as standalone")
+var dumpRules = flag.Bool("dump-rules", false, "dump available rules and exit")
+var ruleFile = flag.String("rule-file", "", "file with rules")
+var ruleSet = &stringSet{}
+
+var debugAddr = flag.String("debug-addr", "127.0.0.1:8081", "Address to bind http debug screen to")
+var debug = flag.Bool("debug", false, "Enable debug")
+
+var playerName = flag.String("name", os.Getenv("USER"), "player name")
+var profilesFile = flag.String("profiles", "lgo-profiles.json", "file to store player profiles in")
+var dumpLeaderboard = flag.Bool("leaderboard", false, "dump leaderboard and exit")
+
+var serverName = flag.String("server-name", "lgo", "server name shown to connecting players")
+var banList = &stringSet{}
+
+var roomName = flag.String("room", "", "room to join on server, default room if empty")
+var roomRule = flag.String("room-rule", "", "create room with that rule if it does not exist")
+var adminSocket = flag.String("admin-socket", "", "serve admin console on that unix socket")
+var listRooms = flag.Bool("list-rooms", false, "list rooms on server given by -connect and exit")
+var frameRate = flag.Int("fps", 30, "frames per second of local view, 0 to redraw only on game updates")
+var tickWorkerCount = flag.Int("tick-workers", 0, "goroutines deciding for units every tick, 0 for one per cpu")
+var profileTickCount = flag.Int("profile-ticks", 0, "run that many ticks of first rule as fast as possible, report time per phase and exit")
+
+func init() {
+	flag.Var(ruleSet, "rule", "game rule(s) to use")
+	flag.Var(banList, "ban", "player name or host address to ban")
+}
+
+func readRuleFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	var rules []string
+	for scanner.Scan() {
+		line := strings.Trim(scanner.Text(), " ")
+		pos := strings.Index(line, "#")
+		if pos > 0 {
+			line = line[:pos]
+		}
+
+		if line != "" {
+			rules = append(rules, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+var defaultServerAddr string
+
+func main() {
+	flag.Parse()
+	if defaultServerAddr != "" && *connect == "" {
+		*connect = defaultServerAddr
+	}
+
+	// set up logging
+	level, err := logging.ParseLogLevel(*logLevel)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	f, err := logging.OpenLogFile(*logfile, *logMaxSize<<20, *logKeep)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	logging.SetupLogging(f, level, *logJSON)
+
+	if *dumpRules {
+		for name, rule := range rules.AllRules {
+			rule.Name = name
+			fmt.Println(rule)
+		}
+		return
+	}
+
+	if *dumpLeaderboard {
+		profiles, err := server.OpenProfileStore(*profilesFile)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, line := range profiles.Leaderboard().Lines() {
+			fmt.Println(line)
+		}
+		return
+	}
+
+	// panic protection
+	defer logging.LogPanic()
+
+	// seed random
+	rand.Seed(time.Now().Unix())
+
+	var attachTo interface {
+		AttachPlayer(netproto.Render, string) int
+	}
+
+	var ruleset = &rules.Ruleset{}
+	var admin *server.Admin
+	var rooms *server.RoomManager
+
+	if *ruleFile != "" {
+		fileRules, err := readRuleFile(*ruleFile)
+		if err != nil {
+			mainLog.Errorf("failed to parse rule file '%s': '%s'", *ruleFile, err)
+		}
+		*ruleSet = append(*ruleSet, fileRules...)
+	}
+
+	if len(*ruleSet) > 0 {
+		for _, r := range *ruleSet {
+			err := ruleset.AddRules(r)
+			if err != nil {
+				mainLog.Warnf("invalid rule '%s', skipping", r)
+			}
+		}
+	}
+
+	if *tickWorkerCount > 0 {
+		engine.TickWorkers = *tickWorkerCount
+	}
+
+	if *profileTickCount > 0 {
+		if len(*ruleset) == 0 {
+			ruleset.AddRules("classic")
+		}
+		engine.ProfileTicks((*ruleset)[0], *profileTickCount, os.Stdout)
+		return
+	}
+
+	if *listRooms {
+		rooms, err := netproto.ListRooms(*connect)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, room := range rooms {
+			fmt.Println(room)
+		}
+		return
+	}
+
+	if *connect != "" {
+		// connect to remote game
+		mainLog.Infof("connecting to remote game at '%s'", *connect)
+		remote, err := netproto.ConnectRemoteGame(*connect, *playerName, *roomName, *roomRule)
+		if err != nil {
+			mainLog.Errorf("cannot connect: %s", err)
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(remote.Info)
+
+		go remote.Run()
+		attachTo = remote
+	} else {
+		// start local game
+		if len(*ruleset) == 0 {
+			mainLog.Fatalf("no valid ruleset specified")
+		}
+
+		// create dispatcher
+		if len(*ruleset) == 0 {
+			if *listen != "" {
+				ruleset.AddRules("single")
+			} else {
+				ruleset.AddRules("classic")
+			}
+		}
+
+		profiles, err := server.OpenProfileStore(*profilesFile)
+		if err != nil {
+			mainLog.Warnf("cannot load profiles, stats will not be saved: %s", err)
+			profiles = nil
+		}
+
+		mainLog.Infof("starting dispatcher")
+		dispatcher := server.NewDispatcher(ruleset, profiles)
+		rooms = server.NewRoomManager(dispatcher, profiles)
+		go dispatcher.Run()
+		attachTo = dispatcher
+
+		if *listen != "" {
+			mainLog.Infof("starting server at '%s'", *listen)
+			go rooms.Run()
+			srv, err := server.CreateServer(rooms, *listen, *serverName)
+			if err != nil {
+				mainLog.Fatalf("cannot start server: %s", err)
+			}
+			for _, who := range *banList {
+				srv.Ban(who)
+			}
+			go srv.Serve()
+
+			admin = server.NewAdmin(rooms, srv)
+			if *adminSocket != "" {
+				if err := admin.ServeUnix(*adminSocket); err != nil {
+					mainLog.Fatalf("cannot serve admin console: %s", err)
+				}
+			}
+		}
+	}
+
+	if *debug {
+		go server.RunDebugAt(*debugAddr, rooms)
+	}
+
+	if *listen != "" && *standalone {
+		fmt.Println("server started on", *listen)
+		// console works until stdin is closed, server keeps running after that
+		admin.Serve(os.Stdin, os.Stdout)
+		for {
+			time.Sleep(time.Hour)
+		}
+	} else {
+		// create local render
+		mainLog.Infof("creating local render")
+		render := tui.NewLocalRender(*frameRate)
+		render.Init()
+
+		mainLog.Infof("attaching to game")
+		// attach render (as player)
+		attachTo.AttachPlayer(render, *playerName)
+
+		mainLog.Infof("ready to play!")
+		// run render
+		render.Run()
+	}
+}
+
+// stringSet implements flag.Value interface
+type stringSet []string
+
+func (s *stringSet) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *stringSet) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
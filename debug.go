@@ -1,10 +0,0 @@
-package main
-
-import (
-	"net/http"
-	_ "net/http/pprof"
-)
-
-func runDebugAt(addr string) {
-	http.ListenAndServe(addr, nil)
-}
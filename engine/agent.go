@@ -1,7 +1,7 @@
-package main
+package engine
 
 import (
-	"math/rand"
+	"github.com/mechmind/life-goes-on/geom"
 )
 
 const (
@@ -15,7 +15,7 @@ type Agent interface {
 	AttachUnit(Unit)
 	DetachUnit(Unit)
 
-	HandleUnit(*FieldView, Unit, UnitCoord)
+	HandleUnit(*FieldView, Unit, geom.UnitCoord)
 }
 
 type Thinker interface {
@@ -24,11 +24,11 @@ type Thinker interface {
 
 type Squad struct {
 	Units       []*Soldier
-	Target      UnitCoord
+	Target      geom.UnitCoord
 	Automove    bool
 	Orders      chan Order
 	FireState   int
-	GrenTo      CellCoord
+	GrenTo      geom.CellCoord
 	GrenTimeout int
 	Pid         int
 	Versus      bool
@@ -51,20 +51,31 @@ func (s *Squad) DetachUnit(u Unit) {
 	}
 }
 
-func (s *Squad) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
+func (s *Squad) HandleUnit(f *FieldView, u Unit, Coord geom.UnitCoord) {
 	soldier := u.(*Soldier)
 
 	if soldier.SemifireCounter > 0 {
 		soldier.SemifireCounter--
 	}
 
-	if s.GrenTo != (CellCoord{0, 0}) && s.GrenTimeout == 0 {
+	if s.handleMorale(f, soldier, Coord) {
+		// soldier is fleeing
+		return
+	}
+
+	if s.GrenTo != (geom.CellCoord{X: 0, Y: 0}) && s.GrenTimeout == 0 {
 		GrenTo := s.GrenTo.UnitCenter()
 		if Coord.Distance(GrenTo) < SOL_GREN_RANGE && f.HaveLOS(Coord, GrenTo) != VS_INVISIBLE {
-			// throw gren
-			s.GrenTo = CellCoord{0, 0}
-			f.ThrowGren(Coord, GrenTo)
-			s.GrenTimeout = SOL_GREN_TIMEOUT
+			// throw gren, unless squadmate earlier in unit order did it
+			target := s.GrenTo
+			f.Effect(soldier.Id, func() {
+				if s.GrenTo != target || s.GrenTimeout != 0 {
+					return
+				}
+				s.GrenTo = geom.CellCoord{X: 0, Y: 0}
+				f.ThrowGren(soldier.Id, Coord, GrenTo)
+				s.GrenTimeout = SOL_GREN_TIMEOUT
+			})
 			return
 		}
 	}
@@ -77,16 +88,14 @@ func (s *Squad) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 			if _, ok := enemy.Unit.(*Zed); ok {
 				if soldier.CanShoot(Coord, enemy.Coord) {
 					// shoot that zed
-					soldier.Shoot(Coord, enemy.Coord, enemy.Unit)
-					soldier.SemifireCounter = SOL_SEMIFIRE_TICKS
+					s.shoot(f, soldier, Coord, enemy)
 					return
 				}
 			} else if s.Versus {
 				if _, ok := enemy.Unit.(*Soldier); ok && enemy.Agent != s {
 					// shoot at enemy soldier
 					if soldier.CanShoot(Coord, enemy.Coord) {
-						soldier.Shoot(Coord, enemy.Coord, enemy.Unit)
-						soldier.SemifireCounter = SOL_SEMIFIRE_TICKS
+						s.shoot(f, soldier, Coord, enemy)
 						return
 					}
 				}
@@ -94,7 +103,12 @@ func (s *Squad) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 		}
 	}
 
-	if s.Target.Cell() == (CellCoord{0, 0}) {
+	if s.Target.Cell() == (geom.CellCoord{X: 0, Y: 0}) {
+		return
+	}
+	rnd := f.field.unitRand(soldier.Id)
+	if soldier.IsShaken() && rnd.Intn(100) < SOL_MORALE_IGNORE_PROB {
+		// too scared to follow orders right now
 		return
 	}
 	// no zeds in fire range, move toward target
@@ -115,7 +129,7 @@ func (s *Squad) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 
 	Target, ok := soldier.path.Current()
 	if ok {
-		if Coord.Distance(Target.UnitCenter()) < FLOAT_ERROR {
+		if Coord.Distance(Target.UnitCenter()) < geom.FLOAT_ERROR {
 			Target, ok = soldier.path.Next()
 			if ok {
 				u.MoveToward(Coord, Target.UnitCenter())
@@ -126,6 +140,53 @@ func (s *Squad) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 	}
 }
 
+// shoot fires at enemy unless it is killed by units applied earlier
+func (s *Squad) shoot(f *FieldView, soldier *Soldier, Coord geom.UnitCoord, enemy UnitPresence) {
+	soldier.SemifireCounter = SOL_SEMIFIRE_TICKS
+	f.Effect(soldier.Id, func() {
+		if _, current := f.UnitByID(enemy.Unit.GetID()); current != enemy.Unit {
+			return
+		}
+		soldier.Shoot(Coord, enemy.Coord, enemy.Unit)
+	})
+}
+
+// handleMorale updates soldier's morale and returns true if soldier panics and flees
+func (s *Squad) handleMorale(f *FieldView, soldier *Soldier, Coord geom.UnitCoord) bool {
+	var zeds, allies int
+	var nearestZed UnitPresence
+	for _, u := range f.UnitsInRange(Coord, geom.Fmax(SOL_MORALE_FEAR_RANGE, SOL_MORALE_ALLY_RANGE)) {
+		dist := Coord.Distance(u.Coord)
+		switch u.Unit.(type) {
+		case *Zed:
+			if dist < SOL_MORALE_FEAR_RANGE {
+				if zeds == 0 {
+					nearestZed = u
+				}
+				zeds++
+			}
+		case *Soldier:
+			if u.Agent == s && u.Unit != soldier && dist < SOL_MORALE_ALLY_RANGE {
+				allies++
+			}
+		}
+	}
+	soldier.UpdateMorale(zeds, allies)
+
+	if soldier.IsPanicking() && zeds > 0 {
+		soldier.MoveAway(Coord, nearestZed.Coord)
+		return true
+	}
+	return false
+}
+
+// HandleDeath implements DeathWatcher, losing a squadmate hits morale of the rest
+func (s *Squad) HandleDeath(f *FieldView, u Unit, src DamageSource) {
+	for _, sol := range s.Units {
+		sol.LoseMorale(SOL_MORALE_SQUADMATE_DIE)
+	}
+}
+
 func (s *Squad) Think(view *FieldView, tick int64) {
 	if len(s.Units) == 0 {
 		return
@@ -156,9 +217,16 @@ OrderLoop:
 			case ORDER_SUICIDE:
 				// :(
 				for i := len(s.Units) - 1; i >= 0; i-- {
-					s.Units[i].RecieveDamage(-1, 1000)
+					view.DealDamage(DamageSource{-1, s.Pid, DAMAGE_SUICIDE}, s.Units[i].Id, 1000)
 				}
 				view.field.RemoveAgent(s)
+
+			case ORDER_HOLD:
+				// stay where leader is
+				if len(s.Units) > 0 {
+					s.Target, _ = view.UnitByID(s.Units[0].GetID())
+				}
+				s.Automove = false
 			}
 		default:
 			break OrderLoop
@@ -207,7 +275,7 @@ func (z *ZedSwarm) DetachUnit(u Unit) {
 	// FIXME: implement
 }
 
-func (z *ZedSwarm) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
+func (z *ZedSwarm) HandleUnit(f *FieldView, u Unit, Coord geom.UnitCoord) {
 	var zed *Zed
 	switch u.(type) {
 	case *Zed:
@@ -217,7 +285,9 @@ func (z *ZedSwarm) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 		corpse.RessurectCounter--
 		if corpse.RessurectCounter == 0 {
 			// respawn corpse as fresh new zed
-			f.ReplaceUnit(corpse.Id, z, corpse.Respawn())
+			f.Effect(corpse.Id, func() {
+				f.ReplaceUnit(corpse.Id, z, corpse.Respawn())
+			})
 		}
 		return
 	}
@@ -227,27 +297,18 @@ func (z *ZedSwarm) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 		return
 	}
 
-	var Target UnitCoord
+	var Target geom.UnitCoord
 	if zed.LastAttacker >= 0 {
 		// fight back
 		attackerCoord, attacker := f.UnitByID(zed.LastAttacker)
 		if zed.CanBite(Coord, attackerCoord) {
-			zed.Bite(Coord, attackerCoord, attacker)
-			_, attacker = f.UnitByID(zed.LastAttacker)
-			if corpse, ok := attacker.(*Corpse); ok {
-				// foe is bitten to death
-				zed.LastAttacker = -1
-				if zed.Nutrition > ZED_NUTRITION_FULL {
-					// infect corpse
-					corpse.RessurectCounter = CORPSE_RESSURECT_TICKS
-					// regain control over it
-					f.Reown(corpse.Id, z)
-					zed.Eat(ZED_INFECT_NUTRITION)
-				} else {
-					// eat it
-					zed.Eat(ZED_EAT_NUTRITION)
+			f.Effect(zed.Id, func() {
+				if z.bite(f, zed, Coord, attackerCoord, attacker) {
+					// foe is bitten to death
+					zed.LastAttacker = -1
 				}
-			}
+			})
+			return
 		} else {
 			Target = attackerCoord
 		}
@@ -274,22 +335,10 @@ func (z *ZedSwarm) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 		// chase toward nonzed
 		dest := nonzed.Coord
 		if zed.CanBite(Coord, dest) {
-			zed.Bite(Coord, dest, nonzed.Unit)
-			_, victim := f.UnitByID(nonzed.Unit.GetID())
-			if corpse, ok := victim.(*Corpse); ok {
-				// victim is bitten to death, eat it
-				if zed.Nutrition > ZED_NUTRITION_FULL {
-					// infect corpse
-					corpse.RessurectCounter = CORPSE_RESSURECT_TICKS
-					// regain control over it
-					f.Reown(corpse.Id, z)
-					zed.Eat(ZED_INFECT_NUTRITION)
-				} else {
-					// eat it
-					zed.Eat(ZED_EAT_NUTRITION)
-				}
-				return
-			}
+			f.Effect(zed.Id, func() {
+				z.bite(f, zed, Coord, dest, nonzed.Unit)
+			})
+			return
 		} else {
 			Target = dest
 		}
@@ -299,14 +348,14 @@ func (z *ZedSwarm) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 		// forget about path, rush toward target
 		zed.path = nil
 		zed.MoveToward(Coord, Target)
-	} else if Target.Cell() != (CellCoord{0, 0}) {
+	} else if Target.Cell() != (geom.CellCoord{X: 0, Y: 0}) {
 		// follow path to target or create one
 		if zed.path == nil {
 			zed.path = f.FindPath(Coord.Cell(), Target.Cell())
 		}
 		Target, ok := zed.path.Current()
 		if ok {
-			if Coord.Distance(Target.UnitCenter()) < FLOAT_ERROR {
+			if Coord.Distance(Target.UnitCenter()) < geom.FLOAT_ERROR {
 				Target, ok = zed.path.Next()
 				if ok {
 					u.MoveToward(Coord, Target.UnitCenter())
@@ -320,6 +369,31 @@ func (z *ZedSwarm) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 	}
 }
 
+// bite attacks victim and eats or infects it if it is a corpse afterwards. Victim killed or
+// replaced by units applied earlier is left alone. Returns true if there was a corpse to eat
+func (z *ZedSwarm) bite(f *FieldView, zed *Zed, Coord, victimCoord geom.UnitCoord, victim Unit) bool {
+	if _, current := f.UnitByID(victim.GetID()); current != victim {
+		return false
+	}
+	zed.Bite(Coord, victimCoord, victim)
+	_, victim = f.UnitByID(victim.GetID())
+	corpse, ok := victim.(*Corpse)
+	if !ok {
+		return false
+	}
+	if zed.Nutrition > ZED_NUTRITION_FULL {
+		// infect corpse
+		corpse.RessurectCounter = CORPSE_RESSURECT_TICKS
+		// regain control over it
+		f.Reown(corpse.Id, z)
+		zed.Eat(ZED_INFECT_NUTRITION)
+	} else {
+		// eat it
+		zed.Eat(ZED_EAT_NUTRITION)
+	}
+	return true
+}
+
 type DamselCrowd struct {
 	Units []*Damsel
 }
@@ -332,7 +406,7 @@ func (d *DamselCrowd) DetachUnit(u Unit) {
 	// FIXME: implement
 }
 
-func (d *DamselCrowd) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
+func (d *DamselCrowd) HandleUnit(f *FieldView, u Unit, Coord geom.UnitCoord) {
 	dam := u.(*Damsel)
 	if dam.LastAttacker >= 0 {
 		// flee away
@@ -348,12 +422,13 @@ func (d *DamselCrowd) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 	} else {
 		if dam.WanderTarget == Coord {
 			// wander around
+			rnd := f.field.unitRand(dam.Id)
 			for i := 0; i < DAMSEL_WANDER_TRIES; i++ {
-				rx := ibound(Coord.Cell().X+int(rand.Int31n(DAMSEL_WANDER_RADIUS))-
+				rx := geom.Ibound(Coord.Cell().X+rnd.Intn(DAMSEL_WANDER_RADIUS)-
 					DAMSEL_WANDER_RADIUS/2, 0, 1024)
-				ry := ibound(Coord.Cell().Y+int(rand.Int31n(DAMSEL_WANDER_RADIUS))-
+				ry := geom.Ibound(Coord.Cell().Y+rnd.Intn(DAMSEL_WANDER_RADIUS)-
 					DAMSEL_WANDER_RADIUS/2, 0, 1024)
-				newCoord := CellCoord{rx, ry}.UnitCenter()
+				newCoord := geom.CellCoord{X: rx, Y: ry}.UnitCenter()
 				if f.HaveDirectPath(Coord, newCoord) {
 					dam.WanderTarget = newCoord
 				}
@@ -365,15 +440,15 @@ func (d *DamselCrowd) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {
 	dam.Adrenaline -= DAM_ADRENALINE_FADE
 	if dam.Adrenaline < 0 {
 		dam.Adrenaline = 0
-		if dam.PanicPoint != (UnitCoord{0, 0}) {
+		if dam.PanicPoint != (geom.UnitCoord{X: 0, Y: 0}) {
 			dam.WanderTarget = Coord
-			dam.PanicPoint = UnitCoord{0, 0}
+			dam.PanicPoint = geom.UnitCoord{X: 0, Y: 0}
 		}
 	}
 }
 
 type NopAgent struct{}
 
-func (n NopAgent) AttachUnit(u Unit)                                {}
-func (n NopAgent) DetachUnit(u Unit)                                {}
-func (n NopAgent) HandleUnit(f *FieldView, u Unit, Coord UnitCoord) {}
+func (n NopAgent) AttachUnit(u Unit)                                     {}
+func (n NopAgent) DetachUnit(u Unit)                                     {}
+func (n NopAgent) HandleUnit(f *FieldView, u Unit, Coord geom.UnitCoord) {}
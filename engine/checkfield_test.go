@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+// newCheckField is a small open field with seeded rng for scenarios
+func newCheckField(size int) *Field {
+	f := NewField(size, size, make(chan *Field, 1))
+	f.setSeed(1)
+	return f
+}
+
+// newCheckSquad places holding fire squad of soldiers at coords
+func newCheckSquad(f *Field, Pid int, coords ...geom.UnitCoord) *Squad {
+	squad := &Squad{Orders: make(chan Order, SQUAD_ORDER_QUEUE_LEN), Pid: Pid,
+		FireState: ORDER_NOFIRE}
+	f.PlaceAgent(squad)
+	for _, c := range coords {
+		f.PlaceUnit(c, squad, NewSoldier(f))
+	}
+	return squad
+}
+
+// wall puts walls along given cells
+func (f *Field) wall(cells ...geom.CellCoord) {
+	for _, c := range cells {
+		f.PlaceObject(c, referenceObjects[OBJECT_WALL])
+		f.CellAt(c).Passable = false
+	}
+}
+
+// runTicks advances field for n ticks
+func (f *Field) runTicks(n int) {
+	for i := 0; i < n; i++ {
+		f.Tick(f.tick + 1)
+	}
+}
+
+// checkAgent owns units in scenarios, does nothing on its own and remembers deaths
+type checkAgent struct {
+	units  []Unit
+	deaths []DamageSource
+}
+
+func (c *checkAgent) AttachUnit(u Unit) {
+	c.units = append(c.units, u)
+}
+
+func (c *checkAgent) DetachUnit(u Unit) {}
+
+func (c *checkAgent) HandleUnit(f *FieldView, u Unit, Coord geom.UnitCoord) {}
+
+func (c *checkAgent) HandleDeath(f *FieldView, u Unit, src DamageSource) {
+	c.deaths = append(c.deaths, src)
+}
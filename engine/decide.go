@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+const (
+	// units are handed to decision workers in chunks
+	TICK_DECIDE_CHUNK = 64
+)
+
+// TickWorkers is number of goroutines deciding for units of new fields, set by -tick-workers
+var TickWorkers = runtime.GOMAXPROCS(0)
+
+// Units are handled in two phases. In decision phase agents handle units concurrently on a field
+// which does not change: they may read anything, but write only to the unit they handle. Moves
+// and effects on other units and on the field are recorded and applied afterwards, one unit at a
+// time in unit order, so outcome does not depend on number of workers.
+type pendingUnit struct {
+	unit    Unit
+	agent   Agent
+	moved   bool
+	coord   geom.UnitCoord
+	effects []func()
+}
+
+func (f *Field) decideUnits(view *FieldView) {
+	n := len(f.Units)
+	if cap(f.pending) < n {
+		f.pending = make([]pendingUnit, n)
+	}
+	f.pending = f.pending[:n]
+
+	workers := f.workers
+	if chunks := (n + TICK_DECIDE_CHUNK - 1) / TICK_DECIDE_CHUNK; workers > chunks {
+		workers = chunks
+	}
+
+	f.deciding = true
+	defer func() { f.deciding = false }()
+	if workers <= 1 {
+		f.decideRange(view, 0, n)
+		return
+	}
+
+	var next int64
+	var wg sync.WaitGroup
+	panics := make(chan interface{}, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				// rethrown on ticking goroutine
+				if p := recover(); p != nil {
+					panics <- p
+				}
+			}()
+			for {
+				low := int(atomic.AddInt64(&next, TICK_DECIDE_CHUNK)) - TICK_DECIDE_CHUNK
+				if low >= n {
+					return
+				}
+				high := low + TICK_DECIDE_CHUNK
+				if high > n {
+					high = n
+				}
+				f.decideRange(view, low, high)
+			}
+		}()
+	}
+	wg.Wait()
+	select {
+	case p := <-panics:
+		panic(p)
+	default:
+	}
+}
+
+func (f *Field) decideRange(view *FieldView, low, high int) {
+	for Id := low; Id < high; Id++ {
+		up := f.Units[Id]
+		p := &f.pending[Id]
+		p.unit, p.agent, p.moved = up.Unit, up.Agent, false
+		up.Agent.HandleUnit(view, up.Unit, up.Coord)
+	}
+}
+
+func (f *Field) applyUnits() {
+	for Id := range f.pending {
+		p := &f.pending[Id]
+		// unit killed or replaced by effects of previous units does nothing
+		if f.Units[Id].Unit == p.unit {
+			if p.moved {
+				f.Units[Id].Coord = p.coord
+			}
+			for _, fn := range p.effects {
+				fn()
+			}
+		}
+		for idx := range p.effects {
+			p.effects[idx] = nil
+		}
+		p.effects = p.effects[:0]
+		f.Profiler.markAgent("apply", p.agent)
+	}
+}
+
+// effect schedules fn to be applied after all units decided, or runs it right away outside of
+// decision phase. Id is the unit fn is an effect of
+func (f *Field) effect(Id int, fn func()) {
+	if !f.deciding {
+		fn()
+		return
+	}
+	p := &f.pending[Id]
+	p.effects = append(p.effects, fn)
+}
+
+// unitRand is random source for decisions. It depends only on field seed, tick and unit, so
+// decision does not depend on worker which makes it
+type unitRand struct {
+	state uint64
+}
+
+func (f *Field) unitRand(Id int) unitRand {
+	return unitRand{uint64(f.seed) ^ uint64(f.tick)*0x9e3779b97f4a7c15 ^ uint64(Id)<<32}
+}
+
+// splitmix64
+func (r *unitRand) next() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (r *unitRand) Intn(n int) int {
+	return int(r.next() % uint64(n))
+}
@@ -1,12 +1,16 @@
-package main
+// Package engine simulates the game: field and terrain, units and agents controlling them.
+package engine
 
 import (
 	"math/rand"
+	"sync/atomic"
 	"time"
+
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/pathfind"
 )
 
 const (
-	FLOAT_ERROR           = 0.000001
 	FIELD_BACKBUFFER_SIZE = 3
 	FIELD_SIZE            = 1024
 	FIELD_GAME_STATE_BUF  = 10
@@ -41,56 +45,152 @@ type Field struct {
 	Cells        []Cell
 	Agents       []Agent
 	Units        []UnitPresence
-	updates      chan *Field
+	Updates      chan *Field
+
+	// Cells are shared with snapshot, copy them before change
+	cellsShared bool
+
+	// Fog is set on snapshots filtered for particular player
+	Fog bool
 
 	// FIXME(pathfind): remove after debugging
-	pathfinder *PathFinder
+	Pathfinder *pathfind.PathFinder
 
 	// moving stuff
 	Grens []FlyingGren
 
 	// rng
-	rng *rand.Rand
+	rng  *rand.Rand
+	seed int64
+
+	// two-phase unit handling, see decideUnits
+	workers  int
+	deciding bool
+	pending  []pendingUnit
 
 	// game state
-	gameState chan GameState
+	GameState chan GameState
 	gameOver  bool
 	versus    bool
+
+	// damage accounting
+	ledger       DamageLedger
+	friendlyFire bool
+	tick         int64
+	squadPids    []int
+	Summary      chan RoundSummary
+
+	// debug stats, nil for fields outside of dispatcher
+	Stats     *RoomStats
+	pathCalls int64
+	Profiler  *TickProfiler
 }
 
 func NewField(XSize, YSize int, updates chan *Field) *Field {
-	rng := rand.New(rand.NewSource(time.Now().Unix()))
-	field := &Field{XSize, YSize, make([]Cell, XSize*YSize), nil, nil, updates, nil, nil, rng,
-		make(chan GameState, FIELD_GAME_STATE_BUF), false, false}
+	field := &Field{XSize: XSize, YSize: YSize, Cells: make([]Cell, XSize*YSize),
+		Updates: updates, workers: TickWorkers,
+		GameState:    make(chan GameState, FIELD_GAME_STATE_BUF),
+		friendlyFire: true, Summary: make(chan RoundSummary, 1)}
+	field.setSeed(time.Now().Unix())
 	field.makePassableField()
 	field.computeSlopes()
 	return field
 }
 
-func copyField(f *Field) *Field {
+// setSeed makes field rng and unit decisions repeatable
+func (f *Field) setSeed(seed int64) {
+	f.seed = seed
+	f.rng = rand.New(rand.NewSource(seed))
+}
+
+// CopyField makes snapshot of f which simulation never touches again: units and squads
+// are copied by value, cells are shared until field changes one of them, see mutableCell.
+// Other agents are kept as is, snapshot readers must not look into them
+func CopyField(f *Field) *Field {
 	bb := &Field{XSize: f.XSize, YSize: f.YSize}
 
 	bb.Cells = f.Cells
-	bb.Units = append(bb.Units[:0], f.Units...)
-	bb.Agents = append(bb.Agents[:0], f.Agents...)
-	bb.Grens = append(bb.Grens[:0], f.Grens...)
+	f.cellsShared = true
+	bb.Units = make([]UnitPresence, len(f.Units))
+	for idx, up := range f.Units {
+		bb.Units[idx] = UnitPresence{up.Coord, up.Agent, copyUnit(bb, up.Unit)}
+	}
+
+	squads := make(map[Agent]Agent)
+	bb.Agents = make([]Agent, len(f.Agents))
+	for idx, a := range f.Agents {
+		if squad, ok := a.(*Squad); ok {
+			a = copySquad(bb, squad)
+			squads[squad] = a
+		}
+		bb.Agents[idx] = a
+	}
+	for idx, up := range bb.Units {
+		if squad, ok := squads[up.Agent]; ok {
+			bb.Units[idx].Agent = squad
+		}
+	}
+	bb.Grens = append([]FlyingGren(nil), f.Grens...)
 	//bb.pathfinder = f.pathfinder // FIXME(pathfind)
 
 	return bb
 }
 
+// copyUnit returns copy of u living on field bb, without path it was following
+func copyUnit(bb *Field, u Unit) Unit {
+	switch u := u.(type) {
+	case *Soldier:
+		sol := *u
+		sol.field, sol.path = bb, nil
+		sol.Items = append([]Item(nil), u.Items...)
+		return &sol
+	case *Zed:
+		zed := *u
+		zed.field, zed.path = bb, nil
+		return &zed
+	case *Damsel:
+		dam := *u
+		dam.field = bb
+		return &dam
+	case *Corpse:
+		corpse := *u
+		corpse.field = bb
+		corpse.Unit = copyUnit(bb, u.Unit)
+		return &corpse
+	}
+	return u
+}
+
+// copySquad returns squad which cannot be ordered, with soldiers taken from bb
+func copySquad(bb *Field, s *Squad) *Squad {
+	squad := *s
+	squad.Orders = nil
+	squad.Units = make([]*Soldier, 0, len(s.Units))
+	for _, sol := range s.Units {
+		if sol, ok := bb.Units[sol.Id].Unit.(*Soldier); ok {
+			squad.Units = append(squad.Units, sol)
+		}
+	}
+	return &squad
+}
+
 func (f *Field) Tick(tick int64) {
+	f.tick = tick
+	start := time.Now()
+	f.pathCalls = 0
+	f.Profiler.begin()
 	view := &FieldView{f}
 
 	for _, Agent := range f.Agents {
 		if thinker, ok := Agent.(Thinker); ok {
 			thinker.Think(view, tick)
+			f.Profiler.markAgent("think", Agent)
 		}
 	}
 
-	for _, up := range f.Units {
-		up.Agent.HandleUnit(view, up.Unit, up.Coord)
-	}
+	f.decideUnits(view)
+	f.Profiler.mark("decide")
+	f.applyUnits()
 
 	// handle exploded grens
 	for i := 0; i < len(f.Grens); {
@@ -107,33 +207,42 @@ func (f *Field) Tick(tick int64) {
 
 	// handle flying grens
 	for idx, gren := range f.Grens {
-		toward := NormTowardCoord(gren.From, gren.To).Mult(SOL_GREN_SPEED)
-		if gren.From.Distance(gren.To) < FLOAT_ERROR && f.Grens[idx].Booming == 0 {
+		toward := geom.NormTowardCoord(gren.From, gren.To).Mult(SOL_GREN_SPEED)
+		if gren.From.Distance(gren.To) < geom.FLOAT_ERROR && f.Grens[idx].Booming == 0 {
 			// BOOM
 			f.Grens[idx].Booming = 1 // for animation
 			for _, u := range view.UnitsInRange(gren.To, SOL_GREN_RADIUS) {
 				if f.HaveDirectPath(u.Coord, gren.To) {
-					u.Unit.RecieveDamage(-1, SOL_GREN_DAMAGE)
+					f.DealDamage(gren.Source, u.Unit.GetID(), SOL_GREN_DAMAGE)
 				}
 			}
 		}
-		if gren.From.Distance(gren.To) < toward.Distance(UnitCoord{0, 0}) {
+		if gren.From.Distance(gren.To) < toward.Distance(geom.UnitCoord{X: 0, Y: 0}) {
 			f.Grens[idx].From = gren.To
 		} else {
 			f.Grens[idx].From = gren.From.AddCoord(toward)
 		}
 	}
 
+	f.Profiler.mark("grenades")
+
 	// check game over
 	if tick%TIME_TICKS_PER_SEC == 0 && !f.gameOver {
 		f.checkGameOver()
+		f.Profiler.mark("game-over")
+	}
+
+	if f.Stats != nil {
+		f.Stats.recordTick(f, time.Since(start))
 	}
 
 	// send update
 	select {
-	case f.updates <- copyField(f):
+	case f.Updates <- CopyField(f):
 	default:
 	}
+	f.Profiler.mark("snapshot")
+	f.Profiler.end(tick)
 }
 
 func (f *Field) checkGameOver() {
@@ -159,14 +268,14 @@ func (f *Field) checkGameOver() {
 			if len(squad.Units) == 0 {
 				// all soldiers are dead, so player is defeated
 				f.Agents[idx] = nil
-				f.gameState <- GameState{GAME_LOSE, squad.Pid}
+				f.GameState <- GameState{GAME_LOSE, squad.Pid}
 			}
 		}
 	}
 
 	if Ss == 0 {
-		f.gameState <- GameState{GAME_OVER, -1}
-		f.gameOver = true
+		f.endRound(Bs)
+		return
 	}
 
 	if Zs == 0 {
@@ -190,28 +299,43 @@ func (f *Field) checkGameOver() {
 
 			if squadCount == 1 {
 				// we have a winner
-				f.gameState <- GameState{winstate, lastSquad.Pid}
-				f.gameState <- GameState{GAME_OVER, -1}
-				f.gameOver = true
+				f.GameState <- GameState{winstate, lastSquad.Pid}
+				f.endRound(Bs)
 			} else if squadCount == 0 {
 				// everybody lose
-				f.gameState <- GameState{GAME_OVER, -1}
-				f.gameOver = true
+				f.endRound(Bs)
 			}
 
 		} else {
 			for _, agent := range f.Agents {
 				if squad, ok := agent.(*Squad); ok {
-					f.gameState <- GameState{winstate, squad.Pid}
+					f.GameState <- GameState{winstate, squad.Pid}
 				}
 			}
-			f.gameState <- GameState{GAME_OVER, -1}
-			f.gameOver = true
+			f.endRound(Bs)
 		}
 	}
 }
 
-func (f *Field) PlaceUnit(c UnitCoord, Agent Agent, u Unit) error {
+// endRound marks game as over and publishes round summary
+func (f *Field) endRound(Bs int) {
+	summary := RoundSummary{Ledger: f.ledger.Copy(), Damsels: Bs,
+		Players: append([]int(nil), f.squadPids...)}
+	for _, agent := range f.Agents {
+		if squad, ok := agent.(*Squad); ok && len(squad.Units) > 0 {
+			summary.Survivors = append(summary.Survivors, squad.Pid)
+		}
+	}
+	select {
+	case f.Summary <- summary:
+	default:
+	}
+
+	f.GameState <- GameState{GAME_OVER, -1}
+	f.gameOver = true
+}
+
+func (f *Field) PlaceUnit(c geom.UnitCoord, Agent Agent, u Unit) error {
 	f.Units = append(f.Units, UnitPresence{c, Agent, u})
 	u.SetID(len(f.Units) - 1)
 	Agent.AttachUnit(u)
@@ -219,6 +343,9 @@ func (f *Field) PlaceUnit(c UnitCoord, Agent Agent, u Unit) error {
 }
 
 func (f *Field) PlaceAgent(a Agent) error {
+	if squad, ok := a.(*Squad); ok {
+		f.squadPids = append(f.squadPids, squad.Pid)
+	}
 	f.Agents = append(f.Agents, a)
 	return nil
 }
@@ -232,7 +359,7 @@ func (f *Field) RemoveAgent(a Agent) error {
 	return nil
 }
 
-func (f *Field) ReplaceUnit(Id int, c UnitCoord, Agent Agent, u Unit) error {
+func (f *Field) ReplaceUnit(Id int, c geom.UnitCoord, Agent Agent, u Unit) error {
 	f.Units[Id] = UnitPresence{c, Agent, u}
 	u.SetID(Id)
 	Agent.AttachUnit(u)
@@ -240,11 +367,16 @@ func (f *Field) ReplaceUnit(Id int, c UnitCoord, Agent Agent, u Unit) error {
 }
 
 // unit/agent api
-func (f *Field) CellAt(c CellCoord) *Cell {
+func (f *Field) CellAt(c geom.CellCoord) *Cell {
 	return &f.Cells[c.Y*f.XSize+c.X]
 }
 
-func (f *Field) UnitByID(Id int) (UnitCoord, Unit) {
+// Passable lets pathfinder walk the field
+func (f *Field) Passable(c geom.CellCoord) bool {
+	return f.CellAt(c).Passable
+}
+
+func (f *Field) UnitByID(Id int) (geom.UnitCoord, Unit) {
 	return f.Units[Id].Coord, f.Units[Id].Unit
 }
 
@@ -252,7 +384,7 @@ func (f *Field) AgentForUnitID(Id int) Agent {
 	return f.Units[Id].Agent
 }
 
-func (f *Field) UnitsInRange(center UnitCoord, radius float32) []UnitPresence {
+func (f *Field) UnitsInRange(center geom.UnitCoord, radius float32) []UnitPresence {
 	var Units []UnitPresence
 
 	for _, up := range f.Units {
@@ -264,21 +396,21 @@ func (f *Field) UnitsInRange(center UnitCoord, radius float32) []UnitPresence {
 }
 
 // return true if have line of sight from 'from' to 'to'
-func (f *Field) TraceShot(From, To UnitCoord, tid int) (atid int, atcoord UnitCoord) {
+func (f *Field) TraceShot(From, To geom.UnitCoord, tid int) (atid int, atcoord geom.UnitCoord) {
 	// misshots start when accuracy starting do decay
 	if From.Distance(To) <= SOL_ACC_DECAY_START {
 		return tid, To
 	}
 
-	toward := NormTowardCoord(From, To)
+	toward := geom.NormTowardCoord(From, To)
 
-	low := UnitCoord{fmin(From.X, To.X), fmin(From.Y, To.Y)}.Cell()
-	high := UnitCoord{fmax(From.X, To.X), fmax(From.Y, To.Y)}.Cell()
+	low := geom.UnitCoord{X: geom.Fmin(From.X, To.X), Y: geom.Fmin(From.Y, To.Y)}.Cell()
+	high := geom.UnitCoord{X: geom.Fmax(From.X, To.X), Y: geom.Fmax(From.Y, To.Y)}.Cell()
 
-	Units := make(map[CellCoord][]UnitPresence)
+	Units := make(map[geom.CellCoord][]UnitPresence)
 	for _, up := range f.Units {
 		cellCoord := up.Coord.Cell()
-		if CheckCellCoordBounds(cellCoord, low, high) {
+		if geom.CheckCellCoordBounds(cellCoord, low, high) {
 			us := append(Units[cellCoord], up)
 			Units[cellCoord] = us
 		}
@@ -301,7 +433,7 @@ func (f *Field) TraceShot(From, To UnitCoord, tid int) (atid int, atcoord UnitCo
 			}
 		}
 
-		stepCoord := NextCellCoord(current, toward)
+		stepCoord := geom.NextCellCoord(current, toward)
 		if currentCell.AddCoord(stepCoord) != current.AddCoord(toward).Cell() {
 			unitsHere := Units[currentCell.AddCoord(stepCoord)]
 			for _, u := range unitsHere {
@@ -325,8 +457,8 @@ func (f *Field) TraceShot(From, To UnitCoord, tid int) (atid int, atcoord UnitCo
 	//return tid, to
 }
 
-func (f *Field) HaveLOS(From, To UnitCoord) int {
-	toward := NormTowardCoord(From, To)
+func (f *Field) HaveLOS(From, To geom.UnitCoord) int {
+	toward := geom.NormTowardCoord(From, To)
 	toCell := To.Cell()
 	fromCell := From.Cell()
 
@@ -339,7 +471,7 @@ func (f *Field) HaveLOS(From, To UnitCoord) int {
 		if f.CellAt(currCoord).Opaque == true {
 			if currCoord == toCell {
 				return VS_ON_HORIZON
-			// FIXME: hack for those who hiding on edge of bushes
+				// FIXME: hack for those who hiding on edge of bushes
 			} else if currCoord != fromCell {
 				return VS_INVISIBLE
 			}
@@ -349,7 +481,7 @@ func (f *Field) HaveLOS(From, To UnitCoord) int {
 			return VS_VISIBLE
 		}
 
-		nextCell := From.Cell().AddCoord(NextCellCoord(From, toward)).Bound(0, 0, 1024, 1024)
+		nextCell := From.Cell().AddCoord(geom.NextCellCoord(From, toward)).Bound(0, 0, 1024, 1024)
 		if f.CellAt(nextCell).Opaque == true {
 			if nextCell == toCell {
 				return VS_ON_HORIZON
@@ -365,8 +497,8 @@ func (f *Field) HaveLOS(From, To UnitCoord) int {
 	}
 }
 
-func (f *Field) HaveDirectPath(From, To UnitCoord) bool {
-	toward := NormTowardCoord(From, To)
+func (f *Field) HaveDirectPath(From, To geom.UnitCoord) bool {
+	toward := geom.NormTowardCoord(From, To)
 
 	current := From
 	for {
@@ -374,7 +506,7 @@ func (f *Field) HaveDirectPath(From, To UnitCoord) bool {
 		// on one step
 
 		currCoord := current.Cell()
-		if !f.CellAt(currCoord).Passable  {
+		if !f.CellAt(currCoord).Passable {
 			return false
 		}
 
@@ -382,7 +514,7 @@ func (f *Field) HaveDirectPath(From, To UnitCoord) bool {
 			return true
 		}
 
-		nextCell := From.Cell().AddCoord(NextCellCoord(From, toward)).Bound(0, 0, 1024, 1024)
+		nextCell := From.Cell().AddCoord(geom.NextCellCoord(From, toward)).Bound(0, 0, 1024, 1024)
 		if !f.CellAt(nextCell).Passable {
 			return false
 		}
@@ -395,13 +527,13 @@ func (f *Field) HaveDirectPath(From, To UnitCoord) bool {
 	}
 }
 
-func (f *Field) CheckPassability(src, dst CellCoord) Passability {
+func (f *Field) CheckPassability(src, dst geom.CellCoord) Passability {
 	dstCell := f.CellAt(dst)
 	srcCell := f.CellAt(src)
 	if !dstCell.Passable {
 		return PS_IMPASSABLE
 	}
-	if iabs(int(srcCell.Elevation-dstCell.Elevation)) > 2 {
+	if geom.Iabs(int(srcCell.Elevation-dstCell.Elevation)) > 2 {
 		return PS_IMPASSABLE
 	}
 	if src.Distance(dst) > 1 {
@@ -416,41 +548,64 @@ func (f *Field) CheckPassability(src, dst CellCoord) Passability {
 	return PS_PASSABLE
 }
 
-func (f *Field) MoveMe(Id int, Coord UnitCoord) UnitCoord {
+func (f *Field) MoveMe(Id int, Coord geom.UnitCoord) geom.UnitCoord {
+	if f.deciding {
+		// unit moves after everybody decided
+		p := &f.pending[Id]
+		p.moved, p.coord = true, Coord
+		return Coord
+	}
 	f.Units[Id].Coord = Coord
 	return Coord
 }
 
-func (f *Field) KillMe(Id int) {
-	// kill unit
+// makeCorpse replaces unit with its corpse, use killUnit to kill units
+func (f *Field) makeCorpse(Id int) {
 	f.Units[Id].Agent.DetachUnit(f.Units[Id].Unit)
 	Unit := f.Units[Id].Unit
 	f.Units[Id] = UnitPresence{Coord: f.Units[Id].Coord, Agent: nopAgent,
 		Unit: &Corpse{f, Id, Unit, 0}}
 }
 
-func (f *Field) ThrowGren(From, To UnitCoord) {
-	f.Grens = append(f.Grens, FlyingGren{From, To, 0})
+func (f *Field) ThrowGren(Thrower int, From, To geom.UnitCoord) {
+	src := f.sourceOf(Thrower, DAMAGE_GREN)
+	if src.Pid >= 0 {
+		f.ledger.Counters(src.Pid).Grens++
+	}
+	f.Grens = append(f.Grens, FlyingGren{From, To, 0, src})
 }
 
-func (f *Field) FindPath(From, To CellCoord) Path {
-	finder := NewPathFinder(f)
+func (f *Field) FindPath(From, To geom.CellCoord) pathfind.Path {
+	atomic.AddInt64(&f.pathCalls, 1)
+	finder := pathfind.NewPathFinder(f)
 	path := finder.FindPath(From, To)
-	f.pathfinder = finder //FIXME(pathfind): remove after debug
+	if !f.deciding {
+		f.Pathfinder = finder //FIXME(pathfind): remove after debug
+	}
 	return path
 }
 
 // terrain api
 // makePassableField makes everything but border passable
-func (f *Field) PlaceObject(coord CellCoord, o Object) {
-	f.CellAt(coord).Object = o
+func (f *Field) PlaceObject(coord geom.CellCoord, o Object) {
+	f.mutableCell(coord).Object = o
+}
+
+// mutableCell returns cell which can be changed without touching snapshots sharing cells
+// with field
+func (f *Field) mutableCell(c geom.CellCoord) *Cell {
+	if f.cellsShared {
+		f.Cells = append([]Cell(nil), f.Cells...)
+		f.cellsShared = false
+	}
+	return f.CellAt(c)
 }
 
 func (f *Field) makePassableField() {
 	for i := 1; i < f.XSize-1; i++ {
 		for j := 1; j < f.YSize-1; j++ {
 			// empty cells are passable and now there are only of that kind
-			f.CellAt(CellCoord{i, j}).Passable = true
+			f.CellAt(geom.CellCoord{X: i, Y: j}).Passable = true
 		}
 	}
 }
@@ -460,7 +615,7 @@ func (f *Field) computeSlopes() {
 	for i := 0; i < f.XSize-1; i++ {
 		for j := 0; j < f.YSize-1; j++ {
 			// compare cell with right and down neighbours
-			Coord := CellCoord{i, j}
+			Coord := geom.CellCoord{X: i, Y: j}
 			cell := f.CellAt(Coord)
 			right := f.CellAt(Coord.Add(1, 0))
 			down := f.CellAt(Coord.Add(0, 1))
@@ -483,7 +638,7 @@ func (f *Field) computeSlopes() {
 }
 
 type UnitPresence struct {
-	Coord UnitCoord
+	Coord geom.UnitCoord
 	Agent Agent
 	Unit  Unit
 }
@@ -494,6 +649,7 @@ type Cell struct {
 	Object
 }
 
+// RecieveDamage damages object in cell, which must be obtained with mutableCell
 func (c *Cell) RecieveDamage(f *Field, damage float32) {
 	c.Health -= damage
 	if c.Type == OBJECT_BUSH || c.Type == OBJECT_BARRICADE {
@@ -505,6 +661,7 @@ func (c *Cell) RecieveDamage(f *Field, damage float32) {
 }
 
 type FlyingGren struct {
-	From, To UnitCoord
+	From, To geom.UnitCoord
 	Booming  int8
+	Source   DamageSource
 }
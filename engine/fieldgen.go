@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+const (
+	TOTAL_DAMSELS     = 350
+	TOTAL_ZEDS        = 2
+	ZED_SPREAD_RADIUS = 4
+)
+
+func GenerateField(rules rules.Rules) *Field {
+	updates := make(chan *Field)
+	field := NewField(FIELD_SIZE, FIELD_SIZE, updates)
+
+	field.versus = rules.Versus
+	field.friendlyFire = !rules.NoFriendlyFire
+
+	qp := NewQuarterPlan(geom.CellCoord{X: 32, Y: 32})
+	qp.CreateQuarters(field)
+
+	return field
+}
+
+func findFreeCellInRange(field *Field, center geom.CellCoord, r float32) geom.CellCoord {
+	for {
+		cx := field.rng.Float32()*r*2 - r + float32(center.X)
+		cy := field.rng.Float32()*r*2 - r + float32(center.Y)
+		coord := geom.CellCoord{X: int(cx), Y: int(cy)}
+		if field.CellAt(coord).Passable {
+			return coord
+		}
+	}
+}
+
+func PopulateField(field *Field, rules rules.Rules) {
+	// zeds
+	var swarm Agent = &ZedSwarm{}
+
+	field.PlaceAgent(swarm)
+	for i := 0; i < TOTAL_ZEDS+rules.MoreZs; i++ {
+		field.PlaceUnit(
+			findFreeCellInRange(field, geom.CellCoord{X: 80, Y: 80}, ZED_SPREAD_RADIUS).UnitCenter(),
+			swarm, NewZed(field))
+	}
+
+	// damsels
+	var crowd Agent = &DamselCrowd{}
+	field.PlaceAgent(crowd)
+
+	// respect additional Bs
+	totalDamsels := TOTAL_DAMSELS + rules.MoreBs
+	for _, a := range field.Agents {
+		if _, ok := a.(*Squad); ok {
+			totalDamsels += rules.MoreBsP
+		}
+	}
+
+	for idx := 0; idx < totalDamsels; idx++ {
+		var Coord geom.UnitCoord
+		for {
+			Coord = geom.UnitCoord{X: field.rng.Float32()*150 + 1, Y: field.rng.Float32()*150 + 1}
+			if field.CellAt(Coord.Cell()).Passable {
+				break
+			}
+		}
+		dam := NewDamsel(field)
+		dam.WanderTarget = Coord
+		field.PlaceUnit(dam.WanderTarget, crowd, dam)
+	}
+}
+
+func PlaceSquad(field *Field, Id, Pid int, rules rules.Rules) chan Order {
+	var Orders = make(chan Order, SQUAD_ORDER_QUEUE_LEN)
+	var squad Agent = &Squad{Orders: Orders, Pid: Pid, FireState: ORDER_FIRE, Versus: rules.Versus}
+
+	var sold1 = NewSoldier(field)
+	var sold2 = NewSoldier(field)
+	var sold3 = NewSoldier(field)
+	var sold4 = NewSoldier(field)
+
+	var cx, cy int
+	if Id%2 == 1 {
+		cx = 150
+	}
+
+	if Id/2 == 1 {
+		cy = 150
+	}
+
+	field.PlaceAgent(squad)
+
+	field.PlaceUnit(findFreeCellNearby(field, geom.CellCoord{X: 1, Y: 1}.Add(cx, cy)).UnitCenter(),
+		squad, sold1)
+	field.PlaceUnit(findFreeCellNearby(field, geom.CellCoord{X: 3, Y: 1}.Add(cx, cy)).UnitCenter(),
+		squad, sold2)
+	field.PlaceUnit(findFreeCellNearby(field, geom.CellCoord{X: 1, Y: 3}.Add(cx, cy)).UnitCenter(),
+		squad, sold3)
+	field.PlaceUnit(findFreeCellNearby(field, geom.CellCoord{X: 3, Y: 3}.Add(cx, cy)).UnitCenter(),
+		squad, sold4)
+
+	return Orders
+}
+
+func findFreeCellNearby(field *Field, desiredCell geom.CellCoord) geom.CellCoord {
+	return desiredCell
+}
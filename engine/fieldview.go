@@ -1,14 +1,17 @@
-package main
+package engine
 
 import (
 	"sort"
+
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/pathfind"
 )
 
 type FieldView struct {
 	field *Field
 }
 
-func (f *FieldView) UnitsByDistance(src UnitCoord) []UnitPresence {
+func (f *FieldView) UnitsByDistance(src geom.UnitCoord) []UnitPresence {
 	presence := make([]UnitPresence, len(f.field.Units))
 	copy(presence, f.field.Units)
 	sorter := &unitsByDistance{src, presence}
@@ -16,7 +19,7 @@ func (f *FieldView) UnitsByDistance(src UnitCoord) []UnitPresence {
 	return sorter.Units
 }
 
-func (f *FieldView) UnitsInRange(src UnitCoord, r float32) []UnitPresence {
+func (f *FieldView) UnitsInRange(src geom.UnitCoord, r float32) []UnitPresence {
 	presence := make([]UnitPresence, 0)
 	for _, u := range f.field.Units {
 		if src.Distance(u.Coord) < r {
@@ -28,7 +31,7 @@ func (f *FieldView) UnitsInRange(src UnitCoord, r float32) []UnitPresence {
 	return sorter.Units
 }
 
-func (f *FieldView) UnitByID(Id int) (UnitCoord, Unit) {
+func (f *FieldView) UnitByID(Id int) (geom.UnitCoord, Unit) {
 	return f.field.UnitByID(Id)
 }
 
@@ -41,25 +44,34 @@ func (f *FieldView) ReplaceUnit(Id int, Agent Agent, u Unit) {
 	f.field.ReplaceUnit(Id, Coord, Agent, u)
 }
 
-func (f *FieldView) FindPath(From, To CellCoord) Path {
+func (f *FieldView) FindPath(From, To geom.CellCoord) pathfind.Path {
 	return f.field.FindPath(From, To)
 }
 
-func (f *FieldView) HaveLOS(From, To UnitCoord) int {
+func (f *FieldView) HaveLOS(From, To geom.UnitCoord) int {
 	return f.field.HaveLOS(From, To)
 }
 
-func (f *FieldView) HaveDirectPath(From, To UnitCoord) bool {
+func (f *FieldView) HaveDirectPath(From, To geom.UnitCoord) bool {
 	return f.field.HaveDirectPath(From, To)
 }
 
-func (f *FieldView) ThrowGren(From, To UnitCoord) {
-	f.field.ThrowGren(From, To)
+func (f *FieldView) ThrowGren(Thrower int, From, To geom.UnitCoord) {
+	f.field.ThrowGren(Thrower, From, To)
+}
+
+func (f *FieldView) DealDamage(src DamageSource, Id int, dmg float32) {
+	f.field.DealDamage(src, Id, dmg)
+}
+
+// Effect schedules changes of other units or the field made by unit Id, see decideUnits
+func (f *FieldView) Effect(Id int, fn func()) {
+	f.field.effect(Id, fn)
 }
 
 // unitsByDistance used to sort units on field, nearest to src first
 type unitsByDistance struct {
-	src   UnitCoord
+	src   geom.UnitCoord
 	Units []UnitPresence
 }
 
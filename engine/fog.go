@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+const (
+	SOL_VISION_RANGE    = 40
+	SOL_BUSH_SPOT_RANGE = 5
+)
+
+// ViewersFor returns soldiers whose eyes are used by player. In coop all squads share their
+// vision, in versus player sees only what own squad sees
+func ViewersFor(f *Field, pid int, versus bool) []geom.UnitCoord {
+	var eyes []geom.UnitCoord
+	for _, a := range f.Agents {
+		squad, ok := a.(*Squad)
+		if !ok || (versus && squad.Pid != pid) {
+			continue
+		}
+		for _, sol := range squad.Units {
+			coord, _ := f.UnitByID(sol.Id)
+			eyes = append(eyes, coord)
+		}
+	}
+	return eyes
+}
+
+// hadSquad returns true if player got squad in this round, even if it is dead by now
+func (f *Field) hadSquad(pid int) bool {
+	for _, p := range f.squadPids {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// CanSee returns true if unit or cell at target is visible from any of eyes. Things hidden
+// in bushes are only seen from close range
+func (f *Field) CanSee(eyes []geom.UnitCoord, target geom.UnitCoord) bool {
+	for _, eye := range eyes {
+		dist := eye.Distance(target)
+		if dist > SOL_VISION_RANGE {
+			continue
+		}
+		switch f.HaveLOS(eye, target) {
+		case VS_VISIBLE:
+			return true
+		case VS_ON_HORIZON:
+			if dist < SOL_BUSH_SPOT_RANGE {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fogField returns copy of field snapshot with units which player cannot see removed.
+// Unit ids are preserved, hidden slots are left empty. Spectators see everything, defeated
+// player in versus keeps fog of the lost squad and sees nothing
+func fogField(f *Field, pid int, versus bool) *Field {
+	eyes := ViewersFor(f, pid, versus)
+	if len(eyes) == 0 && !(versus && f.hadSquad(pid)) {
+		return f
+	}
+
+	ff := &Field{XSize: f.XSize, YSize: f.YSize, Cells: f.Cells, Fog: true}
+	ff.Units = make([]UnitPresence, len(f.Units))
+	for idx, up := range f.Units {
+		if squad, ok := up.Agent.(*Squad); ok && squad.Pid == pid {
+			// own units are always visible
+			ff.Units[idx] = up
+		} else if f.CanSee(eyes, up.Coord) {
+			ff.Units[idx] = up
+		}
+	}
+
+	// gren is at From while flying, thrower position must not leak through it
+	for _, g := range f.Grens {
+		switch {
+		case g.Source.Pid == pid || f.CanSee(eyes, g.From):
+			ff.Grens = append(ff.Grens, g)
+		case f.CanSee(eyes, g.To):
+			g.From = g.To
+			ff.Grens = append(ff.Grens, g)
+		}
+	}
+
+	ff.Agents = make([]Agent, len(f.Agents))
+	for idx, a := range f.Agents {
+		if squad, ok := a.(*Squad); ok && versus && squad.Pid != pid {
+			// do not leak enemy squad state
+			continue
+		}
+		ff.Agents[idx] = a
+	}
+	return ff
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+func TestFogVersus(t *testing.T) {
+	f := newCheckField(64)
+	for y := 1; y < 63; y++ {
+		f.wall(geom.CellCoord{X: 30, Y: y})
+	}
+	newCheckSquad(f, 0, geom.UnitCoord{X: 10.5, Y: 10.5})
+	enemy := newCheckSquad(f, 1, geom.UnitCoord{X: 40.5, Y: 10.5})
+	swarm := &checkAgent{}
+	f.PlaceUnit(geom.UnitCoord{X: 20.5, Y: 10.5}, swarm, NewZed(f))
+	f.PlaceUnit(geom.UnitCoord{X: 50.5, Y: 10.5}, swarm, NewZed(f))
+
+	ff := fogField(f, 0, true)
+	if ff.Units[0].Unit == nil {
+		t.Fatal("own soldier is hidden")
+	}
+	if ff.Units[1].Unit != nil {
+		t.Fatal("enemy behind wall is visible")
+	}
+	if ff.Units[2].Unit == nil {
+		t.Fatal("zed in the open is hidden")
+	}
+	if ff.Units[3].Unit != nil {
+		t.Fatal("zed behind wall is visible")
+	}
+	for _, a := range ff.Agents {
+		if a == Agent(enemy) {
+			t.Fatal("enemy squad is leaked")
+		}
+	}
+
+	// in coop vision is shared
+	ff = fogField(f, 0, false)
+	if ff.Units[3].Unit == nil {
+		t.Error("zed seen by ally is hidden in coop")
+	}
+}
+
+func TestFogBushes(t *testing.T) {
+	f := newCheckField(64)
+	f.PlaceObject(geom.CellCoord{X: 20, Y: 10}, referenceObjects[OBJECT_BUSH])
+	f.PlaceObject(geom.CellCoord{X: 13, Y: 20}, referenceObjects[OBJECT_BUSH])
+	newCheckSquad(f, 0, geom.UnitCoord{X: 10.5, Y: 10.5}, geom.UnitCoord{X: 10.5, Y: 20.5})
+	swarm := &checkAgent{}
+	f.PlaceUnit(geom.UnitCoord{X: 20.5, Y: 10.5}, swarm, NewZed(f))
+	f.PlaceUnit(geom.UnitCoord{X: 13.5, Y: 20.5}, swarm, NewZed(f))
+
+	ff := fogField(f, 0, true)
+	if ff.Units[2].Unit != nil {
+		t.Fatal("zed in far bush is visible")
+	}
+	if ff.Units[3].Unit == nil {
+		t.Error("zed in near bush is hidden")
+	}
+}
+
+func TestFogDefeated(t *testing.T) {
+	f := newCheckField(64)
+	loser := newCheckSquad(f, 0, geom.UnitCoord{X: 10.5, Y: 10.5})
+	newCheckSquad(f, 1, geom.UnitCoord{X: 40.5, Y: 10.5})
+	f.PlaceUnit(geom.UnitCoord{X: 12.5, Y: 10.5}, &checkAgent{}, NewZed(f))
+	f.DealDamage(DamageSource{-1, -1, DAMAGE_BITE}, loser.Units[0].Id, 1000)
+
+	ff := fogField(f, 0, true)
+	if ff.Units[1].Unit != nil || ff.Units[2].Unit != nil {
+		t.Fatal("defeated player sees the field in versus")
+	}
+	ff = fogField(f, 2, true)
+	if ff.Units[1].Unit == nil || ff.Units[2].Unit == nil {
+		t.Error("spectator does not see the field")
+	}
+}
+
+func TestFogGrens(t *testing.T) {
+	f := newCheckField(64)
+	for y := 1; y < 63; y++ {
+		f.wall(geom.CellCoord{X: 30, Y: y})
+	}
+	newCheckSquad(f, 0, geom.UnitCoord{X: 10.5, Y: 10.5})
+	thrower := newCheckSquad(f, 1, geom.UnitCoord{X: 40.5, Y: 10.5})
+	Id := thrower.Units[0].Id
+	f.ThrowGren(Id, geom.UnitCoord{X: 40.5, Y: 10.5}, geom.UnitCoord{X: 12.5, Y: 10.5})
+	f.ThrowGren(Id, geom.UnitCoord{X: 40.5, Y: 10.5}, geom.UnitCoord{X: 50.5, Y: 10.5})
+
+	ff := fogField(f, 0, true)
+	if len(ff.Grens) != 1 {
+		t.Fatalf("%d grens visible, want only one landing in sight", len(ff.Grens))
+	}
+	if ff.Grens[0].From != ff.Grens[0].To {
+		t.Errorf("hidden thrower at %s is leaked", ff.Grens[0].From)
+	}
+	if ff = fogField(f, 1, true); len(ff.Grens) != 2 || ff.Grens[0].From.X != 40.5 {
+		t.Errorf("thrower does not see own grens: %+v", ff.Grens)
+	}
+}
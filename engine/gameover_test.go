@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+// gameStates drains game states sent by field so far
+func (f *Field) gameStates() []GameState {
+	var states []GameState
+	for {
+		select {
+		case s := <-f.GameState:
+			states = append(states, s)
+		default:
+			return states
+		}
+	}
+}
+
+func TestGameOverCoop(t *testing.T) {
+	f := newCheckField(32)
+	newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5})
+	newCheckSquad(f, 1, geom.UnitCoord{X: 5.5, Y: 10.5})
+	crowd := &checkAgent{}
+	f.PlaceUnit(geom.UnitCoord{X: 20.5, Y: 5.5}, crowd, NewDamsel(f))
+	zed := NewZed(f)
+	f.PlaceUnit(geom.UnitCoord{X: 25.5, Y: 5.5}, crowd, zed)
+
+	f.checkGameOver()
+	if states := f.gameStates(); len(states) != 0 || f.gameOver {
+		t.Fatalf("game is over with zeds around: %v", states)
+	}
+
+	// dead zed rises again while its corpse is infected
+	f.killUnit(zed.Id, DamageSource{0, 0, DAMAGE_GUN})
+	corpse := f.Units[zed.Id].Unit.(*Corpse)
+	corpse.RessurectCounter = 10
+	f.checkGameOver()
+	if states := f.gameStates(); len(states) != 0 {
+		t.Fatalf("game is over with infected corpse: %v", states)
+	}
+
+	corpse.RessurectCounter = 0
+	f.checkGameOver()
+	want := fmt.Sprint([]GameState{{GAME_WIN, 0}, {GAME_WIN, 1}, {GAME_OVER, -1}})
+	if got := fmt.Sprint(f.gameStates()); got != want || !f.gameOver {
+		t.Fatalf("game states %s, want %s", got, want)
+	}
+
+	// with damsels gone too it is a draw
+	f = newCheckField(32)
+	newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5})
+	f.checkGameOver()
+	want = fmt.Sprint([]GameState{{GAME_DRAW, 0}, {GAME_OVER, -1}})
+	if got := fmt.Sprint(f.gameStates()); got != want {
+		t.Fatalf("game states %s, want %s", got, want)
+	}
+
+	// all soldiers dead is a loss for everybody
+	f = newCheckField(32)
+	squad := newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5})
+	f.PlaceUnit(geom.UnitCoord{X: 25.5, Y: 5.5}, &checkAgent{}, NewZed(f))
+	f.killUnit(squad.Units[0].Id, DamageSource{1, -1, DAMAGE_BITE})
+	f.checkGameOver()
+	want = fmt.Sprint([]GameState{{GAME_LOSE, 0}, {GAME_OVER, -1}})
+	got := fmt.Sprint(f.gameStates())
+	if got != want || !f.gameOver {
+		t.Errorf("game states %s, want %s", got, want)
+	}
+}
+
+func TestGameOverVersus(t *testing.T) {
+	f := newCheckField(32)
+	f.versus = true
+	newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5})
+	loser := newCheckSquad(f, 1, geom.UnitCoord{X: 25.5, Y: 5.5})
+	f.PlaceUnit(geom.UnitCoord{X: 20.5, Y: 20.5}, &checkAgent{}, NewDamsel(f))
+
+	// no zeds, but two squads are still standing
+	f.checkGameOver()
+	if states := f.gameStates(); len(states) != 0 || f.gameOver {
+		t.Fatalf("game is over with two squads: %v", states)
+	}
+
+	f.killUnit(loser.Units[0].Id, DamageSource{0, 0, DAMAGE_GUN})
+	f.checkGameOver()
+	want := fmt.Sprint([]GameState{{GAME_LOSE, 1}, {GAME_WIN, 0}, {GAME_OVER, -1}})
+	if got := fmt.Sprint(f.gameStates()); got != want || !f.gameOver {
+		t.Fatalf("game states %s, want %s", got, want)
+	}
+
+	// last squad standing without damsels gets a draw
+	f = newCheckField(32)
+	f.versus = true
+	newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5})
+	loser = newCheckSquad(f, 1, geom.UnitCoord{X: 25.5, Y: 5.5})
+	f.killUnit(loser.Units[0].Id, DamageSource{0, 0, DAMAGE_GUN})
+	f.checkGameOver()
+	want = fmt.Sprint([]GameState{{GAME_LOSE, 1}, {GAME_DRAW, 0}, {GAME_OVER, -1}})
+	got := fmt.Sprint(f.gameStates())
+	if got != want {
+		t.Errorf("game states %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package engine
+
+// Health is shared by all living units. Unit can die only once, all following damage is ignored
+type Health struct {
+	HP   float32
+	Dead bool
+}
+
+func NewHealth(hp float32) Health {
+	return Health{HP: hp}
+}
+
+// Hurt applies damage and returns true if that damage was deadly
+func (h *Health) Hurt(dmg float32) bool {
+	if h.Dead {
+		return false
+	}
+	h.HP -= dmg
+	if h.HP < 0 {
+		h.Dead = true
+		return true
+	}
+	return false
+}
+
+func (h *Health) Heal(hp float32) {
+	if !h.Dead {
+		h.HP += hp
+	}
+}
+
+// Die marks unit as dead without any damage, i.e. from starvation
+func (h *Health) Die() bool {
+	if h.Dead {
+		return false
+	}
+	h.Dead = true
+	return true
+}
+
+func (h *Health) IsDead() bool {
+	return h.Dead
+}
+
+// DeathWatcher is implemented by agents which want to know when their units die
+type DeathWatcher interface {
+	HandleDeath(f *FieldView, u Unit, src DamageSource)
+}
+
+// killUnit is the only way for unit to die. It records the death, turns unit into a corpse and
+// notifies unit's agent
+func (f *Field) killUnit(Id int, src DamageSource) {
+	up := f.Units[Id]
+	if _, ok := up.Unit.(*Corpse); ok {
+		// already dead
+		return
+	}
+
+	f.makeCorpse(Id)
+	if watcher, ok := up.Agent.(DeathWatcher); ok {
+		watcher.HandleDeath(&FieldView{f}, up.Unit, src)
+	}
+}
@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+func newCheckUnit(f *Field, kind int) Unit {
+	switch kind {
+	case KIND_SOLDIER:
+		return NewSoldier(f)
+	case KIND_DAMSEL:
+		return NewDamsel(f)
+	case KIND_ZED:
+		return NewZed(f)
+	}
+	panic("unknown unit kind")
+}
+
+func TestDeath(t *testing.T) {
+	// starvation applies only to zeds
+	tests := []struct {
+		kind  int
+		cause int
+		hits  int // hits needed to kill, zero for single shot deaths like starvation
+	}{
+		{KIND_SOLDIER, DAMAGE_GUN, 11},
+		{KIND_DAMSEL, DAMAGE_GUN, 8},
+		{KIND_ZED, DAMAGE_GUN, 15},
+		{KIND_SOLDIER, DAMAGE_BITE, 3},
+		{KIND_DAMSEL, DAMAGE_BITE, 2},
+		{KIND_ZED, DAMAGE_BITE, 4},
+		{KIND_SOLDIER, DAMAGE_GREN, 2},
+		{KIND_DAMSEL, DAMAGE_GREN, 1},
+		{KIND_ZED, DAMAGE_GREN, 2},
+		{KIND_ZED, DAMAGE_STARVATION, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(unitKindNames[tt.kind]+"/"+damageCauseNames[tt.cause], func(t *testing.T) {
+			f := newCheckField(32)
+			agent := &checkAgent{}
+			victimCoord := geom.UnitCoord{X: 10.5, Y: 10.5}
+			f.PlaceUnit(victimCoord, agent, newCheckUnit(f, tt.kind))
+			victim := agent.units[0]
+
+			var hits int
+			switch tt.cause {
+			case DAMAGE_GUN:
+				shooter := NewSoldier(f)
+				f.PlaceUnit(geom.UnitCoord{X: 13.5, Y: 10.5}, &checkAgent{}, shooter)
+				for hits < 100 && !victim.IsDead() {
+					shooter.Shoot(geom.UnitCoord{X: 13.5, Y: 10.5}, victimCoord, victim)
+					hits++
+				}
+			case DAMAGE_BITE:
+				biter := NewZed(f)
+				f.PlaceUnit(geom.UnitCoord{X: 11.0, Y: 10.5}, &checkAgent{}, biter)
+				for hits < 100 && !victim.IsDead() {
+					biter.Bite(geom.UnitCoord{X: 11.0, Y: 10.5}, victimCoord, victim)
+					biter.Rage = 0
+					hits++
+				}
+			case DAMAGE_GREN:
+				thrower := NewSoldier(f)
+				f.PlaceUnit(geom.UnitCoord{X: 25.5, Y: 25.5}, &checkAgent{}, thrower)
+				// all grens explode in the same tick, so victim is hit after death too
+				for i := 0; i < tt.hits+1; i++ {
+					f.ThrowGren(thrower.Id, victimCoord, victimCoord)
+				}
+				f.runTicks(1)
+				hits = tt.hits
+			case DAMAGE_STARVATION:
+				zed := victim.(*Zed)
+				zed.Nutrition = -1
+				zed.Digest()
+			}
+
+			if !victim.IsDead() {
+				t.Fatalf("victim is alive after %d hits", hits)
+			}
+			if hits != tt.hits {
+				t.Errorf("victim died after %d hits, want %d", hits, tt.hits)
+			}
+
+			// hit it once more, nothing should change
+			f.DealDamage(DamageSource{-1, -1, tt.cause}, victim.GetID(), 1000)
+
+			_, unit := f.UnitByID(victim.GetID())
+			if _, ok := unit.(*Corpse); !ok {
+				t.Errorf("victim is not replaced with corpse: %T", unit)
+			}
+
+			var kills int
+			for _, r := range f.ledger.Kills() {
+				if r.Victim == victim.GetID() {
+					kills++
+					if r.Cause != tt.cause || r.VictimKind != tt.kind {
+						t.Errorf("wrong kill record: %+v", r)
+					}
+				}
+			}
+			if kills != 1 {
+				t.Errorf("%d kills recorded, want 1", kills)
+			}
+			if len(agent.deaths) != 1 {
+				t.Fatalf("death hook called %d times, want 1", len(agent.deaths))
+			}
+			if agent.deaths[0].Cause != tt.cause {
+				t.Errorf("death hook got cause %d, want %d", agent.deaths[0].Cause, tt.cause)
+			}
+		})
+	}
+}
+
+func TestSoldierSingleDamage(t *testing.T) {
+	f := newCheckField(32)
+	sol := NewSoldier(f)
+	f.PlaceUnit(geom.UnitCoord{X: 10.5, Y: 10.5}, &checkAgent{}, sol)
+	f.DealDamage(DamageSource{-1, -1, DAMAGE_GUN}, sol.Id, SOL_GUN_DAMAGE)
+	if sol.HP != SOL_BASE_HEALTH-SOL_GUN_DAMAGE {
+		t.Errorf("soldier have %.1f hp, want %d", sol.HP, SOL_BASE_HEALTH-SOL_GUN_DAMAGE)
+	}
+}
+
+func TestRageBite(t *testing.T) {
+	f := newCheckField(32)
+	sol := NewSoldier(f)
+	f.PlaceUnit(geom.UnitCoord{X: 10.5, Y: 10.5}, &checkAgent{}, sol)
+	zed := NewZed(f)
+	f.PlaceUnit(geom.UnitCoord{X: 11.0, Y: 10.5}, &checkAgent{}, zed)
+	zed.Rage = 10
+
+	zed.Bite(geom.UnitCoord{X: 11.0, Y: 10.5}, geom.UnitCoord{X: 10.5, Y: 10.5}, sol)
+	var want float32 = SOL_BASE_HEALTH - (ZED_BITE_DAMAGE + 10*ZED_RAGE_BITEUP)
+	if geom.Fabs(sol.HP-want) >= geom.FLOAT_ERROR {
+		t.Errorf("soldier have %.1f hp, want %.1f", sol.HP, want)
+	}
+}
+
+func TestSuicideNoFriendlyFire(t *testing.T) {
+	for _, versus := range []bool{false, true} {
+		f := newCheckField(32)
+		f.friendlyFire = false
+		f.versus = versus
+		squad := newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5}, geom.UnitCoord{X: 6.5, Y: 5.5})
+		units := append([]*Soldier(nil), squad.Units...)
+		squad.Orders <- Order{Order: ORDER_SUICIDE}
+		f.runTicks(1)
+
+		for _, sol := range units {
+			if _, unit := f.UnitByID(sol.Id); !sol.IsDead() {
+				t.Errorf("versus %v: soldier %d survived suicide as %T", versus, sol.Id, unit)
+			} else if _, ok := unit.(*Corpse); !ok {
+				t.Errorf("versus %v: soldier %d is not replaced with corpse: %T", versus, sol.Id, unit)
+			}
+		}
+	}
+}
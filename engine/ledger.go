@@ -0,0 +1,211 @@
+package engine
+
+const (
+	DAMAGE_GUN = iota
+	DAMAGE_GREN
+	DAMAGE_BITE
+	DAMAGE_STARVATION
+	DAMAGE_SUICIDE
+)
+
+const (
+	KIND_NONE = iota
+	KIND_SOLDIER
+	KIND_DAMSEL
+	KIND_ZED
+	KIND_CORPSE
+)
+
+var damageCauseNames = []string{
+	DAMAGE_GUN:        "gun",
+	DAMAGE_GREN:       "grenade",
+	DAMAGE_BITE:       "bite",
+	DAMAGE_STARVATION: "starvation",
+	DAMAGE_SUICIDE:    "suicide",
+}
+
+var unitKindNames = []string{
+	KIND_NONE:    "none",
+	KIND_SOLDIER: "soldier",
+	KIND_DAMSEL:  "damsel",
+	KIND_ZED:     "zed",
+	KIND_CORPSE:  "corpse",
+}
+
+// DamageSource describes who is dealing damage and how
+type DamageSource struct {
+	Unit  int // unit id of attacker, -1 for nobody
+	Pid   int // player owning attacker, -1 for none
+	Cause int
+}
+
+// DamageRecord is a single ledger entry
+type DamageRecord struct {
+	Tick       int64
+	Attacker   int
+	Pid        int
+	Victim     int
+	VictimKind int
+	VictimPid  int
+	Cause      int
+	Damage     float32
+	Kill       bool
+	Friendly   bool
+}
+
+// FireCounters tracks weapon usage of single player
+type FireCounters struct {
+	Shots, Hits, Grens int
+}
+
+// DamageLedger keeps track of all damage dealt during the round
+type DamageLedger struct {
+	Records []DamageRecord
+	Fire    map[int]*FireCounters
+}
+
+func (l *DamageLedger) Add(r DamageRecord) {
+	l.Records = append(l.Records, r)
+	if r.Cause == DAMAGE_GUN && r.Pid >= 0 {
+		l.Counters(r.Pid).Hits++
+	}
+}
+
+// Counters returns weapon counters for player, creating them if needed
+func (l *DamageLedger) Counters(Pid int) *FireCounters {
+	if l.Fire == nil {
+		l.Fire = make(map[int]*FireCounters)
+	}
+	c, ok := l.Fire[Pid]
+	if !ok {
+		c = &FireCounters{}
+		l.Fire[Pid] = c
+	}
+	return c
+}
+
+// Copy returns ledger copy which can be safely passed to other goroutines
+func (l *DamageLedger) Copy() DamageLedger {
+	c := DamageLedger{Records: append([]DamageRecord(nil), l.Records...),
+		Fire: make(map[int]*FireCounters, len(l.Fire))}
+	for Pid, counters := range l.Fire {
+		fc := *counters
+		c.Fire[Pid] = &fc
+	}
+	return c
+}
+
+// Kills returns kill records only
+func (l *DamageLedger) Kills() []DamageRecord {
+	var kills []DamageRecord
+	for _, r := range l.Records {
+		if r.Kill {
+			kills = append(kills, r)
+		}
+	}
+	return kills
+}
+
+// KillsBy counts kills of given unit kind made by player
+func (l *DamageLedger) KillsBy(Pid, kind int) int {
+	var count int
+	for _, r := range l.Records {
+		if r.Kill && r.Pid == Pid && r.VictimKind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// LossesOf counts killed units of given kind owned by player
+func (l *DamageLedger) LossesOf(Pid, kind int) int {
+	var count int
+	for _, r := range l.Records {
+		if r.Kill && r.VictimPid == Pid && r.VictimKind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func UnitKind(u Unit) int {
+	switch u.(type) {
+	case *Soldier:
+		return KIND_SOLDIER
+	case *Damsel:
+		return KIND_DAMSEL
+	case *Zed:
+		return KIND_ZED
+	case *Corpse:
+		return KIND_CORPSE
+	}
+	return KIND_NONE
+}
+
+// sourceOf resolves owning player of unit at the moment of attack
+func (f *Field) sourceOf(Id, cause int) DamageSource {
+	return DamageSource{Id, f.pidOf(Id), cause}
+}
+
+func (f *Field) pidOf(Id int) int {
+	if Id < 0 || Id >= len(f.Units) {
+		return -1
+	}
+	if squad, ok := f.Units[Id].Agent.(*Squad); ok {
+		return squad.Pid
+	}
+	return -1
+}
+
+// isFriendly returns true if damage from src to victim hits an ally
+func (f *Field) isFriendly(src DamageSource, victimPid int) bool {
+	if src.Pid < 0 || victimPid < 0 {
+		return false
+	}
+	if f.versus {
+		return src.Pid == victimPid
+	}
+	// in coop all squads are on the same side
+	return true
+}
+
+// DealDamage applies damage to unit and records it in the ledger
+func (f *Field) DealDamage(src DamageSource, Id int, dmg float32) {
+	victim := f.Units[Id].Unit
+	if victim.IsDead() {
+		return
+	}
+	kind := UnitKind(victim)
+
+	victimPid := f.pidOf(Id)
+	friendly := kind == KIND_SOLDIER && f.isFriendly(src, victimPid)
+	// suicide is not fire, squad can blow itself up with friendly fire off too
+	if friendly && !f.friendlyFire && src.Cause != DAMAGE_SUICIDE {
+		return
+	}
+
+	victim.RecieveDamage(src.Unit, dmg)
+
+	dead := victim.IsDead()
+	f.ledger.Add(DamageRecord{f.tick, src.Unit, src.Pid, Id, kind, victimPid, src.Cause, dmg,
+		dead, friendly})
+	if dead {
+		f.killUnit(Id, src)
+	}
+}
+
+// StarveMe registers death which is not caused by damage and turns unit into corpse
+func (f *Field) StarveMe(Id int) {
+	src := DamageSource{-1, -1, DAMAGE_STARVATION}
+	f.ledger.Add(DamageRecord{f.tick, -1, -1, Id, UnitKind(f.Units[Id].Unit), f.pidOf(Id),
+		src.Cause, 0, true, false})
+	f.killUnit(Id, src)
+}
+
+// RoundSummary is produced by field when round is over
+type RoundSummary struct {
+	Ledger    DamageLedger
+	Damsels   int
+	Players   []int
+	Survivors []int
+}
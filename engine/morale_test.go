@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+func TestSquadmateDeath(t *testing.T) {
+	f := newCheckField(32)
+	squad := newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5}, geom.UnitCoord{X: 20.5, Y: 20.5})
+	survivor := squad.Units[1]
+
+	f.DealDamage(DamageSource{-1, -1, DAMAGE_GUN}, squad.Units[0].Id, 1000)
+	if survivor.Morale != SOL_MORALE_MAX-SOL_MORALE_SQUADMATE_DIE {
+		t.Errorf("survivor morale is %.1f, want %d", survivor.Morale,
+			SOL_MORALE_MAX-SOL_MORALE_SQUADMATE_DIE)
+	}
+}
+
+func TestPanicFlee(t *testing.T) {
+	f := newCheckField(32)
+	squad := newCheckSquad(f, 0, geom.UnitCoord{X: 10.5, Y: 10.5})
+	sol := squad.Units[0]
+	sol.Morale = 0
+	f.PlaceUnit(geom.UnitCoord{X: 12.5, Y: 10.5}, &checkAgent{}, NewZed(f))
+
+	f.runTicks(5)
+	coord, _ := f.UnitByID(sol.Id)
+	if coord.X >= 10.5 {
+		t.Errorf("panicking soldier at %s did not flee from zed", coord)
+	}
+}
+
+func TestSquadHold(t *testing.T) {
+	f := newCheckField(32)
+	squad := newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5})
+	squad.Orders <- Order{Order: ORDER_MOVE, Coord: geom.CellCoord{X: 25, Y: 5}}
+	f.runTicks(20)
+
+	squad.Orders <- Order{Order: ORDER_HOLD, Coord: geom.CellCoord{}}
+	f.runTicks(1)
+	held, _ := f.UnitByID(squad.Units[0].Id)
+	f.runTicks(50)
+	now, _ := f.UnitByID(squad.Units[0].Id)
+	if now.Distance(held) >= 1 {
+		t.Errorf("squad walked from %s to %s while holding", held, now)
+	}
+}
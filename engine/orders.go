@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+const (
+	ORDER_MOVE = iota
+	ORDER_AUTOMOVE
+	ORDER_FIRE
+	ORDER_NOFIRE
+	ORDER_SEMIFIRE
+	ORDER_GREN
+	ORDER_SUICIDE
+	ORDER_HOLD
+)
+
+type Order struct {
+	Order int
+	Coord geom.CellCoord
+}
+
+// Valid returns true if order is known and its coord lies on field of given size
+func (o Order) Valid(XSize, YSize int) bool {
+	return o.Order >= ORDER_MOVE && o.Order <= ORDER_HOLD &&
+		o.Coord.X >= 0 && o.Coord.X < XSize && o.Coord.Y >= 0 && o.Coord.Y < YSize
+}
+
+func ToggleFireState(fs int) int {
+	switch fs {
+	case ORDER_FIRE:
+		return ORDER_SEMIFIRE
+	case ORDER_SEMIFIRE:
+		return ORDER_FIRE
+	default:
+		return ORDER_FIRE
+	}
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/pathfind"
+)
+
+func TestPassability(t *testing.T) {
+	f := newCheckField(32)
+	f.wall(geom.CellCoord{X: 10, Y: 10}, geom.CellCoord{X: 21, Y: 10}, geom.CellCoord{X: 20, Y: 11})
+	f.CellAt(geom.CellCoord{X: 5, Y: 20}).Elevation = 3
+	f.CellAt(geom.CellCoord{X: 6, Y: 20}).Elevation = 1
+
+	cases := []struct {
+		src, dst geom.CellCoord
+		want     Passability
+	}{
+		{geom.CellCoord{X: 9, Y: 10}, geom.CellCoord{X: 10, Y: 10}, PS_IMPASSABLE},
+		{geom.CellCoord{X: 9, Y: 10}, geom.CellCoord{X: 9, Y: 11}, PS_PASSABLE},
+		// diagonal move cannot cut corner of a wall
+		{geom.CellCoord{X: 9, Y: 11}, geom.CellCoord{X: 10, Y: 12}, PS_PASSABLE},
+		{geom.CellCoord{X: 9, Y: 11}, geom.CellCoord{X: 10, Y: 10}, PS_IMPASSABLE},
+		{geom.CellCoord{X: 9, Y: 9}, geom.CellCoord{X: 10, Y: 10}, PS_IMPASSABLE},
+		{geom.CellCoord{X: 11, Y: 11}, geom.CellCoord{X: 10, Y: 12}, PS_PASSABLE},
+		{geom.CellCoord{X: 9, Y: 9}, geom.CellCoord{X: 10, Y: 8}, PS_PASSABLE},
+		{geom.CellCoord{X: 11, Y: 9}, geom.CellCoord{X: 10, Y: 8}, PS_PASSABLE},
+		{geom.CellCoord{X: 9, Y: 11}, geom.CellCoord{X: 10, Y: 10}, PS_IMPASSABLE},
+		{geom.CellCoord{X: 11, Y: 11}, geom.CellCoord{X: 10, Y: 10}, PS_IMPASSABLE},
+		// nor squeeze between two
+		{geom.CellCoord{X: 20, Y: 10}, geom.CellCoord{X: 21, Y: 11}, PS_IMPASSABLE},
+		{geom.CellCoord{X: 21, Y: 11}, geom.CellCoord{X: 20, Y: 10}, PS_IMPASSABLE},
+		// steep climbs are not allowed, small steps are
+		{geom.CellCoord{X: 4, Y: 20}, geom.CellCoord{X: 5, Y: 20}, PS_IMPASSABLE},
+		{geom.CellCoord{X: 5, Y: 20}, geom.CellCoord{X: 4, Y: 20}, PS_IMPASSABLE},
+		{geom.CellCoord{X: 5, Y: 20}, geom.CellCoord{X: 6, Y: 20}, PS_PASSABLE},
+		{geom.CellCoord{X: 7, Y: 20}, geom.CellCoord{X: 6, Y: 20}, PS_PASSABLE},
+	}
+	for _, c := range cases {
+		if got := f.CheckPassability(c.src, c.dst); got != c.want {
+			t.Fatalf("passability %s -> %s is %d, want %d", c.src, c.dst, got, c.want)
+		}
+	}
+}
+
+func TestLineOfSight(t *testing.T) {
+	f := newCheckField(32)
+	f.wall(geom.CellCoord{X: 10, Y: 5})
+	f.PlaceObject(geom.CellCoord{X: 20, Y: 20}, referenceObjects[OBJECT_BUSH])
+
+	cases := []struct {
+		from, to geom.UnitCoord
+		want     int
+	}{
+		{geom.UnitCoord{X: 5.5, Y: 10.5}, geom.UnitCoord{X: 15.5, Y: 10.5}, VS_VISIBLE},
+		{geom.UnitCoord{X: 5.5, Y: 10.5}, geom.UnitCoord{X: 15.5, Y: 15.5}, VS_VISIBLE},
+		{geom.UnitCoord{X: 5.5, Y: 5.5}, geom.UnitCoord{X: 15.5, Y: 5.5}, VS_INVISIBLE},
+		{geom.UnitCoord{X: 15.5, Y: 5.5}, geom.UnitCoord{X: 5.5, Y: 5.5}, VS_INVISIBLE},
+		// wall itself is seen, but nothing behind it
+		{geom.UnitCoord{X: 5.5, Y: 5.5}, geom.UnitCoord{X: 10.5, Y: 5.5}, VS_ON_HORIZON},
+		// unit in bush is on horizon and can look out of it
+		{geom.UnitCoord{X: 15.5, Y: 20.5}, geom.UnitCoord{X: 20.5, Y: 20.5}, VS_ON_HORIZON},
+		{geom.UnitCoord{X: 20.5, Y: 20.5}, geom.UnitCoord{X: 15.5, Y: 20.5}, VS_VISIBLE},
+		{geom.UnitCoord{X: 15.5, Y: 20.5}, geom.UnitCoord{X: 25.5, Y: 20.5}, VS_INVISIBLE},
+	}
+	for _, c := range cases {
+		if got := f.HaveLOS(c.from, c.to); got != c.want {
+			t.Fatalf("visibility %s -> %s is %d, want %d", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestDirectPath(t *testing.T) {
+	f := newCheckField(32)
+	f.wall(geom.CellCoord{X: 10, Y: 5})
+	f.PlaceObject(geom.CellCoord{X: 20, Y: 20}, referenceObjects[OBJECT_BUSH])
+
+	cases := []struct {
+		from, to geom.UnitCoord
+		want     bool
+	}{
+		{geom.UnitCoord{X: 5.5, Y: 10.5}, geom.UnitCoord{X: 15.5, Y: 10.5}, true},
+		{geom.UnitCoord{X: 5.5, Y: 10.5}, geom.UnitCoord{X: 15.5, Y: 20.5}, true},
+		{geom.UnitCoord{X: 5.5, Y: 5.5}, geom.UnitCoord{X: 15.5, Y: 5.5}, false},
+		{geom.UnitCoord{X: 5.5, Y: 5.5}, geom.UnitCoord{X: 10.5, Y: 5.5}, false},
+		// bushes block sight but not walking
+		{geom.UnitCoord{X: 15.5, Y: 20.5}, geom.UnitCoord{X: 25.5, Y: 20.5}, true},
+	}
+	for _, c := range cases {
+		if got := f.HaveDirectPath(c.from, c.to); got != c.want {
+			t.Fatalf("direct path %s -> %s is %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestPathFinder(t *testing.T) {
+	f := newCheckField(32)
+	// wall with a single gap, which is open only diagonally
+	for y := 1; y < 31; y++ {
+		if y != 20 {
+			f.wall(geom.CellCoord{X: 15, Y: y})
+		}
+	}
+	from, to := geom.CellCoord{X: 5, Y: 5}, geom.CellCoord{X: 25, Y: 5}
+
+	path := pathfind.NewPathFinder(f).FindPath(from, to)
+	if len(path) == 0 {
+		t.Fatalf("no path through the gap")
+	}
+	// path is reversed, first step is the last
+	if path[0] != to {
+		t.Fatalf("path ends at %s, want %s", path[0], to)
+	}
+	prev, gap := from, false
+	for idx := len(path) - 1; idx >= 0; idx-- {
+		step := path[idx]
+		if prev.Distance(step) > 1.5 || f.CheckPassability(prev, step) != PS_PASSABLE {
+			t.Fatalf("path step %s -> %s is not passable", prev, step)
+		}
+		gap = gap || step == geom.CellCoord{X: 15, Y: 20}
+		prev = step
+	}
+	if !gap {
+		t.Fatalf("path %v does not go through the gap", path)
+	}
+	// walk around the wall is 2*15 cells down and back plus 20 across
+	if len(path) > 50 {
+		t.Fatalf("path of %d steps is too long", len(path))
+	}
+
+	f.wall(geom.CellCoord{X: 15, Y: 20})
+	path = pathfind.NewPathFinder(f).FindPath(from, to)
+	if path != nil {
+		t.Errorf("path found through solid wall: %v", path)
+	}
+}
+
+func TestWalkerStuck(t *testing.T) {
+	f := newCheckField(32)
+	f.wall(geom.CellCoord{X: 10, Y: 10}, geom.CellCoord{X: 11, Y: 11})
+	w := NewSoldier(f).Walker
+
+	// free walk is not stuck and goes at full speed
+	src := geom.UnitCoord{X: 5.5, Y: 5.5}
+	next, stuck := w.MoveToward(f, src, geom.UnitCoord{X: 20.5, Y: 5.5})
+	if stuck || next != (geom.UnitCoord{X: 5.5 + SOL_MOVER_WALK, Y: 5.5}) {
+		t.Fatalf("free walk to %s, stuck %v", next, stuck)
+	}
+
+	// wall ahead stops walker where it is
+	src = geom.UnitCoord{X: 9.9, Y: 10.5}
+	next, stuck = w.MoveToward(f, src, geom.UnitCoord{X: 15.5, Y: 10.5})
+	if !stuck || next != src {
+		t.Fatalf("walker before wall moved to %s, stuck %v", next, stuck)
+	}
+
+	// squeezing between diagonal walls is not allowed either
+	src = geom.UnitCoord{X: 10.9, Y: 11.1}
+	next, stuck = w.MoveToward(f, src, geom.UnitCoord{X: 12.9, Y: 9.1})
+	if !stuck || next.Cell() != src.Cell() {
+		t.Fatalf("walker squeezed between walls to %s, stuck %v", next, stuck)
+	}
+
+	// walker which can enter transit cell but not the next one stops on the edge of it
+	src = geom.UnitCoord{X: 9.95, Y: 9.8}
+	next, stuck = w.MoveToward(f, src, geom.UnitCoord{X: 14.95, Y: 12.3})
+	if !stuck || next.Cell() == (geom.CellCoord{X: 10, Y: 10}) {
+		t.Fatalf("walker crossed corner of the wall to %s, stuck %v", next, stuck)
+	}
+
+	// soldier walking into wall ends up next to it and does not pass
+	squad := newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 10.5})
+	squad.Orders <- Order{ORDER_MOVE, geom.CellCoord{X: 25, Y: 10}}
+	f.runTicks(100)
+	coord, _ := f.UnitByID(squad.Units[0].Id)
+	if coord.Cell() != (geom.CellCoord{X: 25, Y: 10}) {
+		t.Errorf("soldier did not walk around wall, at %s",
+			coord)
+	}
+}
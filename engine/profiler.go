@@ -0,0 +1,217 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mechmind/life-goes-on/logging"
+	"github.com/mechmind/life-goes-on/metrics"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+const (
+	// tick must be done before the next one is due
+	TICK_BUDGET = time.Second / TIME_TICKS_PER_SEC
+	// slow ticks are reported at most once per interval, the rest are only counted
+	TICK_WARN_INTERVAL = 10 * time.Second
+)
+
+var metricSlowTicks = metrics.NewCounter("lgo_slow_ticks_total",
+	"Ticks which took longer than tick budget.")
+
+// PhaseStats is accumulated time of one tick phase
+type PhaseStats struct {
+	Phase string
+	Total time.Duration
+	Max   time.Duration // in single tick
+	Ticks int64         // ticks with that phase
+}
+
+func (p PhaseStats) Avg() time.Duration {
+	if p.Ticks == 0 {
+		return 0
+	}
+	return p.Total / time.Duration(p.Ticks)
+}
+
+// TickProfiler measures phases of field tick: thinking and unit handling per agent type,
+// grenades, game over check and snapshot copy. All methods do nothing on nil profiler, so
+// fields without one are not slowed down
+type TickProfiler struct {
+	Log    *logging.Logger
+	budget time.Duration
+
+	// touched only by ticking goroutine
+	start, last time.Time
+	current     map[string]time.Duration
+	lastWarn    time.Time
+	agentPhases map[agentPhase]string
+
+	lock      sync.Mutex
+	phases    map[string]*PhaseStats
+	ticks     int64
+	slowTicks int64
+}
+
+func NewTickProfiler(log *logging.Logger) *TickProfiler {
+	return &TickProfiler{Log: log, budget: TICK_BUDGET, current: make(map[string]time.Duration),
+		agentPhases: make(map[agentPhase]string), phases: make(map[string]*PhaseStats)}
+}
+
+type agentPhase struct {
+	stage string
+	agent reflect.Type
+}
+
+// agentName returns type name of agent, i.e. Squad or ZedSwarm
+func agentName(a Agent) string {
+	t := reflect.TypeOf(a)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+func (p *TickProfiler) begin() {
+	if p == nil {
+		return
+	}
+	p.start = time.Now()
+	p.last = p.start
+}
+
+// mark adds time since previous mark to phase
+func (p *TickProfiler) mark(phase string) {
+	if p == nil {
+		return
+	}
+	now := time.Now()
+	p.current[phase] += now.Sub(p.last)
+	p.last = now
+}
+
+// markAgent adds time since previous mark to stage of agent type, i.e. think/Squad
+func (p *TickProfiler) markAgent(stage string, a Agent) {
+	if p == nil {
+		return
+	}
+	key := agentPhase{stage, reflect.TypeOf(a)}
+	phase, ok := p.agentPhases[key]
+	if !ok {
+		phase = stage + "/" + agentName(a)
+		p.agentPhases[key] = phase
+	}
+	p.mark(phase)
+}
+
+func (p *TickProfiler) end(tick int64) {
+	if p == nil {
+		return
+	}
+	spent := p.last.Sub(p.start)
+
+	p.lock.Lock()
+	p.ticks++
+	var slowest string
+	for phase, d := range p.current {
+		stats, ok := p.phases[phase]
+		if !ok {
+			stats = &PhaseStats{Phase: phase}
+			p.phases[phase] = stats
+		}
+		stats.Total += d
+		stats.Ticks++
+		if d > stats.Max {
+			stats.Max = d
+		}
+		if slowest == "" || d > p.current[slowest] {
+			slowest = phase
+		}
+	}
+	slow := spent > p.budget
+	if slow {
+		p.slowTicks++
+	}
+	p.lock.Unlock()
+
+	if slow {
+		metricSlowTicks.Inc()
+		if p.start.Sub(p.lastWarn) >= TICK_WARN_INTERVAL {
+			p.lastWarn = p.start
+			p.Log.With("tick", tick).Warnf("tick took %s, over budget of %s, slowest phase %s took %s",
+				spent, p.budget, slowest, p.current[slowest])
+		}
+	}
+	for phase := range p.current {
+		delete(p.current, phase)
+	}
+}
+
+// TickProfile is a copy of profiler data
+type TickProfile struct {
+	Ticks     int64
+	SlowTicks int64
+	Budget    time.Duration
+	Phases    []PhaseStats // most expensive first
+}
+
+func (p *TickProfiler) Profile() TickProfile {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	profile := TickProfile{Ticks: p.ticks, SlowTicks: p.slowTicks, Budget: p.budget}
+	for _, stats := range p.phases {
+		profile.Phases = append(profile.Phases, *stats)
+	}
+	sort.Slice(profile.Phases, func(i, j int) bool {
+		return profile.Phases[i].Total > profile.Phases[j].Total
+	})
+	return profile
+}
+
+// WriteReport writes profile as a table
+func (tp TickProfile) WriteReport(w io.Writer) {
+	var total time.Duration
+	for _, phase := range tp.Phases {
+		total += phase.Total
+	}
+	fmt.Fprintf(w, "%d ticks, %d over budget of %s\n", tp.Ticks, tp.SlowTicks, tp.Budget)
+	if tp.Ticks > 0 {
+		fmt.Fprintf(w, "average tick %s\n", total/time.Duration(tp.Ticks))
+	}
+	fmt.Fprintf(w, "%-24s %12s %12s %12s %6s\n", "phase", "total", "avg", "max", "share")
+	for _, phase := range tp.Phases {
+		var share float64
+		if total > 0 {
+			share = 100 * float64(phase.Total) / float64(total)
+		}
+		fmt.Fprintf(w, "%-24s %12s %12s %12s %5.1f%%\n", phase.Phase, phase.Total, phase.Avg(),
+			phase.Max, share)
+	}
+}
+
+// ProfileTicks runs game with given rules for n ticks as fast as possible and reports where
+// the time goes. Every squad slot is taken by idle player
+func ProfileTicks(rules rules.Rules, n int, w io.Writer) {
+	field := GenerateField(rules)
+	for idx := 0; idx < rules.MaxPlayers; idx++ {
+		PlaceSquad(field, idx, idx, rules)
+	}
+	PopulateField(field, rules)
+	field.Profiler = NewTickProfiler(logging.NewLogger("profiler"))
+
+	// field blocks on game state when nobody reads it
+	go func() {
+		for range field.GameState {
+		}
+	}()
+	for tick := int64(0); tick < int64(n); tick++ {
+		field.Tick(tick)
+	}
+
+	fmt.Fprintf(w, "rule %s\n", rules)
+	field.Profiler.Profile().WriteReport(w)
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/logging"
+)
+
+func TestProfilerPhases(t *testing.T) {
+	f := newCheckField(32)
+	f.Profiler = NewTickProfiler(logging.NewLogger("profiler"))
+	squad := newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5})
+	squad.Orders <- Order{ORDER_MOVE, geom.CellCoord{X: 25, Y: 5}}
+	f.runTicks(2 * TIME_TICKS_PER_SEC)
+
+	profile := f.Profiler.Profile()
+	if profile.Ticks != 2*TIME_TICKS_PER_SEC {
+		t.Fatalf("profiled %d ticks, want %d", profile.Ticks, 2*TIME_TICKS_PER_SEC)
+	}
+	phases := make(map[string]PhaseStats)
+	for _, phase := range profile.Phases {
+		phases[phase.Phase] = phase
+	}
+	for _, name := range []string{"think/Squad", "decide", "apply/Squad", "grenades", "game-over", "snapshot"} {
+		if _, ok := phases[name]; !ok {
+			t.Fatalf("no phase %s in %+v", name, profile.Phases)
+		}
+	}
+	// game over is checked once per second, not every tick
+	if ticks := phases["game-over"].Ticks; ticks > 2 {
+		t.Fatalf("game over phase seen in %d ticks, want at most 2", ticks)
+	}
+
+	var report bytes.Buffer
+	profile.WriteReport(&report)
+	if !strings.Contains(report.String(), "apply/Squad") {
+		t.Errorf("report has no unit phase:\n%s",
+			report.String())
+	}
+}
+
+func TestProfilerBudget(t *testing.T) {
+	var buf bytes.Buffer
+	logging.SetupLogging(&buf, logging.LOG_WARN, false)
+	defer logging.SetupLogging(os.Stderr, logging.LOG_INFO, false)
+
+	f := newCheckField(16)
+	f.Profiler = NewTickProfiler(logging.NewLogger("profiler"))
+	f.Profiler.budget = 1
+	before := metricSlowTicks.Value()
+	f.runTicks(10)
+
+	if slow := f.Profiler.Profile().SlowTicks; slow != 10 {
+		t.Fatalf("%d slow ticks, want 10", slow)
+	}
+	if counted := metricSlowTicks.Value() - before; counted != 10 {
+		t.Fatalf("metric counted %v slow ticks, want 10", counted)
+	}
+	// rest of warnings is throttled
+	if strings.Count(buf.String(), "over budget") != 1 {
+		t.Errorf("want one warning, got:\n%s", buf.String())
+	}
+}
@@ -1,4 +1,8 @@
-package main
+package engine
+
+import (
+	"github.com/mechmind/life-goes-on/geom"
+)
 
 const (
 	QUARTER_BLOCK_SIZE           = 6
@@ -15,10 +19,10 @@ const (
 )
 
 type QuarterPlan struct {
-	size CellCoord
+	size geom.CellCoord
 }
 
-func NewQuarterPlan(size CellCoord) *QuarterPlan {
+func NewQuarterPlan(size geom.CellCoord) *QuarterPlan {
 	qp := &QuarterPlan{size}
 	return qp
 }
@@ -28,30 +32,30 @@ func (qp *QuarterPlan) CreateQuarters(f *Field) {
 		for j := 0; j < qp.size.Y; j++ {
 			// roll dices for house in that block
 			if f.rng.Int31n(100) < QUARTER_PROBABILITY {
-				qp.MakeHouse(f, CellCoord{i, j})
+				qp.MakeHouse(f, geom.CellCoord{X: i, Y: j})
 			} else {
 				continue
 				// FIXME: debug
 				// make a bush line
 				xLow := i*QUARTER_HOUSE_SIZE + QUARTER_PADDING
 				yLow := j*QUARTER_HOUSE_SIZE + QUARTER_PADDING
-				for xx := xLow; xx < xLow + 15; xx++ {
-					f.CellAt(CellCoord{xx, yLow}).Object = referenceObjects[OBJECT_BUSH]
+				for xx := xLow; xx < xLow+15; xx++ {
+					f.CellAt(geom.CellCoord{X: xx, Y: yLow}).Object = referenceObjects[OBJECT_BUSH]
 				}
 				// make a barricade
-				for yy := yLow+1; yy < yLow + 15; yy++ {
-					f.CellAt(CellCoord{xLow, yy}).Object = referenceObjects[OBJECT_BARRICADE]
+				for yy := yLow + 1; yy < yLow+15; yy++ {
+					f.CellAt(geom.CellCoord{X: xLow, Y: yy}).Object = referenceObjects[OBJECT_BARRICADE]
 				}
 			}
 		}
 	}
 }
 
-func (qp *QuarterPlan) MakeHouse(f *Field, Coord CellCoord) {
+func (qp *QuarterPlan) MakeHouse(f *Field, Coord geom.CellCoord) {
 	// select size for new house (2..4) x (2..4)
-	size := CellCoord{f.rng.Intn(3) + 2, f.rng.Intn(3) + 2}
+	size := geom.CellCoord{X: f.rng.Intn(3) + 2, Y: f.rng.Intn(3) + 2}
 	// select placement for topleft corner
-	var corner CellCoord
+	var corner geom.CellCoord
 	if size.X != 4 {
 		corner.X = f.rng.Intn(4 - size.X)
 	}
@@ -67,12 +71,12 @@ func (qp *QuarterPlan) MakeHouse(f *Field, Coord CellCoord) {
 	yHig := Coord.Y*QUARTER_HOUSE_SIZE + (corner.Y+size.Y)*QUARTER_BLOCK_SIZE + QUARTER_PADDING
 
 	for i := xLow; i <= xHig; i++ {
-		f.CellAt(CellCoord{i, yLow}).Object = referenceObjects[OBJECT_WALL]
-		f.CellAt(CellCoord{i, yHig}).Object = referenceObjects[OBJECT_WALL]
+		f.CellAt(geom.CellCoord{X: i, Y: yLow}).Object = referenceObjects[OBJECT_WALL]
+		f.CellAt(geom.CellCoord{X: i, Y: yHig}).Object = referenceObjects[OBJECT_WALL]
 	}
 	for j := yLow; j <= yHig; j++ {
-		f.CellAt(CellCoord{xLow, j}).Object = referenceObjects[OBJECT_WALL]
-		f.CellAt(CellCoord{xHig, j}).Object = referenceObjects[OBJECT_WALL]
+		f.CellAt(geom.CellCoord{X: xLow, Y: j}).Object = referenceObjects[OBJECT_WALL]
+		f.CellAt(geom.CellCoord{X: xHig, Y: j}).Object = referenceObjects[OBJECT_WALL]
 	}
 	// make main door
 	hasBackDoor := f.rng.Intn(100) < QUARTER_BACKDOOR_PROBABILITY
@@ -82,15 +86,15 @@ func (qp *QuarterPlan) MakeHouse(f *Field, Coord CellCoord) {
 		bdoorPos := f.rng.Intn((xHig-xLow-1)/2)*2 + xLow + 1
 		if f.rng.Int31n(1) == 0 {
 			// bottom wall
-			f.CellAt(CellCoord{doorPos, yLow}).Object = referenceObjects[OBJECT_EMPTY]
+			f.CellAt(geom.CellCoord{X: doorPos, Y: yLow}).Object = referenceObjects[OBJECT_EMPTY]
 			if hasBackDoor {
-				f.CellAt(CellCoord{bdoorPos, yHig}).Object = referenceObjects[OBJECT_EMPTY]
+				f.CellAt(geom.CellCoord{X: bdoorPos, Y: yHig}).Object = referenceObjects[OBJECT_EMPTY]
 			}
 		} else {
 			// top wall
-			f.CellAt(CellCoord{doorPos, yHig}).Object = referenceObjects[OBJECT_EMPTY]
+			f.CellAt(geom.CellCoord{X: doorPos, Y: yHig}).Object = referenceObjects[OBJECT_EMPTY]
 			if hasBackDoor {
-				f.CellAt(CellCoord{bdoorPos, yLow}).Object = referenceObjects[OBJECT_EMPTY]
+				f.CellAt(geom.CellCoord{X: bdoorPos, Y: yLow}).Object = referenceObjects[OBJECT_EMPTY]
 			}
 		}
 	} else {
@@ -99,15 +103,15 @@ func (qp *QuarterPlan) MakeHouse(f *Field, Coord CellCoord) {
 		bdoorPos := f.rng.Intn((yHig-xLow-1)/2)*2 + yLow + 1
 		if f.rng.Int31n(1) == 0 {
 			// left wall
-			f.CellAt(CellCoord{xLow, doorPos}).Object = referenceObjects[OBJECT_EMPTY]
+			f.CellAt(geom.CellCoord{X: xLow, Y: doorPos}).Object = referenceObjects[OBJECT_EMPTY]
 			if hasBackDoor {
-				f.CellAt(CellCoord{xHig, bdoorPos}).Object = referenceObjects[OBJECT_EMPTY]
+				f.CellAt(geom.CellCoord{X: xHig, Y: bdoorPos}).Object = referenceObjects[OBJECT_EMPTY]
 			}
 		} else {
 			// right wall
-			f.CellAt(CellCoord{xHig, doorPos}).Object = referenceObjects[OBJECT_EMPTY]
+			f.CellAt(geom.CellCoord{X: xHig, Y: doorPos}).Object = referenceObjects[OBJECT_EMPTY]
 			if hasBackDoor {
-				f.CellAt(CellCoord{xLow, bdoorPos}).Object = referenceObjects[OBJECT_EMPTY]
+				f.CellAt(geom.CellCoord{X: xLow, Y: bdoorPos}).Object = referenceObjects[OBJECT_EMPTY]
 			}
 		}
 	}
@@ -118,7 +122,7 @@ func (qp *QuarterPlan) MakeHouse(f *Field, Coord CellCoord) {
 
 		var j int
 		for j = yLow + 1; j <= yHig; j++ {
-			cell := f.CellAt(CellCoord{wallX, j})
+			cell := f.CellAt(geom.CellCoord{X: wallX, Y: j})
 			if cell.Passable {
 				cell.Object = referenceObjects[OBJECT_WALL]
 			} else {
@@ -127,7 +131,7 @@ func (qp *QuarterPlan) MakeHouse(f *Field, Coord CellCoord) {
 		}
 		// make a door in that wall
 		doorPos := f.rng.Intn((j-yLow)/2)*2 + yLow + 1
-		f.CellAt(CellCoord{wallX, doorPos}).Object = referenceObjects[OBJECT_EMPTY]
+		f.CellAt(geom.CellCoord{X: wallX, Y: doorPos}).Object = referenceObjects[OBJECT_EMPTY]
 	}
 
 	if size.Y > 2 && f.rng.Intn(100) < QUARTER_INTWALL_PROBABILITY {
@@ -135,7 +139,7 @@ func (qp *QuarterPlan) MakeHouse(f *Field, Coord CellCoord) {
 
 		var i int
 		for i = xLow + 1; i <= xHig; i++ {
-			cell := f.CellAt(CellCoord{i, wallY})
+			cell := f.CellAt(geom.CellCoord{X: i, Y: wallY})
 			if cell.Passable {
 				cell.Object = referenceObjects[OBJECT_WALL]
 			} else {
@@ -144,7 +148,7 @@ func (qp *QuarterPlan) MakeHouse(f *Field, Coord CellCoord) {
 		}
 		// make a door in that wall
 		doorPos := f.rng.Intn((i-xLow)/2)*2 + xLow + 1
-		f.CellAt(CellCoord{doorPos, wallY}).Object = referenceObjects[OBJECT_EMPTY]
+		f.CellAt(geom.CellCoord{X: doorPos, Y: wallY}).Object = referenceObjects[OBJECT_EMPTY]
 	}
 
 }
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+const (
+	UNIT_FLAG_FAST  = 1 << iota // panicking damsel or well fed zed
+	UNIT_FLAG_PANIC             // panicking soldier
+)
+
+// Snapshot is what player is allowed to know about the field. It is built for every player
+// separately and contains no internal state of units and agents
+type Snapshot struct {
+	XSize, YSize int
+	Cells        []Cell
+	Units        []UnitView
+	Squads       []SquadView
+	Grens        []GrenView
+	Fog          bool
+}
+
+// UnitView is public state of single unit, its index in snapshot is unit id
+type UnitView struct {
+	Kind  int8 // KIND_NONE for units hidden from player
+	Dead  int8 // for corpses, kind of unit before death
+	Flags uint8
+	Coord geom.UnitCoord
+}
+
+// SquadView is a list of soldiers of player, morale is visible only to the owner
+type SquadView struct {
+	Pid    int
+	Units  []int
+	Morale []float32
+}
+
+type GrenView struct {
+	From, To geom.UnitCoord
+	Booming  int8
+}
+
+// SnapshotFor builds snapshot of the field for player according to rules
+func SnapshotFor(f *Field, pid int, rules rules.Rules) *Snapshot {
+	if rules.Fog {
+		f = fogField(f, pid, rules.Versus)
+	}
+	return NewSnapshot(f, pid)
+}
+
+func NewSnapshot(f *Field, pid int) *Snapshot {
+	s := &Snapshot{XSize: f.XSize, YSize: f.YSize, Cells: f.Cells, Fog: f.Fog}
+
+	s.Units = make([]UnitView, len(f.Units))
+	for idx, up := range f.Units {
+		if up.Unit == nil {
+			continue
+		}
+		s.Units[idx] = newUnitView(up)
+	}
+
+	for _, a := range f.Agents {
+		squad, ok := a.(*Squad)
+		if !ok {
+			continue
+		}
+		sv := SquadView{Pid: squad.Pid}
+		for _, sol := range squad.Units {
+			sv.Units = append(sv.Units, sol.Id)
+			if squad.Pid == pid {
+				sv.Morale = append(sv.Morale, sol.Morale)
+			}
+		}
+		s.Squads = append(s.Squads, sv)
+	}
+
+	for _, g := range f.Grens {
+		s.Grens = append(s.Grens, GrenView{g.From, g.To, g.Booming})
+	}
+	return s
+}
+
+func newUnitView(up UnitPresence) UnitView {
+	uv := UnitView{Kind: int8(UnitKind(up.Unit)), Coord: up.Coord}
+	switch u := up.Unit.(type) {
+	case *Soldier:
+		if u.IsPanicking() {
+			uv.Flags |= UNIT_FLAG_PANIC
+		}
+	case *Damsel:
+		if u.Adrenaline > 0 {
+			uv.Flags |= UNIT_FLAG_FAST
+		}
+	case *Zed:
+		if u.Nutrition > ZED_NUTRITION_FULL {
+			uv.Flags |= UNIT_FLAG_FAST
+		}
+	case *Corpse:
+		uv.Dead = int8(UnitKind(u.Unit))
+	}
+	return uv
+}
+
+// Field reconstructs render state from snapshot. Units in it are just shells for rendering
+func (s *Snapshot) Field() *Field {
+	f := &Field{XSize: s.XSize, YSize: s.YSize, Cells: s.Cells, Fog: s.Fog}
+
+	f.Units = make([]UnitPresence, len(s.Units))
+	for idx, uv := range s.Units {
+		var u Unit
+		if uv.Kind == KIND_CORPSE {
+			u = &Corpse{field: f, Id: idx, Unit: uv.shell(f, int(uv.Dead), idx)}
+		} else {
+			u = uv.shell(f, int(uv.Kind), idx)
+		}
+		f.Units[idx] = UnitPresence{uv.Coord, nopAgent, u}
+	}
+
+	for _, sv := range s.Squads {
+		squad := &Squad{Pid: sv.Pid}
+		for idx, Id := range sv.Units {
+			sol, ok := f.Units[Id].Unit.(*Soldier)
+			if !ok {
+				continue
+			}
+			if idx < len(sv.Morale) {
+				sol.Morale = sv.Morale[idx]
+			}
+			squad.Units = append(squad.Units, sol)
+			f.Units[Id].Agent = squad
+		}
+		f.Agents = append(f.Agents, squad)
+	}
+
+	for _, g := range s.Grens {
+		f.Grens = append(f.Grens, FlyingGren{From: g.From, To: g.To, Booming: g.Booming})
+	}
+	return f
+}
+
+func (uv UnitView) shell(f *Field, kind, Id int) Unit {
+	switch kind {
+	case KIND_SOLDIER:
+		sol := &Soldier{field: f, Id: Id, Morale: SOL_MORALE_MAX}
+		if uv.Flags&UNIT_FLAG_PANIC > 0 {
+			sol.Morale = 0
+		}
+		return sol
+	case KIND_DAMSEL:
+		dam := &Damsel{field: f, Id: Id}
+		if uv.Flags&UNIT_FLAG_FAST > 0 {
+			dam.Adrenaline = 1
+		}
+		return dam
+	case KIND_ZED:
+		zed := &Zed{field: f, Id: Id}
+		if uv.Flags&UNIT_FLAG_FAST > 0 {
+			zed.Nutrition = ZED_NUTRITION_FULL + 1
+		}
+		return zed
+	}
+	return nil
+}
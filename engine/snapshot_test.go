@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+func TestSnapshotCopy(t *testing.T) {
+	f := newCheckField(32)
+	squad := newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5}, geom.UnitCoord{X: 6.5, Y: 5.5})
+	squad.Orders <- Order{ORDER_MOVE, geom.CellCoord{X: 25, Y: 5}}
+	crowd := &checkAgent{}
+	f.PlaceUnit(geom.UnitCoord{X: 20.5, Y: 20.5}, crowd, NewZed(f))
+
+	bb := CopyField(f)
+	f.runTicks(5)
+	f.DealDamage(DamageSource{2, -1, DAMAGE_BITE}, 1, 1000)
+	f.PlaceObject(geom.CellCoord{X: 10, Y: 10}, referenceObjects[OBJECT_WALL])
+
+	if bb.Units[0].Coord != (geom.UnitCoord{X: 5.5, Y: 5.5}) || bb.Units[0].Unit == f.Units[0].Unit {
+		t.Fatalf("copy follows moving soldier to %s", bb.Units[0].Coord)
+	}
+	if _, ok := bb.Units[1].Unit.(*Soldier); !ok {
+		t.Fatalf("copy sees soldier killed after it was made")
+	}
+	copied := bb.AgentForUnitID(0).(*Squad)
+	if copied == squad || len(copied.Units) != 2 || copied.Units[1] != bb.Units[1].Unit {
+		t.Fatalf("squad in copy is not made of copied soldiers")
+	}
+	if bb.CellAt(geom.CellCoord{X: 10, Y: 10}).Type == OBJECT_WALL ||
+		f.CellAt(geom.CellCoord{X: 10, Y: 10}).Type != OBJECT_WALL {
+		t.Error("wall placed after copy is in copy")
+	}
+}
+
+// snapshotReader passes snapshots to another goroutine, as remote and local renders do
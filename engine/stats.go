@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mechmind/life-goes-on/metrics"
+)
+
+const (
+	// one unit sample per second
+	STATS_SAMPLE_TICKS = TIME_TICKS_PER_SEC
+	STATS_HISTORY_LEN  = 300
+)
+
+// upper bounds of tick duration histogram buckets, last bucket is unbounded
+var StatsTickBuckets = []time.Duration{time.Millisecond, 2 * time.Millisecond,
+	5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond}
+
+var metricTickDuration = metrics.NewHistogram("lgo_tick_duration_seconds",
+	"Time spent on simulation tick.", StatsTickBuckets)
+
+// UnitSample is unit census of the field taken once per STATS_SAMPLE_TICKS
+type UnitSample struct {
+	Time                             time.Time
+	Tick                             int64
+	Soldiers, Damsels, Zeds, Corpses int
+	PathCalls                        int // since previous sample
+}
+
+type HistogramBucket struct {
+	Le    time.Duration // 0 for the last, unbounded bucket
+	Count int64
+}
+
+// StatsView is a copy of room stats for debug dashboard
+type StatsView struct {
+	Tick          int64
+	PathCalls     int // in last tick
+	TickTime      time.Duration
+	TickHistogram []HistogramBucket
+	History       []UnitSample
+}
+
+// RoomStats collects simulation stats of one room. Field updates it on time goroutine,
+// dashboard reads it from http handlers
+type RoomStats struct {
+	lock sync.Mutex
+
+	tick        int64
+	pathCalls   int
+	samplePaths int
+	tickTime    time.Duration
+	tickCounts  []int64
+	history     []UnitSample
+}
+
+func NewRoomStats() *RoomStats {
+	return &RoomStats{tickCounts: make([]int64, len(StatsTickBuckets)+1)}
+}
+
+// recordTick is called by field at the end of every tick
+func (s *RoomStats) recordTick(f *Field, spent time.Duration) {
+	metricTickDuration.Observe(spent)
+	var sample *UnitSample
+	if f.tick%STATS_SAMPLE_TICKS == 0 {
+		sample = &UnitSample{Time: time.Now(), Tick: f.tick}
+		for _, u := range f.Units {
+			switch UnitKind(u.Unit) {
+			case KIND_SOLDIER:
+				sample.Soldiers++
+			case KIND_DAMSEL:
+				sample.Damsels++
+			case KIND_ZED:
+				sample.Zeds++
+			case KIND_CORPSE:
+				sample.Corpses++
+			}
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.tick, s.pathCalls, s.tickTime = f.tick, int(f.pathCalls), spent
+	s.samplePaths += int(f.pathCalls)
+	bucket := 0
+	for bucket < len(StatsTickBuckets) && spent > StatsTickBuckets[bucket] {
+		bucket++
+	}
+	s.tickCounts[bucket]++
+
+	if sample != nil {
+		sample.PathCalls, s.samplePaths = s.samplePaths, 0
+		s.history = append(s.history, *sample)
+		if len(s.history) > STATS_HISTORY_LEN {
+			s.history = s.history[len(s.history)-STATS_HISTORY_LEN:]
+		}
+	}
+}
+
+func (s *RoomStats) View() StatsView {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	view := StatsView{Tick: s.tick, PathCalls: s.pathCalls, TickTime: s.tickTime,
+		History: append([]UnitSample(nil), s.history...)}
+	for idx, count := range s.tickCounts {
+		var le time.Duration
+		if idx < len(StatsTickBuckets) {
+			le = StatsTickBuckets[idx]
+		}
+		view.TickHistogram = append(view.TickHistogram, HistogramBucket{le, count})
+	}
+	return view
+}
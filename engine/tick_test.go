@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+// newCrowdField is a seeded field with a swarm attacking damsels and two squads defending them
+func newCrowdField(workers int) *Field {
+	f := newCheckField(96)
+	f.workers = workers
+	for y := 30; y < 60; y++ {
+		f.wall(geom.CellCoord{X: 48, Y: y})
+	}
+
+	swarm := &ZedSwarm{}
+	f.PlaceAgent(swarm)
+	for i := 0; i < 40; i++ {
+		f.PlaceUnit(geom.UnitCoord{X: 70 + f.rng.Float32()*10, Y: 40 + f.rng.Float32()*10}, swarm, NewZed(f))
+	}
+	crowd := &DamselCrowd{}
+	f.PlaceAgent(crowd)
+	for i := 0; i < 300; i++ {
+		dam := NewDamsel(f)
+		dam.WanderTarget = geom.UnitCoord{X: 2 + f.rng.Float32()*90, Y: 2 + f.rng.Float32()*90}
+		f.PlaceUnit(dam.WanderTarget, crowd, dam)
+	}
+	for Pid, x := range []float32{20.5, 60.5} {
+		squad := newCheckSquad(f, Pid, geom.UnitCoord{X: x, Y: 10.5}, geom.UnitCoord{X: x + 2, Y: 10.5},
+			geom.UnitCoord{X: x, Y: 12.5}, geom.UnitCoord{X: x + 2, Y: 12.5})
+		squad.FireState = ORDER_FIRE
+		squad.Orders <- Order{ORDER_MOVE, geom.CellCoord{X: 70, Y: 45}}
+		squad.Orders <- Order{ORDER_GREN, geom.CellCoord{X: int(x) + 10, Y: 20}}
+	}
+	return f
+}
+
+// fingerprint describes every unit and recorded damage
+func (f *Field) fingerprint() string {
+	var b strings.Builder
+	for Id, up := range f.Units {
+		fmt.Fprintf(&b, "%d %d %v %v\n", Id, UnitKind(up.Unit), up.Coord, up.Agent == nopAgent)
+	}
+	for _, r := range f.ledger.Records {
+		fmt.Fprintf(&b, "%+v\n", r)
+	}
+	return b.String()
+}
+
+func TestTickWorkers(t *testing.T) {
+	var want string
+	for _, workers := range []int{1, 3, 8} {
+		f := newCrowdField(workers)
+		f.runTicks(150)
+		if len(f.ledger.Records) == 0 {
+			t.Fatalf("nothing happened in %d ticks", f.tick)
+		}
+		got := f.fingerprint()
+		if want == "" {
+			want = got
+		} else if got != want {
+			t.Fatalf("outcome with %d workers differs from one with single worker",
+				workers)
+		}
+	}
+}
+
+// watchAgent remembers where it saw other unit while deciding
+type watchAgent struct {
+	checkAgent
+	watched int
+	seen    []geom.UnitCoord
+}
+
+func (w *watchAgent) HandleUnit(f *FieldView, u Unit, Coord geom.UnitCoord) {
+	coord, _ := f.UnitByID(w.watched)
+	w.seen = append(w.seen, coord)
+}
+
+func TestTickSnapshot(t *testing.T) {
+	f := newCheckField(32)
+	runner := newCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5})
+	runner.Orders <- Order{ORDER_MOVE, geom.CellCoord{X: 25, Y: 5}}
+	watcher := &watchAgent{watched: runner.Units[0].Id}
+	f.PlaceAgent(watcher)
+	f.PlaceUnit(geom.UnitCoord{X: 5.5, Y: 20.5}, watcher, NewDamsel(f))
+
+	f.runTicks(3)
+	// watcher is handled after runner, but sees where runner was before the tick
+	if watcher.seen[0] != (geom.UnitCoord{X: 5.5, Y: 5.5}) {
+		t.Fatalf("watcher saw runner at %s in first tick", watcher.seen[0])
+	}
+	coord, _ := f.UnitByID(runner.Units[0].Id)
+	if watcher.seen[2] == coord || coord.X <= 5.5 {
+		t.Errorf("runner at %s, watcher saw %v",
+			coord, watcher.seen)
+	}
+}
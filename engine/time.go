@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/logging"
+)
+
+const (
+	TIME_TICKS_PER_SEC = 10
+	TIME_NORMAL_SPEED  = 2 // index in timeSpeeds
+)
+
+// speed multipliers game can run at
+var timeSpeeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+
+type Ticker interface {
+	Tick(tick int64)
+}
+
+type Time struct {
+	ticker Ticker
+	freq   int64
+	clock  *time.Ticker
+	stopCh chan struct{}
+	paused int32 // set atomically, ticks are skipped while paused
+	speed  int32 // index in timeSpeeds, set atomically
+	steps  int32 // ticks to do while paused
+	wake   chan struct{}
+	ticks  int64 // ticks done since Run, set atomically
+}
+
+func NewTime(freq int64) *Time {
+	return &Time{freq: freq, clock: time.NewTicker(time.Second / time.Duration(freq)),
+		stopCh: make(chan struct{}), speed: TIME_NORMAL_SPEED, wake: make(chan struct{}, 1)}
+}
+
+func (t *Time) Run() {
+	var counter int64
+	var period = t.period()
+	atomic.StoreInt64(&t.ticks, 0)
+	// speed might be changed while time was stopped
+	t.clock.Reset(period)
+	defer logging.LogPanic()
+	for {
+		select {
+		case <-t.clock.C:
+			if t.Paused() {
+				continue
+			}
+			t.ticker.Tick(counter)
+			counter++
+			atomic.StoreInt64(&t.ticks, counter)
+		case <-t.wake:
+			if p := t.period(); p != period {
+				period = p
+				t.clock.Reset(period)
+			}
+			for atomic.LoadInt32(&t.steps) > 0 {
+				atomic.AddInt32(&t.steps, -1)
+				t.ticker.Tick(counter)
+				counter++
+				atomic.StoreInt64(&t.ticks, counter)
+			}
+		case <-t.stopCh:
+			return
+		}
+	}
+}
+
+// Elapsed returns game time passed since Run, pauses and speed do not count
+func (t *Time) Elapsed() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.ticks)) * time.Second / time.Duration(t.freq)
+}
+
+func (t *Time) Stop() {
+	t.stopCh <- struct{}{}
+}
+
+func (t *Time) SetTicker(tr Ticker) {
+	t.ticker = tr
+}
+
+// nudge makes Run pick up new speed and steps, it does not block when Run is not running
+func (t *Time) nudge() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Time) period() time.Duration {
+	return time.Duration(float64(time.Second) / float64(t.freq) / t.Speed())
+}
+
+func (t *Time) SetPaused(paused bool) {
+	var v int32
+	if paused {
+		v = 1
+	} else {
+		// steps requested while paused are no longer needed
+		atomic.StoreInt32(&t.steps, 0)
+	}
+	atomic.StoreInt32(&t.paused, v)
+}
+
+func (t *Time) Paused() bool {
+	return atomic.LoadInt32(&t.paused) == 1
+}
+
+// Step does a single tick of paused game, it returns false if game is not paused
+func (t *Time) Step() bool {
+	if !t.Paused() {
+		return false
+	}
+	atomic.AddInt32(&t.steps, 1)
+	t.nudge()
+	return true
+}
+
+// SetSpeed sets speed multiplier, it must be one of timeSpeeds
+func (t *Time) SetSpeed(speed float64) error {
+	for idx, s := range timeSpeeds {
+		if s == speed {
+			atomic.StoreInt32(&t.speed, int32(idx))
+			t.nudge()
+			return nil
+		}
+	}
+	return fmt.Errorf("speed must be one of %s", FormatSpeeds())
+}
+
+func (t *Time) Speed() float64 {
+	return timeSpeeds[atomic.LoadInt32(&t.speed)]
+}
+
+// Status is shown in status bar of players
+func (t *Time) Status() string {
+	if t.Paused() {
+		return "paused"
+	}
+	return FormatSpeed(t.Speed())
+}
+
+// ShiftSpeed returns speed steps faster (or slower for negative steps) than given one
+func ShiftSpeed(speed float64, steps int) float64 {
+	idx := TIME_NORMAL_SPEED
+	for i, s := range timeSpeeds {
+		if s == speed {
+			idx = i
+		}
+	}
+	return timeSpeeds[geom.Ibound(idx+steps, 0, len(timeSpeeds)-1)]
+}
+
+// ParseSpeed accepts multiplier with optional x suffix, i.e. 2, 0.5x
+func ParseSpeed(text string) (float64, error) {
+	if len(text) > 0 && text[len(text)-1] == 'x' {
+		text = text[:len(text)-1]
+	}
+	speed, err := strconv.ParseFloat(text, 64)
+	if err == nil {
+		for _, s := range timeSpeeds {
+			if s == speed {
+				return speed, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("speed must be one of %s", FormatSpeeds())
+}
+
+func FormatSpeed(speed float64) string {
+	return strconv.FormatFloat(speed, 'g', -1, 64) + "x"
+}
+
+func FormatSpeeds() string {
+	var text string
+	for idx, s := range timeSpeeds {
+		if idx > 0 {
+			text += ", "
+		}
+		text += FormatSpeed(s)
+	}
+	return text
+}
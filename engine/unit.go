@@ -1,4 +1,9 @@
-package main
+package engine
+
+import (
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/pathfind"
+)
 
 const (
 	ZED_NUTRITION_WALKING         = 1
@@ -38,6 +43,16 @@ const (
 	SOL_GREN_TIMEOUT    = 15
 	SOL_SEMIFIRE_TICKS  = 2
 
+	SOL_MORALE_MAX           = 100
+	SOL_MORALE_SHAKEN        = 50
+	SOL_MORALE_PANIC         = 15
+	SOL_MORALE_SQUADMATE_DIE = 25
+	SOL_MORALE_FEAR_RANGE    = 5
+	SOL_MORALE_FEAR          = 0.4
+	SOL_MORALE_ALLY_RANGE    = 4
+	SOL_MORALE_RECOVERY      = 0.15
+	SOL_MORALE_IGNORE_PROB   = 30
+
 	DAM_MOVER_WALK      = 0.30
 	DAM_MOVER_WALKUP    = 0.10
 	DAM_MOVER_WALKDOWN  = 0.35
@@ -54,12 +69,13 @@ const (
 type Unit interface {
 	SetID(int)
 	GetID() int
+	IsDead() bool
 	Mover
 	DamageReciever
 }
 
 type Mover interface {
-	MoveToward(src, dest UnitCoord) (UnitCoord, bool)
+	MoveToward(src, dest geom.UnitCoord) (geom.UnitCoord, bool)
 }
 
 type DamageReciever interface {
@@ -72,9 +88,9 @@ type Walker struct {
 	WalkDownSpeed float32
 }
 
-func (w *Walker) MoveToward(f *Field, src, dest UnitCoord) (UnitCoord, bool) {
-	toward := NormTowardCoord(src, dest)
-	direction := NextCellCoord(src, toward)
+func (w *Walker) MoveToward(f *Field, src, dest geom.UnitCoord) (geom.UnitCoord, bool) {
+	toward := geom.NormTowardCoord(src, dest)
+	direction := geom.NextCellCoord(src, toward)
 	currentCellCoord := src.Cell()
 	currentCell := f.CellAt(currentCellCoord)
 	cost := calcSlopeCost(direction, currentCell.Slopes)
@@ -129,7 +145,7 @@ func (w *Walker) MoveToward(f *Field, src, dest UnitCoord) (UnitCoord, bool) {
 							scale = (float32(currentCellCoord.Y) - src.Y) / distance.Y
 						}
 					}
-					scale -= FLOAT_ERROR
+					scale -= geom.FLOAT_ERROR
 					next = src.AddCoord(distance.Mult(scale))
 					stuck = true
 				}
@@ -145,20 +161,20 @@ func (w *Walker) MoveToward(f *Field, src, dest UnitCoord) (UnitCoord, bool) {
 	return next, stuck
 }
 
-func (w *Walker) MoveAway(f *Field, src, dest UnitCoord) (UnitCoord, bool) {
+func (w *Walker) MoveAway(f *Field, src, dest geom.UnitCoord) (geom.UnitCoord, bool) {
 	newDest := src.AddCoord(src.AddCoord(dest.Mult(-1)))
 	return w.MoveToward(f, src, newDest)
 }
 
 // calcSlopeCost return 1 for moving up on slope, -1 for moving down on slope
-func calcSlopeCost(direction CellCoord, slope uint8) int {
+func calcSlopeCost(direction geom.CellCoord, slope uint8) int {
 	var cost int
 	// check horizontal movement
 	cost += (int((slope&SLOPE_DOWN)>>SLOPE_DOWN_SHIFT) -
 		int((slope&SLOPE_UP)>>SLOPE_UP_SHIFT)) * direction.X
 	cost += (int((slope&SLOPE_DOWN)>>SLOPE_DOWN_SHIFT) -
 		int((slope&SLOPE_UP)>>SLOPE_UP_SHIFT)) * direction.Y
-	return ibound(cost, -1, 1)
+	return geom.Ibound(cost, -1, 1)
 }
 
 type Possesser struct {
@@ -179,7 +195,7 @@ type Gunner struct {
 	GunDamage float32
 }
 
-func (g Gunner) CanShoot(src, dest UnitCoord) bool {
+func (g Gunner) CanShoot(src, dest geom.UnitCoord) bool {
 	return src.Distance(dest) < g.FireRange
 }
 
@@ -187,7 +203,7 @@ type Biter struct {
 	BiteDamage float32
 }
 
-func (b Biter) CanBite(src, dest UnitCoord) bool {
+func (b Biter) CanBite(src, dest geom.UnitCoord) bool {
 	return src.Distance(dest) < 1
 }
 
@@ -196,19 +212,20 @@ type Soldier struct {
 	Possesser
 	Chaser
 	Gunner
+	Health
 	field           *Field
 	Id              int
-	Health          float32
+	Morale          float32
 	SemifireCounter int8
-	Target          UnitCoord
-	MyTarget        UnitCoord
-	path            Path
+	Target          geom.UnitCoord
+	MyTarget        geom.UnitCoord
+	path            pathfind.Path
 }
 
 func NewSoldier(field *Field) *Soldier {
 	return &Soldier{Walker: Walker{SOL_MOVER_WALK, SOL_MOVER_WALKUP, SOL_MOVER_WALKDOWN},
 		Chaser: Chaser{-1}, Gunner: Gunner{FireRange: SOL_GUN_RANGE, GunDamage: SOL_GUN_DAMAGE},
-		Health: SOL_BASE_HEALTH, field: field}
+		Health: NewHealth(SOL_BASE_HEALTH), Morale: SOL_MORALE_MAX, field: field}
 }
 
 func (s *Soldier) SetID(Id int) {
@@ -219,24 +236,55 @@ func (s *Soldier) GetID() int {
 	return s.Id
 }
 
-func (s *Soldier) MoveToward(src, dest UnitCoord) (UnitCoord, bool) {
+func (s *Soldier) MoveToward(src, dest geom.UnitCoord) (geom.UnitCoord, bool) {
 	nextCoord, stuck := s.Walker.MoveToward(s.field, src, dest)
 	return s.field.MoveMe(s.Id, nextCoord), stuck
 }
 
-func (s *Soldier) CanShoot(src, dest UnitCoord) bool {
+func (s *Soldier) MoveAway(src, dest geom.UnitCoord) (geom.UnitCoord, bool) {
+	nextCoord, stuck := s.Walker.MoveAway(s.field, src, dest)
+	return s.field.MoveMe(s.Id, nextCoord), stuck
+}
+
+// UpdateMorale applies fear from nearby zeds and support from nearby allies
+func (s *Soldier) UpdateMorale(zeds, allies int) {
+	s.Morale += float32(allies)*SOL_MORALE_RECOVERY - float32(zeds)*SOL_MORALE_FEAR
+	s.Morale = geom.Fbound(s.Morale, 0, SOL_MORALE_MAX)
+}
+
+func (s *Soldier) LoseMorale(amount float32) {
+	s.Morale = geom.Fbound(s.Morale-amount, 0, SOL_MORALE_MAX)
+}
+
+func (s *Soldier) IsShaken() bool {
+	return s.Morale < SOL_MORALE_SHAKEN
+}
+
+func (s *Soldier) IsPanicking() bool {
+	return s.Morale < SOL_MORALE_PANIC
+}
+
+// moraleAccuracy returns hit chance multiplier, from 0.5 for broken soldier up to 1
+func (s *Soldier) moraleAccuracy() float32 {
+	return 0.5 + s.Morale/SOL_MORALE_MAX/2
+}
+
+func (s *Soldier) CanShoot(src, dest geom.UnitCoord) bool {
 	return s.Gunner.CanShoot(src, dest) && s.field.HaveLOS(src, dest) != VS_INVISIBLE
 }
 
 func (s *Soldier) RecieveDamage(From int, dmg float32) {
-	s.Health -= dmg
-	if s.Health < 0 {
-		s.field.KillMe(s.Id)
-	}
-	s.Health -= dmg
+	s.Hurt(dmg)
 }
 
-func (s *Soldier) Shoot(src, dest UnitCoord, victim Unit) {
+func (s *Soldier) Shoot(src, dest geom.UnitCoord, victim Unit) {
+	if Pid := s.field.pidOf(s.Id); Pid >= 0 {
+		s.field.ledger.Counters(Pid).Shots++
+	}
+	// shaking hands
+	if s.field.rng.Float32() > s.moraleAccuracy() {
+		return
+	}
 	// check misshots
 	tid, newDest := s.field.TraceShot(src, dest, victim.GetID())
 	if tid == -1 {
@@ -251,8 +299,7 @@ func (s *Soldier) Shoot(src, dest UnitCoord, victim Unit) {
 			return
 		}
 	}
-	_, realVictim := s.field.UnitByID(tid)
-	realVictim.RecieveDamage(s.Id, s.Gunner.GunDamage)
+	s.field.DealDamage(s.field.sourceOf(s.Id, DAMAGE_GUN), tid, s.Gunner.GunDamage)
 }
 
 type Zed struct {
@@ -262,19 +309,19 @@ type Zed struct {
 	Walker
 	Chaser
 	Biter
-	Health       float32
+	Health
 	LastAttacker int
 
 	Rage      float32
 	Nutrition float32
 
-	path Path
+	path pathfind.Path
 }
 
 func NewZed(field *Field) *Zed {
 	return &Zed{Walker: Walker{ZED_MOVER_WALK, ZED_MOVER_WALKUP, ZED_MOVER_WALKDOWN},
 		Biter: Biter{BiteDamage: ZED_BITE_DAMAGE}, LastAttacker: -1, Rage: 0,
-		Nutrition: ZED_NUTRITION_BASE, Health: ZED_HEALTH, field: field}
+		Nutrition: ZED_NUTRITION_BASE, Health: NewHealth(ZED_HEALTH), field: field}
 }
 
 func (z *Zed) SetID(Id int) {
@@ -284,33 +331,30 @@ func (z *Zed) SetID(Id int) {
 func (z *Zed) GetID() int {
 	return z.Id
 }
-func (z *Zed) MoveToward(src, dest UnitCoord) (UnitCoord, bool) {
+func (z *Zed) MoveToward(src, dest geom.UnitCoord) (geom.UnitCoord, bool) {
 	// apply nutrition and rage speedup/slowdown
 	nutr_coeff := z.Nutrition / 1000
 	rage_coeff := z.Rage * ZED_RAGE_SPEEDUP
 	all_coeff := nutr_coeff + rage_coeff
-	z.Walker = Walker{fbound(ZED_MOVER_WALK*all_coeff, 0, 1),
-		fbound(ZED_MOVER_WALKUP*all_coeff, 0, 1),
-		fbound(ZED_MOVER_WALKDOWN*all_coeff, 0, 1)}
+	z.Walker = Walker{geom.Fbound(ZED_MOVER_WALK*all_coeff, 0, 1),
+		geom.Fbound(ZED_MOVER_WALKUP*all_coeff, 0, 1),
+		geom.Fbound(ZED_MOVER_WALKDOWN*all_coeff, 0, 1)}
 	nextCoord, stuck := z.Walker.MoveToward(z.field, src, dest)
 	z.Nutrition -= src.Distance(nextCoord) * ZED_NUTRITION_WALKING
 	return z.field.MoveMe(z.Id, nextCoord), stuck
 }
 
-func (z *Zed) Bite(src, dest UnitCoord, victim Unit) {
+func (z *Zed) Bite(src, dest geom.UnitCoord, victim Unit) {
 	damage := z.Biter.BiteDamage + z.Rage*ZED_RAGE_BITEUP
 	z.Nutrition -= damage * ZED_NUTRITION_BITING
 
-	victim.RecieveDamage(z.Id, z.Biter.BiteDamage)
+	z.field.DealDamage(z.field.sourceOf(z.Id, DAMAGE_BITE), victim.GetID(), damage)
 }
 
 func (z *Zed) RecieveDamage(From int, dmg float32) {
-	z.Health -= dmg
+	z.Hurt(dmg)
 	z.Rage += dmg * ZED_RAGE_FROM_DAMAGE
 	z.LastAttacker = From
-	if z.Health < 0 {
-		z.field.KillMe(z.Id)
-	}
 }
 
 func (z *Zed) Eat(food float32) {
@@ -330,31 +374,36 @@ func (z *Zed) Digest() bool {
 	// digest the food
 	if z.Nutrition > ZED_NUTRITION_TO_HP_THRESHOLD {
 		z.Nutrition -= ZED_NUTRITION_TO_HP_PORTION
-		z.Health += ZED_NUTRITION_TO_HP_PORTION * ZED_NUTRITION_TO_HP_SCALE
+		z.Heal(ZED_NUTRITION_TO_HP_PORTION * ZED_NUTRITION_TO_HP_SCALE)
 	}
 
 	if z.Nutrition < 0 {
 		// starve to death
-		z.field.KillMe(z.Id)
+		z.field.effect(z.Id, z.starve)
 		return false
 	}
 	return true
 }
 
+func (z *Zed) starve() {
+	z.Die()
+	z.field.StarveMe(z.Id)
+}
+
 type Damsel struct {
 	Walker
+	Health
 	field        *Field
 	Id           int
-	Health       float32
-	PanicPoint   UnitCoord
+	PanicPoint   geom.UnitCoord
 	Adrenaline   float32
 	LastAttacker int
-	WanderTarget UnitCoord
+	WanderTarget geom.UnitCoord
 }
 
 func NewDamsel(field *Field) *Damsel {
 	return &Damsel{Walker: Walker{DAM_MOVER_WALK, DAM_MOVER_WALKUP, DAM_MOVER_WALKDOWN},
-		LastAttacker: -1, Health: DAM_BASE_HEALTH, field: field}
+		LastAttacker: -1, Health: NewHealth(DAM_BASE_HEALTH), field: field}
 }
 
 func (d *Damsel) SetID(Id int) {
@@ -365,13 +414,13 @@ func (d *Damsel) GetID() int {
 	return d.Id
 }
 
-func (d *Damsel) MoveToward(src, dest UnitCoord) (UnitCoord, bool) {
+func (d *Damsel) MoveToward(src, dest geom.UnitCoord) (geom.UnitCoord, bool) {
 	d.adjustWalkSpeed()
 	nextCoord, stuck := d.Walker.MoveToward(d.field, src, dest)
 	return d.field.MoveMe(d.Id, nextCoord), stuck
 }
 
-func (d *Damsel) MoveAway(src, dest UnitCoord) (UnitCoord, bool) {
+func (d *Damsel) MoveAway(src, dest geom.UnitCoord) (geom.UnitCoord, bool) {
 	d.adjustWalkSpeed()
 	nextCoord, stuck := d.Walker.MoveAway(d.field, src, dest)
 	return d.field.MoveMe(d.Id, nextCoord), stuck
@@ -381,13 +430,13 @@ func (d *Damsel) adjustWalkSpeed() {
 	// calculate adrenaline effect
 	// FIXME: walkup/walkdown recalc
 	newSpeed := DAM_MOVER_WALK + d.Adrenaline*DAM_PANIC_SPEEDUP
-	d.Walker = Walker{fbound(newSpeed, 0, DAM_PANIC_MAX_SPEED),
-		fbound(newSpeed, 0, DAM_PANIC_MAX_SPEED), fbound(newSpeed, 0, DAM_PANIC_MAX_SPEED)}
+	d.Walker = Walker{geom.Fbound(newSpeed, 0, DAM_PANIC_MAX_SPEED),
+		geom.Fbound(newSpeed, 0, DAM_PANIC_MAX_SPEED), geom.Fbound(newSpeed, 0, DAM_PANIC_MAX_SPEED)}
 }
 
-func (d *Damsel) HearScream(dmg float32, src UnitCoord, distance float32) {
+func (d *Damsel) HearScream(dmg float32, src geom.UnitCoord, distance float32) {
 	newAdrenaline := dmg / distance * DAM_FEAR_FACTOR
-	if d.Adrenaline < FLOAT_ERROR {
+	if d.Adrenaline < geom.FLOAT_ERROR {
 		d.Adrenaline = newAdrenaline
 	}
 
@@ -395,7 +444,7 @@ func (d *Damsel) HearScream(dmg float32, src UnitCoord, distance float32) {
 }
 
 func (d *Damsel) RecieveDamage(From int, dmg float32) {
-	d.Health -= dmg
+	d.Hurt(dmg)
 	d.LastAttacker = From
 	// scream in pain
 	myCoord, _ := d.field.UnitByID(d.Id)
@@ -406,9 +455,7 @@ func (d *Damsel) RecieveDamage(From int, dmg float32) {
 		}
 	}
 
-	if d.Health < 0 {
-		d.field.KillMe(d.Id)
-	} else {
+	if !d.IsDead() {
 		// boost adrenaline
 		d.Adrenaline += dmg
 	}
@@ -430,12 +477,16 @@ func (c *Corpse) GetID() int {
 	return c.Id
 }
 
-func (c *Corpse) MoveToward(src, dest UnitCoord) (UnitCoord, bool) {
+func (c *Corpse) MoveToward(src, dest geom.UnitCoord) (geom.UnitCoord, bool) {
 	return src, false
 }
 
 func (c *Corpse) RecieveDamage(From int, dmg float32) {}
 
+func (c *Corpse) IsDead() bool {
+	return true
+}
+
 func (c *Corpse) Respawn() *Zed {
 	return NewZed(c.field)
 }
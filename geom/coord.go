@@ -1,4 +1,5 @@
-package main
+// Package geom has unit and cell coordinates of the field and small math helpers.
+package geom
 
 import (
 	"fmt"
@@ -8,6 +9,11 @@ import (
 const (
 	LOWER_BOUND = 0
 	UPPER_BOUND = 1024
+	FLOAT_ERROR = 0.000001
+)
+
+var (
+	Neighbours = [8]CellCoord{{0, 1}, {1, 1}, {1, 0}, {1, -1}, {0, -1}, {-1, -1}, {-1, 0}, {-1, 1}}
 )
 
 type UnitCoord struct {
@@ -33,14 +39,14 @@ func (u UnitCoord) Mult(value float32) UnitCoord {
 func (u UnitCoord) Distance(To UnitCoord) float32 {
 	dx := u.X - To.X
 	dy := u.Y - To.Y
-	if fabs(dx) < FLOAT_ERROR && fabs(dy) < FLOAT_ERROR {
+	if Fabs(dx) < FLOAT_ERROR && Fabs(dy) < FLOAT_ERROR {
 		return 0
 	}
 	return float32(math.Sqrt(float64(dx*dx + dy*dy)))
 }
 
 func (u UnitCoord) Bound(lx, ly, hx, hy float32) UnitCoord {
-	return UnitCoord{fbound(u.X, lx, hx), fbound(u.Y, ly, hy)}
+	return UnitCoord{Fbound(u.X, lx, hx), Fbound(u.Y, ly, hy)}
 }
 
 func (u UnitCoord) Cell() CellCoord {
@@ -81,7 +87,7 @@ func NextCellCoord(pos, dir UnitCoord) CellCoord {
 	jointDir := joint.Unit().AddCoord(pos.Mult(-1))
 	crossProd := dir.X*jointDir.Y - dir.Y*jointDir.X
 	next := CellCoord{}
-	if fabs(crossProd) < FLOAT_ERROR {
+	if Fabs(crossProd) < FLOAT_ERROR {
 		// moving right into joint
 		next = next.Add(sgn(dir.X), sgn(dir.Y))
 	} else if crossProd < 0 {
@@ -141,7 +147,7 @@ func (c CellCoord) UnitCenter() UnitCoord {
 }
 
 func (c CellCoord) Bound(lx, ly, hx, hy int) CellCoord {
-	return CellCoord{ibound(c.X, lx, hx), ibound(c.Y, ly, hy)}
+	return CellCoord{Ibound(c.X, lx, hx), Ibound(c.Y, ly, hy)}
 }
 
 func (c CellCoord) Distance(To CellCoord) float32 {
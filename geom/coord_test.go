@@ -0,0 +1,54 @@
+package geom
+
+import (
+	"testing"
+)
+
+func TestNextCellCoord(t *testing.T) {
+	center := UnitCoord{5.5, 5.5}
+	cases := []struct {
+		pos, toward UnitCoord
+		want        CellCoord
+	}{
+		{center, UnitCoord{1, 0}, CellCoord{1, 0}},
+		{center, UnitCoord{-1, 0}, CellCoord{-1, 0}},
+		{center, UnitCoord{0, 1}, CellCoord{0, 1}},
+		{center, UnitCoord{0, -1}, CellCoord{0, -1}},
+		// straight into the corner
+		{center, UnitCoord{1, 1}, CellCoord{1, 1}},
+		{center, UnitCoord{-1, -1}, CellCoord{-1, -1}},
+		// shallow and steep lines cross the edge before the corner
+		{center, UnitCoord{1, 0.2}, CellCoord{1, 0}},
+		{center, UnitCoord{0.2, 1}, CellCoord{0, 1}},
+		{center, UnitCoord{-1, 0.2}, CellCoord{-1, 0}},
+		{center, UnitCoord{-0.2, -1}, CellCoord{0, -1}},
+		// off center unit near the bottom edge goes down even when moving mostly right
+		{UnitCoord{5.9, 5.97}, UnitCoord{1, 0.5}, CellCoord{0, 1}},
+	}
+	for _, c := range cases {
+		got := NextCellCoord(c.pos, NormTowardCoord(UnitCoord{}, c.toward))
+		if got != c.want {
+			t.Fatalf("from %s toward %s next cell is %s, want %s", c.pos, c.toward,
+				got, c.want)
+		}
+	}
+}
+
+func TestSiblings(t *testing.T) {
+	// neighbours go clockwise
+	for idx, n := range Neighbours {
+		next := Neighbours[(idx+1)%len(Neighbours)]
+		if n.ClockwiseSibling() != next {
+			t.Fatalf("clockwise sibling of %s is %s, want %s", n,
+				n.ClockwiseSibling(), next)
+		}
+		if next.CounterclockwiseSibling() != n {
+			t.Fatalf("counterclockwise sibling of %s is %s, want %s", next,
+				next.CounterclockwiseSibling(), n)
+		}
+	}
+	zero := CellCoord{}
+	if zero.ClockwiseSibling() != zero {
+		t.Error("zero direction has a sibling")
+	}
+}
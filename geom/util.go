@@ -0,0 +1,65 @@
+package geom
+
+func Fabs(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+func Fmin(v1, v2 float32) float32 {
+	if v1 < v2 {
+		return v1
+	}
+	return v2
+}
+
+func Fmax(v1, v2 float32) float32 {
+	if v1 > v2 {
+		return v1
+	}
+	return v2
+}
+
+func Fbound(value, low, high float32) float32 {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+
+	return value
+}
+
+func Imax(v1, v2 int) int {
+	if v1 > v2 {
+		return v1
+	}
+	return v2
+}
+
+func Ibound(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high-1 {
+		return high - 1
+	}
+
+	return value
+}
+
+func Iabs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+func sgn(value float32) int {
+	if value < 0 {
+		return -1
+	}
+	return 1
+}
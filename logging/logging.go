@@ -0,0 +1,228 @@
+// Package logging writes leveled text or json records of game subsystems to log file.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LOG_DEBUG = iota
+	LOG_INFO
+	LOG_WARN
+	LOG_ERROR
+)
+
+const (
+	LOG_TIME_FORMAT = "2006-01-02 15:04:05.000"
+)
+
+var logLevelNames = []string{
+	LOG_DEBUG: "debug",
+	LOG_INFO:  "info",
+	LOG_WARN:  "warn",
+	LOG_ERROR: "error",
+}
+
+func ParseLogLevel(name string) (int, error) {
+	for level, levelName := range logLevelNames {
+		if levelName == name {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level '%s', want one of %s", name,
+		strings.Join(logLevelNames, ", "))
+}
+
+// logSink is where all loggers write to
+var logSink = struct {
+	lock  sync.Mutex
+	out   io.Writer
+	level int
+	json  bool
+}{out: os.Stderr, level: LOG_INFO}
+
+// SetupLogging directs all records, including ones from standard log package, to out
+func SetupLogging(out io.Writer, level int, asJSON bool) {
+	logSink.lock.Lock()
+	logSink.out, logSink.level, logSink.json = out, level, asJSON
+	logSink.lock.Unlock()
+
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{NewLogger("go")})
+}
+
+type logField struct {
+	key   string
+	value interface{}
+}
+
+// Logger writes records of one subsystem. Fields like room, round or player id are attached
+// with With and written with every record
+type Logger struct {
+	subsystem string
+	fields    []logField
+}
+
+func NewLogger(subsystem string) *Logger {
+	return &Logger{subsystem: subsystem}
+}
+
+// With returns logger which adds field to every record
+func (l *Logger) With(key string, value interface{}) *Logger {
+	fields := make([]logField, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+	return &Logger{l.subsystem, append(fields, logField{key, value})}
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) { l.write(LOG_DEBUG, format, args) }
+func (l *Logger) Infof(format string, args ...interface{})  { l.write(LOG_INFO, format, args) }
+func (l *Logger) Warnf(format string, args ...interface{})  { l.write(LOG_WARN, format, args) }
+func (l *Logger) Errorf(format string, args ...interface{}) { l.write(LOG_ERROR, format, args) }
+
+// Fatalf logs error and exits
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.write(LOG_ERROR, format, args)
+	os.Exit(1)
+}
+
+func (l *Logger) write(level int, format string, args []interface{}) {
+	logSink.lock.Lock()
+	defer logSink.lock.Unlock()
+	if level < logSink.level {
+		return
+	}
+
+	now := time.Now()
+	msg := fmt.Sprintf(format, args...)
+	var line []byte
+	if logSink.json {
+		line = l.formatJSON(now, level, msg)
+	} else {
+		line = l.formatText(now, level, msg)
+	}
+	logSink.out.Write(line)
+}
+
+func (l *Logger) formatText(now time.Time, level int, msg string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %-5s %s: %s", now.Format(LOG_TIME_FORMAT),
+		strings.ToUpper(logLevelNames[level]), l.subsystem, msg)
+	for _, f := range l.fields {
+		value := fmt.Sprint(f.value)
+		if value == "" || strings.ContainsAny(value, " \"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %s=%s", f.key, value)
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+func (l *Logger) formatJSON(now time.Time, level int, msg string) []byte {
+	record := map[string]interface{}{
+		"time":      now.Format(time.RFC3339Nano),
+		"level":     logLevelNames[level],
+		"subsystem": l.subsystem,
+		"msg":       msg,
+	}
+	for _, f := range l.fields {
+		record[f.key] = f.value
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		line, _ = json.Marshal(map[string]string{"level": "error", "subsystem": "log",
+			"msg": "cannot encode record: " + err.Error()})
+	}
+	return append(line, '\n')
+}
+
+// stdLogWriter turns output of standard log package into records
+type stdLogWriter struct {
+	log *Logger
+}
+
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	w.log.Infof("%s", strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// rotatingFile is log file which is moved aside when it grows over maxSize. Up to keep
+// old files are kept as path.1 (newest) .. path.N
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	file *os.File
+	size int64
+}
+
+// OpenLogFile opens log for appending if it rotates, otherwise it starts from scratch
+func OpenLogFile(path string, maxSize int64, keep int) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize, keep: keep}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if maxSize > 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	rf.file = file
+	if fi, err := file.Stat(); err == nil {
+		rf.size = fi.Size()
+	}
+	return rf, nil
+}
+
+// Write is called with sink lock held
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, "log: cannot rotate:", err)
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) rotate() error {
+	rf.file.Close()
+	for idx := rf.keep - 1; idx > 0; idx-- {
+		os.Rename(fmt.Sprintf("%s.%d", rf.path, idx), fmt.Sprintf("%s.%d", rf.path, idx+1))
+	}
+	if rf.keep > 0 {
+		os.Rename(rf.path, rf.path+".1")
+	}
+
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		// keep writing somewhere
+		rf.file = os.Stderr
+		return err
+	}
+	rf.file, rf.size = file, 0
+	return nil
+}
+
+func (rf *rotatingFile) Close() error {
+	return rf.file.Close()
+}
+
+func LogPanic() {
+	if err := recover(); err != nil {
+		var stack = make([]byte, 4096)
+		n := runtime.Stack(stack, false)
+		NewLogger("main").Errorf("recovering err: %v\n%s", err, stack[:n])
+	}
+}
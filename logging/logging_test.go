@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureLog directs records to buffer until restore is called
+func captureLog(level int, asJSON bool) (*bytes.Buffer, func()) {
+	var buf bytes.Buffer
+	logSink.lock.Lock()
+	out, oldLevel, oldJSON := logSink.out, logSink.level, logSink.json
+	logSink.out, logSink.level, logSink.json = &buf, level, asJSON
+	logSink.lock.Unlock()
+	return &buf, func() {
+		logSink.lock.Lock()
+		logSink.out, logSink.level, logSink.json = out, oldLevel, oldJSON
+		logSink.lock.Unlock()
+	}
+}
+
+func TestLogText(t *testing.T) {
+	buf, restore := captureLog(LOG_INFO, false)
+	defer restore()
+
+	l := NewLogger("dispatcher").With("room", "main").With("round", 3)
+	l.Debugf("hidden")
+	l.With("player", 7).Infof("player %s joined", "alice")
+	l.With("name", "bob the zed").Warnf("odd name")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2:\n%s", len(lines), buf)
+	}
+	if want := "INFO  dispatcher: player alice joined room=main round=3 player=7"; !strings.HasSuffix(lines[0], want) {
+		t.Fatalf("record %q does not end with %q", lines[0], want)
+	}
+	if !strings.HasSuffix(lines[1], `WARN  dispatcher: odd name room=main round=3 name="bob the zed"`) {
+		t.Errorf("value with spaces is not quoted: %q", lines[1])
+	}
+}
+
+func TestLogJSON(t *testing.T) {
+	buf, restore := captureLog(LOG_DEBUG, true)
+	defer restore()
+
+	NewLogger("server").With("player", 2).Errorf("handshake failed: %s", "eof")
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("record is not json: %s: %q", err, buf)
+	}
+	for key, want := range map[string]interface{}{"level": "error", "subsystem": "server",
+		"msg": "handshake failed: eof", "player": 2.0} {
+		if record[key] != want {
+			t.Fatalf("record %s is %v, want %v", key, record[key], want)
+		}
+	}
+}
+
+func TestLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lgo-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lgo.log")
+	rf, err := OpenLogFile(path, 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	line := []byte(strings.Repeat("x", 59) + "\n")
+	for i := 0; i < 5; i++ {
+		rf.Write(line)
+	}
+
+	// every file holds one line, fifth one is the current
+	for _, name := range []string{"lgo.log", "lgo.log.1", "lgo.log.2"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != len(line) {
+			t.Fatalf("%s has %d bytes, want %d", name, len(data), len(line))
+		}
+	}
+	_, err = os.Stat(path + ".3")
+	if !os.IsNotExist(err) {
+		t.Error("more rotated files than asked to keep")
+	}
+}
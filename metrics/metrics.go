@@ -0,0 +1,201 @@
+// Package metrics keeps counters, gauges and histograms exported at /metrics of debug
+// listener in prometheus text format. Packages define their own metrics next to the code
+// updating them.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type metric interface {
+	writeTo(w io.Writer)
+}
+
+var metricRegistry struct {
+	lock    sync.Mutex
+	metrics []metric
+}
+
+func registerMetric(m metric) {
+	metricRegistry.lock.Lock()
+	defer metricRegistry.lock.Unlock()
+	metricRegistry.metrics = append(metricRegistry.metrics, m)
+}
+
+// WriteMetrics writes all metrics in prometheus text exposition format
+func WriteMetrics(w io.Writer) {
+	metricRegistry.lock.Lock()
+	metrics := append([]metric(nil), metricRegistry.metrics...)
+	metricRegistry.lock.Unlock()
+	for _, m := range metrics {
+		m.writeTo(w)
+	}
+}
+
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	WriteMetrics(w)
+}
+
+func writeMetricHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatMetricValue(v float64) string {
+	if math.IsInf(v, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatLabels returns {name="value",...} or empty string without labels
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var pairs []string
+	for idx, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, strconv.Quote(values[idx])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter only goes up, it has separate value for every combination of label values
+type Counter struct {
+	name, help string
+	labels     []string
+
+	lock   sync.Mutex
+	values map[string]float64 // by label values joined with zero byte
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	registerMetric(c)
+	return c
+}
+
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) Add(delta float64, values ...string) {
+	if len(values) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", c.name, len(c.labels),
+			len(values)))
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[strings.Join(values, "\x00")] += delta
+}
+
+func (c *Counter) Value(values ...string) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.values[strings.Join(values, "\x00")]
+}
+
+func (c *Counter) writeTo(w io.Writer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	writeMetricHeader(w, c.name, c.help, "counter")
+	var keys []string
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var values []string
+		if len(c.labels) > 0 {
+			values = strings.Split(key, "\x00")
+		}
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, values),
+			formatMetricValue(c.values[key]))
+	}
+}
+
+// Gauge is a single value which goes up and down
+type Gauge struct {
+	name, help string
+
+	lock  sync.Mutex
+	value float64
+}
+
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	registerMetric(g)
+	return g
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.value += delta
+}
+
+func (g *Gauge) Value() float64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.value
+}
+
+func (g *Gauge) writeTo(w io.Writer) {
+	writeMetricHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatMetricValue(g.Value()))
+}
+
+// Histogram counts durations in buckets, in seconds
+type Histogram struct {
+	name, help string
+	bounds     []time.Duration
+
+	lock   sync.Mutex
+	counts []uint64 // not cumulative, last one is +Inf
+	sum    time.Duration
+	count  uint64
+}
+
+func NewHistogram(name, help string, bounds []time.Duration) *Histogram {
+	h := &Histogram{name: name, help: help, bounds: bounds,
+		counts: make([]uint64, len(bounds)+1)}
+	registerMetric(h)
+	return h
+}
+
+func (h *Histogram) Observe(d time.Duration) {
+	bucket := 0
+	for bucket < len(h.bounds) && d > h.bounds[bucket] {
+		bucket++
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.counts[bucket]++
+	h.sum += d
+	h.count++
+}
+
+func (h *Histogram) writeTo(w io.Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	writeMetricHeader(w, h.name, h.help, "histogram")
+	var cumulative uint64
+	for idx, count := range h.counts {
+		cumulative += count
+		le := math.Inf(+1)
+		if idx < len(h.bounds) {
+			le = h.bounds[idx].Seconds()
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatMetricValue(le), cumulative)
+	}
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatMetricValue(h.sum.Seconds()))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
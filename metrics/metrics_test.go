@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	var out bytes.Buffer
+	c := &Counter{name: "c_total", help: "Check.", labels: []string{"rule", "outcome"},
+		values: make(map[string]float64)}
+	c.Inc("classic", "won")
+	c.Add(2, "wild-west", `"lost"`)
+	c.writeTo(&out)
+
+	h := &Histogram{name: "h_seconds", help: "Check.", bounds: []time.Duration{time.Millisecond, 2 * time.Millisecond},
+		counts: make([]uint64, 3)}
+	h.Observe(500 * time.Microsecond)
+	h.Observe(1500 * time.Microsecond)
+	h.Observe(time.Second)
+	h.writeTo(&out)
+
+	want := `# HELP c_total Check.
+# TYPE c_total counter
+c_total{rule="classic",outcome="won"} 1
+c_total{rule="wild-west",outcome="\"lost\""} 2
+# HELP h_seconds Check.
+# TYPE h_seconds histogram
+h_seconds_bucket{le="0.001"} 1
+h_seconds_bucket{le="0.002"} 2
+h_seconds_bucket{le="+Inf"} 3
+h_seconds_sum 1.002
+h_seconds_count 3
+`
+	if out.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,26 @@
+package netproto
+
+const (
+	CHAT_MAX_LEN = 120
+	CHAT_QUEUE   = 16
+)
+
+// ChatLine is sent by render when player says something. From is set by the side which
+// knows the player for sure: dispatcher for local render, remote render for network players
+type ChatLine struct {
+	From int
+	Team bool
+	Text string
+}
+
+func (c *ChatLine) MsgType() uint8 { return MSG_CHAT }
+
+// sender is never trusted, so only text and audience go over the wire
+func (c *ChatLine) encode(w *wireWriter) {
+	w.Bool(c.Team)
+	w.String(c.Text)
+}
+
+func decodeChatLine(r *wireReader) WireMessage {
+	return &ChatLine{From: -1, Team: r.Bool(), Text: r.String()}
+}
@@ -0,0 +1,203 @@
+package netproto
+
+import (
+	"encoding/binary"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+const (
+	DELTA_KEYFRAME_INTERVAL = 50
+	DELTA_HISTORY_LEN       = 32
+	DELTA_COORD_SCALE       = 32 // quantisation steps per cell
+)
+
+// UnitDelta carries units changed since snapshot with sequence number BaseSeq. Keyframes
+// carry all units and do not depend on any previous snapshot. Units which only moved are
+// packed into Moves as varints: id gap from previous moved unit, dx and dy in quants
+type UnitDelta struct {
+	Seq, BaseSeq int64
+	Keyframe     bool
+	Total        int
+	Units        []UnitUpdate
+	Moves        []byte
+	Squads       []engine.SquadView
+	Grens        []engine.GrenView
+	Fog          bool
+	XSize, YSize int
+}
+
+// UnitUpdate is quantised unit state
+type UnitUpdate struct {
+	Id    int32
+	Kind  int8
+	Dead  int8
+	Flags uint8
+	X, Y  uint16
+}
+
+// Ack is sent by client for every applied delta
+type Ack struct {
+	Seq int64
+}
+
+func quantiseCoord(v float32) uint16 {
+	return uint16(geom.Fbound(v*DELTA_COORD_SCALE, 0, 0xffff))
+}
+
+func dequantiseCoord(v uint16) float32 {
+	return float32(v) / DELTA_COORD_SCALE
+}
+
+func quantiseUnits(units []engine.UnitView) []UnitUpdate {
+	q := make([]UnitUpdate, len(units))
+	for idx, uv := range units {
+		q[idx] = UnitUpdate{int32(idx), uv.Kind, uv.Dead, uv.Flags, quantiseCoord(uv.Coord.X),
+			quantiseCoord(uv.Coord.Y)}
+	}
+	return q
+}
+
+// deltaHistory keeps recent full unit states by sequence number
+type deltaHistory map[int64][]UnitUpdate
+
+func (h deltaHistory) prune(before int64) {
+	for seq := range h {
+		if seq < before {
+			delete(h, seq)
+		}
+	}
+}
+
+// deltaEncoder builds deltas against last snapshot acknowledged by client
+type deltaEncoder struct {
+	history      deltaHistory
+	seq          int64
+	acked        int64
+	lastKeyframe int64
+}
+
+func newDeltaEncoder() *deltaEncoder {
+	e := &deltaEncoder{}
+	e.Reset()
+	return e
+}
+
+func (e *deltaEncoder) Reset() {
+	e.history = make(deltaHistory)
+	e.acked = -1
+	e.lastKeyframe = -DELTA_KEYFRAME_INTERVAL
+}
+
+func (e *deltaEncoder) Ack(seq int64) {
+	if _, ok := e.history[seq]; ok && seq > e.acked {
+		e.acked = seq
+		e.history.prune(seq)
+	}
+}
+
+func (e *deltaEncoder) Encode(s *engine.Snapshot) *UnitDelta {
+	e.seq++
+	cur := quantiseUnits(s.Units)
+	ud := &UnitDelta{Seq: e.seq, Total: len(cur), Squads: s.Squads, Grens: s.Grens, Fog: s.Fog,
+		XSize: s.XSize, YSize: s.YSize}
+
+	base, ok := e.history[e.acked]
+	if !ok || e.seq-e.lastKeyframe >= DELTA_KEYFRAME_INTERVAL {
+		ud.Keyframe = true
+		ud.Units = cur
+		e.lastKeyframe = e.seq
+	} else {
+		ud.BaseSeq = e.acked
+		var buf [binary.MaxVarintLen64]byte
+		var lastMoved int32
+		for idx := range cur {
+			u := cur[idx]
+			switch {
+			case idx >= len(base) || u.Kind != base[idx].Kind || u.Dead != base[idx].Dead ||
+				u.Flags != base[idx].Flags:
+				ud.Units = append(ud.Units, u)
+			case u != base[idx]:
+				n := binary.PutUvarint(buf[:], uint64(u.Id-lastMoved))
+				ud.Moves = append(ud.Moves, buf[:n]...)
+				n = binary.PutVarint(buf[:], int64(u.X)-int64(base[idx].X))
+				ud.Moves = append(ud.Moves, buf[:n]...)
+				n = binary.PutVarint(buf[:], int64(u.Y)-int64(base[idx].Y))
+				ud.Moves = append(ud.Moves, buf[:n]...)
+				lastMoved = u.Id
+			}
+		}
+	}
+
+	e.history[e.seq] = cur
+	// client is too slow to ack, forget about it and wait for keyframe
+	e.history.prune(e.seq - DELTA_HISTORY_LEN)
+	return ud
+}
+
+// deltaDecoder restores snapshots from deltas on the client side
+type deltaDecoder struct {
+	history deltaHistory
+}
+
+func newDeltaDecoder() *deltaDecoder {
+	return &deltaDecoder{history: make(deltaHistory)}
+}
+
+// Decode returns restored snapshot, or false if delta's base is unknown and it must be dropped
+func (d *deltaDecoder) Decode(ud *UnitDelta) (*engine.Snapshot, bool) {
+	var base []UnitUpdate
+	if !ud.Keyframe {
+		var ok bool
+		base, ok = d.history[ud.BaseSeq]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	cur := make([]UnitUpdate, ud.Total)
+	copy(cur, base)
+	for _, u := range ud.Units {
+		if int(u.Id) < len(cur) {
+			cur[u.Id] = u
+		}
+	}
+
+	var Id int64
+	moves := ud.Moves
+	for len(moves) > 0 {
+		gap, n := binary.Uvarint(moves)
+		if n <= 0 {
+			return nil, false
+		}
+		dx, nx := binary.Varint(moves[n:])
+		if nx <= 0 {
+			return nil, false
+		}
+		dy, ny := binary.Varint(moves[n+nx:])
+		if ny <= 0 {
+			return nil, false
+		}
+		moves = moves[n+nx+ny:]
+
+		Id += int64(gap)
+		if Id >= int64(len(cur)) {
+			return nil, false
+		}
+		cur[Id].X = uint16(int64(cur[Id].X) + dx)
+		cur[Id].Y = uint16(int64(cur[Id].Y) + dy)
+	}
+
+	d.history[ud.Seq] = cur
+	d.history.prune(ud.Seq - DELTA_HISTORY_LEN)
+
+	s := &engine.Snapshot{XSize: ud.XSize, YSize: ud.YSize, Squads: ud.Squads, Grens: ud.Grens,
+		Fog: ud.Fog}
+	s.Units = make([]engine.UnitView, len(cur))
+	for idx, u := range cur {
+		s.Units[idx] = engine.UnitView{Kind: u.Kind, Dead: u.Dead, Flags: u.Flags,
+			Coord: geom.UnitCoord{X: dequantiseCoord(u.X), Y: dequantiseCoord(u.Y)}}
+	}
+	return s, true
+}
@@ -0,0 +1,145 @@
+package netproto
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+const (
+	CHECK_DELTA_TICKS = 100
+)
+
+// countingWriter counts bytes written through it
+type countingWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count += n
+	return n, err
+}
+
+// loopbackPair returns both ends of tcp connection on loopback interface
+func loopbackPair() (server, client net.Conn, err error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		return nil, nil, err
+	}
+	server = <-accepted
+	if server == nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("failed to accept loopback connection")
+	}
+	return server, client, nil
+}
+
+// newCheckRound builds field populated like a real classic round
+func newCheckRound() *engine.Field {
+	f := engine.NewField(engine.FIELD_SIZE/4, engine.FIELD_SIZE/4, make(chan *engine.Field, 1))
+	rule := rules.AllRules["classic"]
+	engine.PlaceSquad(f, 0, 0, rule)
+	engine.PopulateField(f, rule)
+	return f
+}
+
+func TestDeltaLoopback(t *testing.T) {
+	full, err := measureLoopback(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := measureLoopback(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Logf("full snapshots: %d bytes/tick, deltas: %d bytes/tick",
+		full/CHECK_DELTA_TICKS, delta/CHECK_DELTA_TICKS)
+	if delta >= full {
+		t.Error("deltas are not smaller than full snapshots")
+	}
+}
+
+// measureLoopback runs round for a while sending updates to client over loopback and returns
+// number of bytes sent by server. Client acknowledges every delta in lockstep
+func measureLoopback(useDelta bool) (int, error) {
+	server, client, err := loopbackPair()
+	if err != nil {
+		return 0, err
+	}
+	defer server.Close()
+	defer client.Close()
+
+	counter := &countingWriter{w: server}
+
+	f := newCheckRound()
+	encoder, decoder := newDeltaEncoder(), newDeltaDecoder()
+
+	for tick := 0; tick < CHECK_DELTA_TICKS; tick++ {
+		f.Tick(int64(tick + 1))
+		snap := engine.NewSnapshot(f, 0)
+		snap.Cells = nil
+
+		var msg WireMessage = (*wireSnapshot)(snap)
+		if useDelta {
+			msg = encoder.Encode(snap)
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- WriteFrame(counter, msg)
+		}()
+
+		got, err := ReadFrame(client)
+		if err != nil {
+			return 0, err
+		}
+		if err := <-errs; err != nil {
+			return 0, err
+		}
+
+		if !useDelta {
+			continue
+		}
+
+		ud := got.(*UnitDelta)
+		restored, ok := decoder.Decode(ud)
+		if !ok {
+			return 0, fmt.Errorf("tick %d: delta base is lost", tick)
+		}
+		if !reflect.DeepEqual(quantiseUnits(restored.Units), quantiseUnits(snap.Units)) {
+			return 0, fmt.Errorf("tick %d: restored units differ from sent ones", tick)
+		}
+
+		go func() {
+			errs <- WriteFrame(client, &Ack{ud.Seq})
+		}()
+		ack, err := ReadFrame(server)
+		if err != nil {
+			return 0, err
+		}
+		if err := <-errs; err != nil {
+			return 0, err
+		}
+		encoder.Ack(ack.(*Ack).Seq)
+	}
+	return counter.count, nil
+}
@@ -0,0 +1,69 @@
+package netproto
+
+import (
+	"fmt"
+
+	"github.com/mechmind/life-goes-on/engine"
+)
+
+const (
+	MESSAGE_LEVEL_INFO = 1
+	MESSAGE_LEVEL_RULE = 2
+	MESSAGE_LEVEL_CHAT = 3
+	// status bar shows pace of the game: speed multiplier or paused
+	MESSAGE_LEVEL_SPEED = 4
+	MESSAGE_TTL         = 80
+)
+
+type Render interface {
+	HandleUpdate(*engine.Snapshot)
+	HandleGameState(engine.GameState)
+	HandleMessage(int, string)
+	AssignSquad(int, chan engine.Order)
+	HandleScoreboard(*Scoreboard)
+	HandleLeaderboard(*Leaderboard)
+	Spectate()
+	Reset()
+	AttachChat(int, chan ChatLine)
+}
+
+// NopRender stands in for players which lost connection
+type NopRender struct{}
+
+func (n NopRender) HandleUpdate(*engine.Snapshot)      {}
+func (n NopRender) HandleGameState(engine.GameState)   {}
+func (n NopRender) HandleMessage(int, string)          {}
+func (n NopRender) AssignSquad(int, chan engine.Order) {}
+func (n NopRender) HandleScoreboard(*Scoreboard)       {}
+func (n NopRender) HandleLeaderboard(*Leaderboard)     {}
+func (n NopRender) Spectate()                          {}
+func (n NopRender) Reset()                             {}
+func (n NopRender) AttachChat(int, chan ChatLine)      {}
+
+type Assignment struct {
+	Id     int
+	Orders chan engine.Order
+}
+
+func (a Assignment) String() string {
+	if a.Id >= 0 {
+		if a.Orders != nil {
+			return fmt.Sprintf("assgn{#%d with orders}", a.Id)
+		} else {
+			return fmt.Sprintf("assgn{#%d without orders}", a.Id)
+		}
+	} else {
+		return fmt.Sprintf("assgn{spectator}")
+	}
+}
+
+type Message struct {
+	Level   int
+	Content string
+	TTL     int // ticks message is shown fresh, not sent over the wire
+}
+
+type ChatBinding struct {
+	Pid  int
+	Chat chan ChatLine
+}
@@ -0,0 +1,85 @@
+package netproto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	PROFILE_NAME_MAX_LEN  = 16
+	LEADERBOARD_MAX_LINES = 10
+)
+
+type LeaderboardEntry struct {
+	Name         string
+	Wins         int
+	WinsByRule   map[string]int
+	ZedsKilled   int
+	BestSurvival int
+}
+
+type Leaderboard struct {
+	Entries []LeaderboardEntry
+}
+
+// sort.Interface implementation, best players first
+func (lb *Leaderboard) Len() int {
+	return len(lb.Entries)
+}
+
+func (lb *Leaderboard) Less(i, j int) bool {
+	ei, ej := lb.Entries[i], lb.Entries[j]
+	if ei.Wins != ej.Wins {
+		return ei.Wins > ej.Wins
+	}
+	if ei.ZedsKilled != ej.ZedsKilled {
+		return ei.ZedsKilled > ej.ZedsKilled
+	}
+	return ei.Name < ej.Name
+}
+
+func (lb *Leaderboard) Swap(i, j int) {
+	lb.Entries[i], lb.Entries[j] = lb.Entries[j], lb.Entries[i]
+}
+
+// Lines formats leaderboard as table, first line is a header
+func (lb *Leaderboard) Lines() []string {
+	format := "%3s %-16s %5s %6s %9s  %s"
+	lines := []string{fmt.Sprintf(format, "#", "name", "wins", "Zs", "survived", "wins by rule")}
+	for idx, e := range lb.Entries {
+		if idx == LEADERBOARD_MAX_LINES {
+			break
+		}
+		var rules []string
+		for rule := range e.WinsByRule {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		var byRule []string
+		for _, rule := range rules {
+			byRule = append(byRule, fmt.Sprintf("%s:%d", rule, e.WinsByRule[rule]))
+		}
+		lines = append(lines, fmt.Sprintf(format, fmt.Sprint(idx+1), e.Name, fmt.Sprint(e.Wins),
+			fmt.Sprint(e.ZedsKilled), formatSeconds(e.BestSurvival), strings.Join(byRule, " ")))
+	}
+	return lines
+}
+
+func formatSeconds(secs int) string {
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+// SanitizeName makes player name safe to display
+func SanitizeName(name string) string {
+	var clean []rune
+	for _, r := range name {
+		if r > ' ' && r < 0x7f {
+			clean = append(clean, r)
+		}
+		if len(clean) == PROFILE_NAME_MAX_LEN {
+			break
+		}
+	}
+	return string(clean)
+}
@@ -0,0 +1,39 @@
+package netproto
+
+import (
+	"fmt"
+
+	"github.com/mechmind/life-goes-on/engine"
+)
+
+// LobbyInfo describes current round for clients which are about to join
+type LobbyInfo struct {
+	Rule       string
+	Players    int
+	Needed     int
+	MaxPlayers int
+	State      int
+}
+
+func (l LobbyInfo) String() string {
+	var state string
+	switch {
+	case l.State&engine.GAME_WAIT > 0:
+		state = fmt.Sprintf("waiting for players, %d needed", l.Needed)
+	case l.State&engine.GAME_RUNNING > 0:
+		state = "round is running"
+	case l.State&engine.GAME_OVER > 0:
+		state = "round is over"
+	}
+	return fmt.Sprintf("rule %s, %d/%d players, %s", l.Rule, l.Players, l.MaxPlayers, state)
+}
+
+// RoomInfo describes room in room list
+type RoomInfo struct {
+	Name  string
+	Lobby LobbyInfo
+}
+
+func (r RoomInfo) String() string {
+	return fmt.Sprintf("%s: %s", r.Name, r.Lobby)
+}
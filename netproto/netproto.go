@@ -0,0 +1,800 @@
+// Package netproto is the wire protocol between server and remote players, and
+// the frontend interface both local and remote renders implement.
+package netproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/metrics"
+)
+
+// Wire protocol.
+//
+// Client opens connection with 4-byte header: 'L', 'G', 'O', PROTO_FRAMED. Everything after
+// that is a stream of frames:
+//
+//   uint32 length (big endian, covers type and payload) | uint8 message type | payload
+//
+// Payloads use varints for integers, length-prefixed strings and little endian float32.
+// Every message kind below has fixed schema. Adding field means bumping PROTO_VERSION.
+//
+// First frame from client is MSG_HELLO with its protocol version, server answers with
+// MSG_WELCOME or, if versions differ, MSG_REJECT with human readable reason. Hello which
+// only asks for rooms is answered with MSG_ROOM_LIST and connection is closed. Kicked client
+// gets MSG_REJECT at any time later, client must not reconnect after it.
+
+const (
+	PROTO_VERSION        = 11
+	PROTO_FRAMED         = 0xff
+	PROTO_MAX_FRAME_SIZE = 16 * 1024 * 1024
+	// hello is read before client is checked, keep it small
+	PROTO_MAX_HELLO_SIZE = 4 * 1024
+	// server keeps squad of disconnected player that long, client retries as long
+	SESSION_GRACE = 30 * time.Second
+)
+
+const (
+	MSG_HELLO = iota + 1
+	MSG_WELCOME
+	MSG_REJECT
+	MSG_SNAPSHOT
+	MSG_DELTA
+	MSG_ASSIGNMENT
+	MSG_GAME_STATE
+	MSG_RESET
+	MSG_MESSAGE
+	MSG_SCOREBOARD
+	MSG_LEADERBOARD
+	MSG_ORDER
+	MSG_ACK
+	MSG_PING
+	MSG_PONG
+	MSG_CHAT
+	MSG_ROOM_LIST
+)
+
+var ProtoHeader = [4]byte{'L', 'G', 'O', PROTO_FRAMED}
+
+var errBadPayload = errors.New("proto: malformed payload")
+
+var (
+	MetricDropped = metrics.NewCounter("lgo_dropped_updates_total",
+		"Updates dropped because render could not take them in time.", "render", "kind")
+	metricInvalidOrders = metrics.NewCounter("lgo_invalid_orders_total",
+		"Orders from clients dropped because of unknown type or coord off the field.")
+	metricEncodeErrors = metrics.NewCounter("lgo_encode_errors_total",
+		"Messages which could not be encoded into frame.", "type")
+)
+
+type WireMessage interface {
+	MsgType() uint8
+	encode(w *wireWriter)
+}
+
+// snapshots, game states and orders belong to the game, they go over the wire as these
+type (
+	wireSnapshot  engine.Snapshot
+	wireGameState engine.GameState
+	wireOrder     engine.Order
+)
+
+var wireDecoders = map[uint8]func(r *wireReader) WireMessage{
+	MSG_HELLO:       decodeHello,
+	MSG_WELCOME:     decodeWelcome,
+	MSG_REJECT:      decodeReject,
+	MSG_SNAPSHOT:    decodeSnapshot,
+	MSG_DELTA:       decodeUnitDelta,
+	MSG_ASSIGNMENT:  decodeAssignment,
+	MSG_GAME_STATE:  decodeGameState,
+	MSG_RESET:       decodeReset,
+	MSG_MESSAGE:     decodeMessage,
+	MSG_SCOREBOARD:  decodeScoreboard,
+	MSG_LEADERBOARD: decodeLeaderboard,
+	MSG_ORDER:       decodeOrder,
+	MSG_ACK:         decodeAck,
+	MSG_PING:        decodePing,
+	MSG_PONG:        decodePong,
+	MSG_CHAT:        decodeChatLine,
+	MSG_ROOM_LIST:   decodeRoomList,
+}
+
+// EncodeFrame returns message packed into frame
+func EncodeFrame(m WireMessage) []byte {
+	w := &wireWriter{buf: make([]byte, 5, 64)}
+	w.buf[4] = m.MsgType()
+	m.encode(w)
+	binary.BigEndian.PutUint32(w.buf, uint32(len(w.buf)-4))
+	return w.buf
+}
+
+func WriteFrame(w io.Writer, m WireMessage) error {
+	_, err := writeFrame(w, m)
+	return err
+}
+
+// writeFrame returns number of bytes written. Frame which peer would refuse is not sent
+func writeFrame(w io.Writer, m WireMessage) (int, error) {
+	frame := EncodeFrame(m)
+	if len(frame)-4 > PROTO_MAX_FRAME_SIZE {
+		metricEncodeErrors.Inc(strconv.Itoa(int(m.MsgType())))
+		return 0, fmt.Errorf("proto: message type %d does not fit into frame: %d bytes",
+			m.MsgType(), len(frame))
+	}
+	return w.Write(frame)
+}
+
+func ReadFrame(r io.Reader) (WireMessage, error) {
+	return ReadFrameLimit(r, PROTO_MAX_FRAME_SIZE)
+}
+
+// ReadFrameLimit reads frame refusing ones longer than max before allocating them
+func ReadFrameLimit(r io.Reader, max uint32) (WireMessage, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length == 0 || length > max {
+		return nil, fmt.Errorf("proto: invalid frame length %d", length)
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+
+	decoder, ok := wireDecoders[frame[0]]
+	if !ok {
+		return nil, fmt.Errorf("proto: unknown message type %d", frame[0])
+	}
+	wr := &wireReader{buf: frame[1:]}
+	m := decoder(wr)
+	if wr.err != nil {
+		return nil, fmt.Errorf("proto: cannot decode message type %d: %s", frame[0], wr.err)
+	}
+	return m, nil
+}
+
+// wireWriter appends primitive values to the buffer
+type wireWriter struct {
+	buf []byte
+}
+
+func (w *wireWriter) Uint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	w.buf = append(w.buf, tmp[:n]...)
+}
+
+func (w *wireWriter) Int(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	w.buf = append(w.buf, tmp[:n]...)
+}
+
+func (w *wireWriter) Byte(v uint8) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *wireWriter) Bool(v bool) {
+	if v {
+		w.Byte(1)
+	} else {
+		w.Byte(0)
+	}
+}
+
+func (w *wireWriter) Float(v float32) {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], math.Float32bits(v))
+	w.buf = append(w.buf, tmp[:]...)
+}
+
+func (w *wireWriter) Bytes(v []byte) {
+	w.Uint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *wireWriter) String(v string) {
+	w.Bytes([]byte(v))
+}
+
+func (w *wireWriter) Coord(c geom.UnitCoord) {
+	w.Float(c.X)
+	w.Float(c.Y)
+}
+
+// wireReader reads primitive values, first error sticks and zero values are returned after it
+type wireReader struct {
+	buf []byte
+	err error
+}
+
+func (r *wireReader) fail() {
+	if r.err == nil {
+		r.err = errBadPayload
+	}
+	r.buf = nil
+}
+
+func (r *wireReader) Uint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *wireReader) Int() int64 {
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+// Len reads length of collection, every item takes at least one byte
+func (r *wireReader) Len() int {
+	v := r.Uint()
+	if v > uint64(len(r.buf)) {
+		r.fail()
+		return 0
+	}
+	return int(v)
+}
+
+func (r *wireReader) Byte() uint8 {
+	if len(r.buf) < 1 {
+		r.fail()
+		return 0
+	}
+	v := r.buf[0]
+	r.buf = r.buf[1:]
+	return v
+}
+
+func (r *wireReader) Bool() bool {
+	return r.Byte() != 0
+}
+
+func (r *wireReader) Float() float32 {
+	if len(r.buf) < 4 {
+		r.fail()
+		return 0
+	}
+	v := math.Float32frombits(binary.LittleEndian.Uint32(r.buf))
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *wireReader) Bytes() []byte {
+	n := r.Len()
+	if r.err != nil {
+		return nil
+	}
+	v := append([]byte(nil), r.buf[:n]...)
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *wireReader) String() string {
+	return string(r.Bytes())
+}
+
+func (r *wireReader) Coord() geom.UnitCoord {
+	x := r.Float()
+	return geom.UnitCoord{X: x, Y: r.Float()}
+}
+
+// handshake messages
+
+// Hello carries session token when client reconnects. Room is joined or, if it does not
+// exist and Rule is set, created. List asks for room list instead of joining
+type Hello struct {
+	Version int
+	Name    string
+	Token   string
+	Room    string
+	Rule    string
+	List    bool
+}
+
+func (m *Hello) MsgType() uint8 { return MSG_HELLO }
+
+func (m *Hello) encode(w *wireWriter) {
+	w.Uint(uint64(m.Version))
+	w.String(m.Name)
+	w.String(m.Token)
+	w.String(m.Room)
+	w.String(m.Rule)
+	w.Bool(m.List)
+}
+
+func decodeHello(r *wireReader) WireMessage {
+	return &Hello{int(r.Uint()), r.String(), r.String(), r.String(), r.String(), r.Bool()}
+}
+
+func encodeLobby(w *wireWriter, l LobbyInfo) {
+	w.String(l.Rule)
+	w.Uint(uint64(l.Players))
+	w.Uint(uint64(l.Needed))
+	w.Uint(uint64(l.MaxPlayers))
+	w.Uint(uint64(l.State))
+}
+
+func decodeLobby(r *wireReader) LobbyInfo {
+	return LobbyInfo{r.String(), int(r.Uint()), int(r.Uint()), int(r.Uint()), int(r.Uint())}
+}
+
+// Welcome tells client about the server and the round it joins
+type Welcome struct {
+	Version int
+	Server  string
+	Room    string
+	Lobby   LobbyInfo
+	Token   string
+}
+
+func (m *Welcome) MsgType() uint8 { return MSG_WELCOME }
+
+func (m *Welcome) encode(w *wireWriter) {
+	w.Uint(uint64(m.Version))
+	w.String(m.Server)
+	w.String(m.Room)
+	encodeLobby(w, m.Lobby)
+	w.String(m.Token)
+}
+
+func decodeWelcome(r *wireReader) WireMessage {
+	m := &Welcome{Version: int(r.Uint()), Server: r.String(), Room: r.String()}
+	m.Lobby = decodeLobby(r)
+	m.Token = r.String()
+	return m
+}
+
+func (m *Welcome) String() string {
+	return fmt.Sprintf("connected to '%s' (protocol %d), room %s: %s", m.Server, m.Version,
+		m.Room, m.Lobby)
+}
+
+type RoomList struct {
+	Rooms []RoomInfo
+}
+
+func (m *RoomList) MsgType() uint8 { return MSG_ROOM_LIST }
+
+func (m *RoomList) encode(w *wireWriter) {
+	w.Uint(uint64(len(m.Rooms)))
+	for _, room := range m.Rooms {
+		w.String(room.Name)
+		encodeLobby(w, room.Lobby)
+	}
+}
+
+func decodeRoomList(r *wireReader) WireMessage {
+	m := &RoomList{}
+	count := r.Len()
+	for i := 0; i < count && r.err == nil; i++ {
+		m.Rooms = append(m.Rooms, RoomInfo{r.String(), decodeLobby(r)})
+	}
+	return m
+}
+
+const (
+	REJECT_VERSION = iota + 1
+	REJECT_FULL
+	REJECT_BANNED
+	// no such room or it can not be created
+	REJECT_ROOM
+	// sent in the middle of the game right before server closes connection
+	REJECT_KICKED
+)
+
+type Reject struct {
+	Code   int
+	Reason string
+}
+
+func (m *Reject) MsgType() uint8 { return MSG_REJECT }
+
+func (m *Reject) encode(w *wireWriter) {
+	w.Uint(uint64(m.Code))
+	w.String(m.Reason)
+}
+
+func decodeReject(r *wireReader) WireMessage {
+	return &Reject{int(r.Uint()), r.String()}
+}
+
+// Reject is returned to client code as error
+func (m *Reject) Error() string {
+	return "server rejected connection: " + m.Reason
+}
+
+// CheckVersion returns error if client speaks other protocol version
+func CheckVersion(h *Hello) error {
+	if h.Version != PROTO_VERSION {
+		return fmt.Errorf("protocol version mismatch: server speaks %d, client %d",
+			PROTO_VERSION, h.Version)
+	}
+	return nil
+}
+
+// game messages
+
+func (ws *wireSnapshot) MsgType() uint8 { return MSG_SNAPSHOT }
+
+// cells are run-length encoded, terrain is mostly empty
+func (ws *wireSnapshot) encode(w *wireWriter) {
+	s := (*engine.Snapshot)(ws)
+	w.Uint(uint64(s.XSize))
+	w.Uint(uint64(s.YSize))
+
+	var runs []int
+	for idx := range s.Cells {
+		if idx > 0 && s.Cells[idx] == s.Cells[idx-1] {
+			runs[len(runs)-1]++
+		} else {
+			runs = append(runs, 1)
+		}
+	}
+	w.Uint(uint64(len(runs)))
+	var idx int
+	for _, run := range runs {
+		w.Uint(uint64(run))
+		encodeCell(w, s.Cells[idx])
+		idx += run
+	}
+
+	w.Uint(uint64(len(s.Units)))
+	for _, uv := range s.Units {
+		w.Int(int64(uv.Kind))
+		w.Int(int64(uv.Dead))
+		w.Byte(uv.Flags)
+		w.Coord(uv.Coord)
+	}
+	encodeSquads(w, s.Squads)
+	encodeGrens(w, s.Grens)
+	w.Bool(s.Fog)
+}
+
+func decodeSnapshot(r *wireReader) WireMessage {
+	s := &engine.Snapshot{XSize: int(r.Uint()), YSize: int(r.Uint())}
+
+	runs := r.Len()
+	for i := 0; i < runs && r.err == nil; i++ {
+		run := int(r.Uint())
+		cell := decodeCell(r)
+		if len(s.Cells)+run > s.XSize*s.YSize {
+			r.fail()
+			break
+		}
+		for j := 0; j < run; j++ {
+			s.Cells = append(s.Cells, cell)
+		}
+	}
+
+	units := r.Len()
+	for i := 0; i < units && r.err == nil; i++ {
+		kind, dead, flags := int8(r.Int()), int8(r.Int()), r.Byte()
+		s.Units = append(s.Units, engine.UnitView{Kind: kind, Dead: dead, Flags: flags, Coord: r.Coord()})
+	}
+	s.Squads = decodeSquads(r)
+	s.Grens = decodeGrens(r)
+	s.Fog = r.Bool()
+	return (*wireSnapshot)(s)
+}
+
+const (
+	CELL_PASSABLE = 1 << iota
+	CELL_OPAQUE
+)
+
+func encodeCell(w *wireWriter, c engine.Cell) {
+	w.Int(int64(c.Elevation))
+	w.Byte(c.Slopes)
+	w.Uint(uint64(c.Type))
+	w.Float(c.Health)
+	var flags uint8
+	if c.Passable {
+		flags |= CELL_PASSABLE
+	}
+	if c.Opaque {
+		flags |= CELL_OPAQUE
+	}
+	w.Byte(flags)
+}
+
+func decodeCell(r *wireReader) engine.Cell {
+	var c engine.Cell
+	c.Elevation = int16(r.Int())
+	c.Slopes = r.Byte()
+	c.Type = int(r.Uint())
+	c.Health = r.Float()
+	flags := r.Byte()
+	c.Passable = flags&CELL_PASSABLE > 0
+	c.Opaque = flags&CELL_OPAQUE > 0
+	return c
+}
+
+func encodeSquads(w *wireWriter, squads []engine.SquadView) {
+	w.Uint(uint64(len(squads)))
+	for _, sv := range squads {
+		w.Int(int64(sv.Pid))
+		w.Uint(uint64(len(sv.Units)))
+		for _, Id := range sv.Units {
+			w.Uint(uint64(Id))
+		}
+		w.Uint(uint64(len(sv.Morale)))
+		for _, m := range sv.Morale {
+			w.Float(m)
+		}
+	}
+}
+
+func decodeSquads(r *wireReader) []engine.SquadView {
+	var squads []engine.SquadView
+	count := r.Len()
+	for i := 0; i < count && r.err == nil; i++ {
+		sv := engine.SquadView{Pid: int(r.Int())}
+		units := r.Len()
+		for j := 0; j < units && r.err == nil; j++ {
+			sv.Units = append(sv.Units, int(r.Uint()))
+		}
+		morale := r.Len()
+		for j := 0; j < morale && r.err == nil; j++ {
+			sv.Morale = append(sv.Morale, r.Float())
+		}
+		squads = append(squads, sv)
+	}
+	return squads
+}
+
+func encodeGrens(w *wireWriter, grens []engine.GrenView) {
+	w.Uint(uint64(len(grens)))
+	for _, g := range grens {
+		w.Coord(g.From)
+		w.Coord(g.To)
+		w.Int(int64(g.Booming))
+	}
+}
+
+func decodeGrens(r *wireReader) []engine.GrenView {
+	var grens []engine.GrenView
+	count := r.Len()
+	for i := 0; i < count && r.err == nil; i++ {
+		from, to := r.Coord(), r.Coord()
+		grens = append(grens, engine.GrenView{From: from, To: to, Booming: int8(r.Int())})
+	}
+	return grens
+}
+
+func (ud *UnitDelta) MsgType() uint8 { return MSG_DELTA }
+
+func (ud *UnitDelta) encode(w *wireWriter) {
+	w.Int(ud.Seq)
+	w.Int(ud.BaseSeq)
+	w.Bool(ud.Keyframe)
+	w.Uint(uint64(ud.Total))
+	w.Uint(uint64(len(ud.Units)))
+	for _, u := range ud.Units {
+		w.Uint(uint64(u.Id))
+		w.Int(int64(u.Kind))
+		w.Int(int64(u.Dead))
+		w.Byte(u.Flags)
+		w.Uint(uint64(u.X))
+		w.Uint(uint64(u.Y))
+	}
+	w.Bytes(ud.Moves)
+	encodeSquads(w, ud.Squads)
+	encodeGrens(w, ud.Grens)
+	w.Bool(ud.Fog)
+	w.Uint(uint64(ud.XSize))
+	w.Uint(uint64(ud.YSize))
+}
+
+func decodeUnitDelta(r *wireReader) WireMessage {
+	ud := &UnitDelta{Seq: r.Int(), BaseSeq: r.Int(), Keyframe: r.Bool(), Total: int(r.Uint())}
+	units := r.Len()
+	for i := 0; i < units && r.err == nil; i++ {
+		var u UnitUpdate
+		u.Id = int32(r.Uint())
+		u.Kind = int8(r.Int())
+		u.Dead = int8(r.Int())
+		u.Flags = r.Byte()
+		u.X = uint16(r.Uint())
+		u.Y = uint16(r.Uint())
+		ud.Units = append(ud.Units, u)
+	}
+	ud.Moves = r.Bytes()
+	ud.Squads = decodeSquads(r)
+	ud.Grens = decodeGrens(r)
+	ud.Fog = r.Bool()
+	ud.XSize = int(r.Uint())
+	ud.YSize = int(r.Uint())
+	return ud
+}
+
+// orders channel is local and never sent
+func (a *Assignment) MsgType() uint8 { return MSG_ASSIGNMENT }
+
+func (a *Assignment) encode(w *wireWriter) {
+	w.Int(int64(a.Id))
+}
+
+func decodeAssignment(r *wireReader) WireMessage {
+	return &Assignment{Id: int(r.Int())}
+}
+
+func (g *wireGameState) MsgType() uint8 { return MSG_GAME_STATE }
+
+func (g *wireGameState) encode(w *wireWriter) {
+	w.Uint(uint64(g.State))
+	w.Int(int64(g.Player))
+}
+
+func decodeGameState(r *wireReader) WireMessage {
+	return &wireGameState{int(r.Uint()), int(r.Int())}
+}
+
+// ResetMsg tells client that new round begins
+type ResetMsg struct{}
+
+func (m *ResetMsg) MsgType() uint8 { return MSG_RESET }
+
+func (m *ResetMsg) encode(w *wireWriter) {}
+
+func decodeReset(r *wireReader) WireMessage {
+	return &ResetMsg{}
+}
+
+func (m *Message) MsgType() uint8 { return MSG_MESSAGE }
+
+func (m *Message) encode(w *wireWriter) {
+	w.Uint(uint64(m.Level))
+	w.String(m.Content)
+}
+
+func decodeMessage(r *wireReader) WireMessage {
+	return &Message{Level: int(r.Uint()), Content: r.String()}
+}
+
+func (sb *Scoreboard) MsgType() uint8 { return MSG_SCOREBOARD }
+
+func (sb *Scoreboard) encode(w *wireWriter) {
+	w.String(sb.Rule)
+	w.Uint(uint64(len(sb.Players)))
+	for _, p := range sb.Players {
+		w.Int(int64(p.Pid))
+		w.String(p.Name)
+		for _, v := range []int{p.ZedsKilled, p.DamselsSaved, p.DamselsShot, p.SoldiersLost,
+			p.GrensThrown, p.Shots, p.Hits} {
+			w.Uint(uint64(v))
+		}
+	}
+}
+
+func decodeScoreboard(r *wireReader) WireMessage {
+	sb := &Scoreboard{Rule: r.String()}
+	count := r.Len()
+	for i := 0; i < count && r.err == nil; i++ {
+		p := PlayerStats{Pid: int(r.Int()), Name: r.String()}
+		for _, v := range []*int{&p.ZedsKilled, &p.DamselsSaved, &p.DamselsShot,
+			&p.SoldiersLost, &p.GrensThrown, &p.Shots, &p.Hits} {
+			*v = int(r.Uint())
+		}
+		sb.Players = append(sb.Players, p)
+	}
+	return sb
+}
+
+func (lb *Leaderboard) MsgType() uint8 { return MSG_LEADERBOARD }
+
+func (lb *Leaderboard) encode(w *wireWriter) {
+	w.Uint(uint64(len(lb.Entries)))
+	for _, e := range lb.Entries {
+		w.String(e.Name)
+		w.Uint(uint64(e.Wins))
+		// map order is random, keep frames stable
+		var rules []string
+		for rule := range e.WinsByRule {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		w.Uint(uint64(len(rules)))
+		for _, rule := range rules {
+			w.String(rule)
+			w.Uint(uint64(e.WinsByRule[rule]))
+		}
+		w.Uint(uint64(e.ZedsKilled))
+		w.Uint(uint64(e.BestSurvival))
+	}
+}
+
+func decodeLeaderboard(r *wireReader) WireMessage {
+	lb := &Leaderboard{}
+	count := r.Len()
+	for i := 0; i < count && r.err == nil; i++ {
+		e := LeaderboardEntry{Name: r.String(), Wins: int(r.Uint())}
+		rules := r.Len()
+		if rules > 0 {
+			e.WinsByRule = make(map[string]int, rules)
+		}
+		for j := 0; j < rules && r.err == nil; j++ {
+			rule := r.String()
+			e.WinsByRule[rule] = int(r.Uint())
+		}
+		e.ZedsKilled = int(r.Uint())
+		e.BestSurvival = int(r.Uint())
+		lb.Entries = append(lb.Entries, e)
+	}
+	return lb
+}
+
+func (o *wireOrder) MsgType() uint8 { return MSG_ORDER }
+
+func (o *wireOrder) encode(w *wireWriter) {
+	w.Uint(uint64(o.Order))
+	w.Int(int64(o.Coord.X))
+	w.Int(int64(o.Coord.Y))
+}
+
+func decodeOrder(r *wireReader) WireMessage {
+	o := &wireOrder{Order: int(r.Uint())}
+	o.Coord.X = int(r.Int())
+	o.Coord.Y = int(r.Int())
+	return o
+}
+
+func (a *Ack) MsgType() uint8 { return MSG_ACK }
+
+func (a *Ack) encode(w *wireWriter) {
+	w.Int(a.Seq)
+}
+
+func decodeAck(r *wireReader) WireMessage {
+	return &Ack{r.Int()}
+}
+
+// Ping is sent by server to measure latency and keep connection alive, client echoes it
+// back as Pong. Time is server clock in nanoseconds
+type Ping struct {
+	Seq, Time int64
+}
+
+type Pong Ping
+
+func (p *Ping) MsgType() uint8 { return MSG_PING }
+
+func (p *Ping) encode(w *wireWriter) {
+	w.Int(p.Seq)
+	w.Int(p.Time)
+}
+
+func decodePing(r *wireReader) WireMessage {
+	return &Ping{r.Int(), r.Int()}
+}
+
+func (p *Pong) MsgType() uint8 { return MSG_PONG }
+
+func (p *Pong) encode(w *wireWriter) {
+	w.Int(p.Seq)
+	w.Int(p.Time)
+}
+
+func decodePong(r *wireReader) WireMessage {
+	return &Pong{r.Int(), r.Int()}
+}
@@ -0,0 +1,214 @@
+package netproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+// Golden files hold hex of encoded frames for every message kind, so any change of the wire
+// format shows up as failed test. After intended change bump PROTO_VERSION and rewrite them
+// with go test -update.
+
+const (
+	GOLDEN_DIR        = "testdata"
+	GOLDEN_LINE_BYTES = 32
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+type goldenMessage struct {
+	name string
+	msg  WireMessage
+}
+
+func goldenMessages() []goldenMessage {
+	cells := make([]engine.Cell, 16)
+	cells[5] = engine.Cell{Elevation: -2, Slopes: 3, Object: engine.Object{Type: engine.OBJECT_WALL, Health: 40, Passable: false, Opaque: true}}
+	cells[6] = engine.Cell{Object: engine.Object{Type: engine.OBJECT_BUSH, Health: 5, Passable: true, Opaque: false}}
+	for idx := range cells {
+		if cells[idx].Type == engine.OBJECT_EMPTY {
+			cells[idx].Passable = true
+		}
+	}
+
+	return []goldenMessage{
+		{"hello", &Hello{PROTO_VERSION, "alice", "00112233445566778899aabbccddeeff",
+			"zoo", "wild-west", false}},
+		{"welcome", &Welcome{PROTO_VERSION, "lgo", "zoo", LobbyInfo{"classic", 1, 1, 4, engine.GAME_WAIT},
+			"00112233445566778899aabbccddeeff"}},
+		{"room-list", &RoomList{[]RoomInfo{
+			{"main", LobbyInfo{"classic", 3, 0, 4, engine.GAME_RUNNING}},
+			{"zoo", LobbyInfo{"wild-west", 1, 1, 2, engine.GAME_WAIT}},
+		}}},
+		{"reject", &Reject{REJECT_FULL, "server is full, 16 players connected"}},
+		{"snapshot", &wireSnapshot{XSize: 4, YSize: 4, Cells: cells,
+			Units: []engine.UnitView{
+				{Kind: engine.KIND_SOLDIER, Dead: engine.KIND_NONE, Flags: 0, Coord: geom.UnitCoord{X: 1.5, Y: 2.5}},
+				{Kind: engine.KIND_CORPSE, Dead: engine.KIND_ZED, Flags: 0, Coord: geom.UnitCoord{X: 3.25, Y: 0.5}},
+				{Kind: engine.KIND_DAMSEL, Dead: engine.KIND_NONE, Flags: engine.UNIT_FLAG_FAST, Coord: geom.UnitCoord{X: 0.5, Y: 0.5}},
+			},
+			Squads: []engine.SquadView{{Pid: 0, Units: []int{0}, Morale: []float32{75}}},
+			Grens:  []engine.GrenView{{From: geom.UnitCoord{X: 1.5, Y: 2.5}, To: geom.UnitCoord{X: 3, Y: 3}, Booming: 2}},
+			Fog:    true}},
+		{"delta", &UnitDelta{Seq: 12, BaseSeq: 10, Total: 3,
+			Units:  []UnitUpdate{{1, engine.KIND_CORPSE, engine.KIND_ZED, 0, 104, 16}},
+			Moves:  []byte{0, 2, 1, 2, 3, 0},
+			Squads: []engine.SquadView{{Pid: 1, Units: []int{0, 2}}},
+			XSize:  4, YSize: 4}},
+		{"assignment", &Assignment{Id: 2}},
+		{"game-state", &wireGameState{engine.GAME_OVER, 1}},
+		{"reset", &ResetMsg{}},
+		{"message", &Message{Level: MESSAGE_LEVEL_INFO, Content: "player 1 joined"}},
+		{"scoreboard", &Scoreboard{Rule: "classic", Players: []PlayerStats{
+			{Pid: 0, Name: "alice", ZedsKilled: 14, DamselsSaved: 3, DamselsShot: 1,
+				SoldiersLost: 2, GrensThrown: 4, Shots: 310, Hits: 120}}}},
+		{"leaderboard", &Leaderboard{Entries: []LeaderboardEntry{
+			{Name: "alice", Wins: 3, WinsByRule: map[string]int{"classic": 2, "versus": 1},
+				ZedsKilled: 250, BestSurvival: 320},
+			{Name: "bob"}}}},
+		{"order", &wireOrder{engine.ORDER_MOVE, geom.CellCoord{X: 17, Y: 42}}},
+		{"ack", &Ack{12}},
+		{"ping", &Ping{3, 1500000000123456789}},
+		{"pong", &Pong{3, 1500000000123456789}},
+		{"chat", &ChatLine{From: -1, Team: true, Text: "zeds at the north gate"}},
+	}
+}
+
+func TestProtoGolden(t *testing.T) {
+	for _, gm := range goldenMessages() {
+		frame := EncodeFrame(gm.msg)
+		path := filepath.Join(GOLDEN_DIR, gm.name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(path, []byte(formatGolden(frame)), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden, err := hex.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		if !bytes.Equal(frame, golden) {
+			t.Errorf("%s: encoded frame differs from golden one", gm.name)
+			continue
+		}
+
+		decoded, err := ReadFrame(bytes.NewReader(golden))
+		if err != nil {
+			t.Errorf("%s: %s", gm.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, gm.msg) {
+			t.Errorf("%s: decoded %+v, want %+v", gm.name, decoded, gm.msg)
+		}
+
+		// every truncated frame must be refused, not misread
+		for cut := 5; cut < len(golden); cut++ {
+			broken := append([]byte(nil), golden[:cut]...)
+			broken[3] = byte(cut - 4)
+			if _, err := ReadFrame(bytes.NewReader(broken)); err == nil {
+				t.Errorf("%s: frame truncated to %d bytes is accepted", gm.name, cut)
+				break
+			}
+		}
+	}
+}
+
+func formatGolden(frame []byte) string {
+	var lines []string
+	for len(frame) > GOLDEN_LINE_BYTES {
+		lines = append(lines, hex.EncodeToString(frame[:GOLDEN_LINE_BYTES]))
+		frame = frame[GOLDEN_LINE_BYTES:]
+	}
+	lines = append(lines, hex.EncodeToString(frame))
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// crowdAgent owns units which do nothing
+type crowdAgent struct{}
+
+func (c *crowdAgent) AttachUnit(u engine.Unit)                                            {}
+func (c *crowdAgent) DetachUnit(u engine.Unit)                                            {}
+func (c *crowdAgent) HandleUnit(f *engine.FieldView, u engine.Unit, Coord geom.UnitCoord) {}
+
+// placeCheckSquad places squad of soldiers at coords
+func placeCheckSquad(f *engine.Field, Pid int, coords ...geom.UnitCoord) *engine.Squad {
+	squad := &engine.Squad{Orders: make(chan engine.Order, engine.SQUAD_ORDER_QUEUE_LEN),
+		Pid: Pid, FireState: engine.ORDER_NOFIRE}
+	f.PlaceAgent(squad)
+	for _, c := range coords {
+		f.PlaceUnit(c, squad, engine.NewSoldier(f))
+	}
+	return squad
+}
+
+func TestOversizedFrame(t *testing.T) {
+	var out bytes.Buffer
+	before := metricEncodeErrors.Value(fmt.Sprint(MSG_MESSAGE))
+	err := WriteFrame(&out, &Message{Content: strings.Repeat("z", PROTO_MAX_FRAME_SIZE)})
+	if err == nil || out.Len() != 0 {
+		t.Fatalf("oversized frame is written")
+	}
+	if metricEncodeErrors.Value(fmt.Sprint(MSG_MESSAGE)) != before+1 {
+		t.Error("encode error is not counted")
+	}
+}
+
+func TestSnapshotRoundtrip(t *testing.T) {
+	f := engine.NewField(32, 32, make(chan *engine.Field, 1))
+	placeCheckSquad(f, 0, geom.UnitCoord{X: 5.5, Y: 5.5}, geom.UnitCoord{X: 6.5, Y: 5.5})
+	placeCheckSquad(f, 1, geom.UnitCoord{X: 20.5, Y: 5.5}).Units[0].Morale = 42
+	crowd := &crowdAgent{}
+	dam := engine.NewDamsel(f)
+	dam.Adrenaline = 10
+	f.PlaceUnit(geom.UnitCoord{X: 10.5, Y: 10.5}, crowd, dam)
+	zed := engine.NewZed(f)
+	f.PlaceUnit(geom.UnitCoord{X: 12.5, Y: 10.5}, crowd, zed)
+	f.DealDamage(engine.DamageSource{Unit: zed.Id, Pid: -1, Cause: engine.DAMAGE_BITE}, dam.Id, 1000)
+
+	msg, err := ReadFrame(bytes.NewReader(EncodeFrame((*wireSnapshot)(engine.NewSnapshot(f, 0)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf := (*engine.Snapshot)(msg.(*wireSnapshot)).Field()
+
+	for idx, up := range f.Units {
+		if engine.UnitKind(rf.Units[idx].Unit) != engine.UnitKind(up.Unit) {
+			t.Fatalf("unit %d is %T, want %T", idx, rf.Units[idx].Unit, up.Unit)
+		}
+		if rf.Units[idx].Coord != up.Coord {
+			t.Fatalf("unit %d at %s, want %s", idx,
+				rf.Units[idx].Coord, up.Coord)
+		}
+	}
+
+	corpse := rf.Units[dam.Id].Unit.(*engine.Corpse)
+	if _, ok := corpse.Unit.(*engine.Damsel); !ok {
+		t.Fatalf("corpse of %T, want damsel", corpse.Unit)
+	}
+
+	for _, Id := range []int{0, 1} {
+		squad, ok := rf.AgentForUnitID(Id).(*engine.Squad)
+		if !ok || squad.Pid != 0 {
+			t.Fatalf("soldier %d is not in squad of player 0", Id)
+		}
+	}
+	enemy := rf.AgentForUnitID(2).(*engine.Squad)
+	if enemy.Pid != 1 || enemy.Units[0].Morale != engine.SOL_MORALE_MAX {
+		t.Error("enemy squad morale is leaked or squad is wrong")
+	}
+}
@@ -0,0 +1,320 @@
+package netproto
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/logging"
+)
+
+const (
+	RGAME_BACKOFF_MIN = 500 * time.Millisecond
+	RGAME_BACKOFF_MAX = 8 * time.Second
+	// server wipes our squad after that anyway
+	RGAME_RECONNECT_TIME = SESSION_GRACE
+	// server pings us regularly, silence means that connection is dead
+	RGAME_READ_TIMEOUT  = REMOTE_READ_TIMEOUT
+	RGAME_WRITE_TIMEOUT = REMOTE_WRITE_TIMEOUT
+)
+
+var rgameLog = logging.NewLogger("Rgame")
+
+type RemoteGame struct {
+	conn *net.TCPConn
+	addr *net.TCPAddr
+	name string
+	room string
+	rule string
+
+	render   Render
+	Orders   chan engine.Order
+	attachan chan Render
+	cells    []engine.Cell
+	acks     chan int64
+	pings    chan *Ping
+	chat     chan ChatLine
+	delta    *deltaDecoder
+
+	// server and lobby info from handshake
+	Info *Welcome
+}
+
+// ConnectRemoteGame joins room on the server, room is created with given rule if it does
+// not exist. Empty room means server's default one
+func ConnectRemoteGame(straddr, name, room, rule string) (*RemoteGame, error) {
+	addr, err := net.ResolveTCPAddr("tcp4", straddr)
+	if err != nil {
+		return nil, err
+	}
+
+	rg := &RemoteGame{addr: addr, name: name, room: room, rule: rule, Orders: make(chan engine.Order),
+		attachan: make(chan Render), acks: make(chan int64, 3), pings: make(chan *Ping, 1),
+		chat: make(chan ChatLine, CHAT_QUEUE)}
+	err = rg.connect()
+	if err != nil {
+		return nil, err
+	}
+	return rg, nil
+}
+
+// connect dials server and makes handshake, resuming session if there is one
+func (rg *RemoteGame) connect() error {
+	conn, err := net.DialTCP("tcp4", nil, rg.addr)
+	if err != nil {
+		return err
+	}
+
+	hello := &Hello{Name: rg.name, Room: rg.room, Rule: rg.rule}
+	if rg.Info != nil {
+		hello.Token = rg.Info.Token
+		hello.Room = rg.Info.Room
+	}
+	conn.SetDeadline(time.Now().Add(RGAME_READ_TIMEOUT))
+	info, err := SendHandshake(conn, hello)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	rg.conn = conn
+	rg.Info = info
+	rg.delta = newDeltaDecoder()
+	rg.cells = nil
+	return nil
+}
+
+// AttachPlayer binds local render to the remote game, name is already sent on connect
+func (rg *RemoteGame) AttachPlayer(r Render, name string) int {
+	rg.attachan <- r
+	return 0
+}
+
+func (rg *RemoteGame) Run() {
+	// wait for attaching
+	rgameLog.Debugf("waiting for render...")
+	rg.render = <-rg.attachan
+	rgameLog.Debugf("render attached, starting chat with server")
+	rg.render.HandleMessage(MESSAGE_LEVEL_INFO, rg.Info.String())
+	// server knows who we are
+	rg.render.AttachChat(-1, rg.chat)
+
+	for {
+		if reject := rg.serve(); reject != nil {
+			// kick is final, there is no session to come back to
+			rgameLog.Infof("kicked by server: %s", reject.Reason)
+			rg.render.HandleMessage(MESSAGE_LEVEL_INFO, reject.Reason)
+			return
+		}
+		if !rg.reconnect() {
+			break
+		}
+		rgameLog.Infof("reconnected")
+		rg.render.HandleMessage(MESSAGE_LEVEL_INFO, "reconnected to server")
+	}
+	rgameLog.Errorf("cannot reconnect, giving up")
+	rg.render.HandleMessage(MESSAGE_LEVEL_INFO, "connection to server have been terminated")
+}
+
+// serve interacts with remote until connection breaks, returns rejection if server have
+// kicked the player
+func (rg *RemoteGame) serve() *Reject {
+	readErrs, writeErrs := make(chan error, 1), make(chan error, 1)
+	done := make(chan struct{})
+	go rg.runReader(rg.conn, readErrs)
+	go rg.runWriter(rg.conn, writeErrs, done)
+
+	defer rg.conn.Close()
+	defer close(done)
+	select {
+	case err := <-readErrs:
+		if reject, ok := err.(*Reject); ok {
+			return reject
+		}
+		rgameLog.Warnf("failed to recieve message from server: %s", err)
+	case err := <-writeErrs:
+		rgameLog.Warnf("failed to send message to server: %s", err)
+		// reader must be gone before next connection starts
+		rg.conn.Close()
+		<-readErrs
+	}
+	return nil
+}
+
+// reconnect retries to connect with growing delay, false if server is gone for good
+func (rg *RemoteGame) reconnect() bool {
+	deadline := time.Now().Add(RGAME_RECONNECT_TIME)
+	backoff := RGAME_BACKOFF_MIN
+	for time.Now().Before(deadline) {
+		rg.render.HandleMessage(MESSAGE_LEVEL_INFO,
+			fmt.Sprintf("connection lost, reconnecting in %s", backoff))
+		time.Sleep(backoff)
+
+		err := rg.connect()
+		if err == nil {
+			return true
+		}
+		rgameLog.Warnf("reconnect failed: %s", err)
+		if _, ok := err.(*Reject); ok {
+			rg.render.HandleMessage(MESSAGE_LEVEL_INFO, err.Error())
+			return false
+		}
+
+		backoff *= 2
+		if backoff > RGAME_BACKOFF_MAX {
+			backoff = RGAME_BACKOFF_MAX
+		}
+	}
+	return false
+}
+
+func (rg *RemoteGame) runReader(conn net.Conn, errs chan error) {
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(RGAME_READ_TIMEOUT))
+		msg, err := ReadFrame(reader)
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *wireSnapshot:
+			// is a full update with terrain
+			snap := (*engine.Snapshot)(msg)
+			rg.delta = newDeltaDecoder()
+			rg.fixSnapshot(snap)
+			rg.render.HandleUpdate(snap)
+		case *UnitDelta:
+			snap, ok := rg.delta.Decode(msg)
+			if !ok {
+				// base is lost, wait for keyframe
+				continue
+			}
+			select {
+			case rg.acks <- msg.Seq:
+			default:
+			}
+			rg.fixSnapshot(snap)
+			rg.render.HandleUpdate(snap)
+		case *Assignment:
+			rg.render.AssignSquad(msg.Id, rg.Orders)
+		case *wireGameState:
+			rg.render.HandleGameState(engine.GameState(*msg))
+		case *Message:
+			rg.render.HandleMessage(msg.Level, msg.Content)
+		case *Scoreboard:
+			rg.render.HandleScoreboard(msg)
+		case *Leaderboard:
+			rg.render.HandleLeaderboard(msg)
+		case *ResetMsg:
+			rg.render.Reset()
+		case *Ping:
+			select {
+			case rg.pings <- msg:
+			default:
+			}
+		case *Reject:
+			errs <- msg
+			return
+		default:
+			errs <- fmt.Errorf("unexpected message type %d from server", msg.MsgType())
+			return
+		}
+	}
+}
+
+func (rg *RemoteGame) runWriter(conn net.Conn, errs chan error, done chan struct{}) {
+	for {
+		var msg WireMessage
+		select {
+		case Order := <-rg.Orders:
+			msg = (*wireOrder)(&Order)
+		case seq := <-rg.acks:
+			msg = &Ack{seq}
+		case line := <-rg.chat:
+			msg = &line
+		case ping := <-rg.pings:
+			pong := Pong(*ping)
+			msg = &pong
+		case <-done:
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(RGAME_WRITE_TIMEOUT))
+		err := WriteFrame(conn, msg)
+		if err != nil {
+			errs <- err
+			return
+		}
+	}
+}
+
+// fixSnapshot restores terrain which is sent only once per round
+func (rg *RemoteGame) fixSnapshot(snap *engine.Snapshot) {
+	if snap.Cells != nil {
+		rg.cells = snap.Cells
+	} else {
+		snap.Cells = rg.cells
+	}
+}
+
+// ListRooms asks server for its rooms without joining any
+func ListRooms(straddr string) ([]RoomInfo, error) {
+	conn, err := net.DialTimeout("tcp4", straddr, RGAME_READ_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(RGAME_READ_TIMEOUT))
+	msg, err := SendHello(conn, &Hello{List: true})
+	if err != nil {
+		return nil, err
+	}
+	list, ok := msg.(*RoomList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %d, want room list", msg.MsgType())
+	}
+	return list.Rooms, nil
+}
+
+// SendHandshake introduces player to the server and returns server info. Token from previous
+// welcome resumes the session. Rejection is returned as *Reject error
+func SendHandshake(conn io.ReadWriter, hello *Hello) (*Welcome, error) {
+	hello.Name = SanitizeName(hello.Name)
+	msg, err := SendHello(conn, hello)
+	if err != nil {
+		return nil, err
+	}
+	welcome, ok := msg.(*Welcome)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %d in handshake", msg.MsgType())
+	}
+	rgameLog.Infof("%s", welcome)
+	return welcome, nil
+}
+
+// SendHello writes header and hello and reads server's answer
+func SendHello(conn io.ReadWriter, hello *Hello) (WireMessage, error) {
+	header := ProtoHeader
+	if _, err := conn.Write(header[:]); err != nil {
+		return nil, err
+	}
+	hello.Version = PROTO_VERSION
+	if err := WriteFrame(conn, hello); err != nil {
+		return nil, err
+	}
+
+	msg, err := ReadFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if reject, ok := msg.(*Reject); ok {
+		return nil, reject
+	}
+	return msg, nil
+}
@@ -0,0 +1,71 @@
+package netproto
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// messageRender remembers messages shown to player
+type messageRender struct {
+	NopRender
+	lock     sync.Mutex
+	messages []string
+}
+
+func (r *messageRender) HandleMessage(lvl int, msg string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.messages = append(r.messages, msg)
+}
+
+func TestKickIsFinal(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var header [4]byte
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return
+		}
+		if _, err := ReadFrame(conn); err != nil {
+			return
+		}
+		WriteFrame(conn, &Welcome{Version: PROTO_VERSION, Server: "check", Token: "token"})
+		WriteFrame(conn, &Reject{REJECT_KICKED, "you have been kicked by admin"})
+	}()
+
+	rg, err := ConnectRemoteGame(listener.Addr().String(), "bob", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := &messageRender{}
+	go rg.AttachPlayer(render, "bob")
+	done := make(chan struct{})
+	go func() {
+		rg.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(RGAME_BACKOFF_MIN / 2):
+		t.Fatalf("kicked client tries to reconnect")
+	}
+	render.lock.Lock()
+	defer render.lock.Unlock()
+	last := render.messages[len(render.messages)-1]
+	if !strings.Contains(last, "kicked by admin") {
+		t.Errorf("kicked player is told %q", last)
+	}
+}
@@ -0,0 +1,351 @@
+package netproto
+
+import (
+	"bufio"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/logging"
+)
+
+var rrenderLog = logging.NewLogger("Rrender")
+
+const (
+	REMOTE_ENCODE_BUFFER_SIZE = 8 * 1024 * 1024
+	REMOTE_WRITE_TIMEOUT      = 5 * time.Second
+	// client pongs every ping, so silence for that long means dead peer
+	REMOTE_READ_TIMEOUT  = 15 * time.Second
+	REMOTE_PING_INTERVAL = 2 * time.Second
+)
+
+type RemoteRender struct {
+	updates      chan *engine.Snapshot
+	messages     chan Message
+	squad        int
+	stateUpdates chan engine.GameState
+	assignments  chan Assignment
+	scoreboards  chan *Scoreboard
+	leaderboards chan *Leaderboard
+
+	localUpdates        chan WireMessage
+	acks                chan int64
+	pongs               chan *Pong
+	delta               *deltaEncoder
+	conn                net.Conn
+	readErrs, writeErrs chan error
+	mapSent             bool
+	reset               chan struct{}
+	kicks               chan *Reject
+	done                chan struct{}
+	chat                ChatBinding
+
+	WriteTimeout, ReadTimeout, PingInterval time.Duration
+
+	// updated atomically, read by anyone
+	latency int64
+	dropped int64
+	sent    int64
+	// orders of assigned squad, set by writer and read by reader
+	orders atomic.Value
+	// size of the field client got last, orders off it are dropped
+	xsize, ysize int32
+}
+
+func CreateRemoteRender(conn net.Conn) *RemoteRender {
+	return &RemoteRender{updates: make(chan *engine.Snapshot, 3), stateUpdates: make(chan engine.GameState, 3),
+		squad: -1, assignments: make(chan Assignment, 1),
+		localUpdates: make(chan WireMessage, 3), acks: make(chan int64, 3), delta: newDeltaEncoder(),
+		pongs: make(chan *Pong, 1), conn: conn,
+		readErrs: make(chan error, 1), writeErrs: make(chan error, 1),
+		reset: make(chan struct{}, 1), kicks: make(chan *Reject, 1), messages: make(chan Message, CHAT_QUEUE),
+		scoreboards: make(chan *Scoreboard, 1), leaderboards: make(chan *Leaderboard, 1),
+		done: make(chan struct{}), WriteTimeout: REMOTE_WRITE_TIMEOUT,
+		ReadTimeout: REMOTE_READ_TIMEOUT, PingInterval: REMOTE_PING_INTERVAL}
+}
+
+// HandleUpdate never blocks: if client can not keep up, oldest pending snapshot is dropped.
+// Deltas are built against acknowledged snapshot, so client is fine with missing ones
+func (rr *RemoteRender) HandleUpdate(s *engine.Snapshot) {
+	for {
+		select {
+		case rr.updates <- s:
+			return
+		default:
+		}
+		select {
+		case <-rr.updates:
+			atomic.AddInt64(&rr.dropped, 1)
+			MetricDropped.Inc("remote", "snapshot")
+		default:
+		}
+	}
+}
+
+func (rr *RemoteRender) HandleGameState(s engine.GameState) {
+	select {
+	case rr.stateUpdates <- s:
+	default:
+		MetricDropped.Inc("remote", "game-state")
+	}
+}
+
+func (rr *RemoteRender) HandleMessage(lvl int, msg string) {
+	select {
+	case rr.messages <- Message{lvl, msg, MESSAGE_TTL}:
+	default:
+		MetricDropped.Inc("remote", "message")
+	}
+}
+
+// AssignSquad never blocks, pending assignment is replaced with the latest one
+func (rr *RemoteRender) AssignSquad(Id int, Orders chan engine.Order) {
+	for {
+		select {
+		case rr.assignments <- Assignment{Id, Orders}:
+			return
+		default:
+		}
+		select {
+		case <-rr.assignments:
+		default:
+		}
+	}
+}
+
+func (rr *RemoteRender) HandleScoreboard(sb *Scoreboard) {
+	select {
+	case rr.scoreboards <- sb:
+	default:
+		MetricDropped.Inc("remote", "scoreboard")
+	}
+}
+
+func (rr *RemoteRender) HandleLeaderboard(lb *Leaderboard) {
+	select {
+	case rr.leaderboards <- lb:
+	default:
+		MetricDropped.Inc("remote", "leaderboard")
+	}
+}
+
+func (rr *RemoteRender) Spectate() {
+	rr.AssignSquad(-1, nil)
+}
+
+// Reset never blocks, Run sends reset to client before next snapshot
+func (rr *RemoteRender) Reset() {
+	select {
+	case rr.reset <- struct{}{}:
+	default:
+		// reset is already pending
+	}
+}
+
+// AttachChat must be called before Run, reader uses the binding without locking
+func (rr *RemoteRender) AttachChat(Pid int, chat chan ChatLine) {
+	rr.chat = ChatBinding{Pid, chat}
+}
+
+// Kick sends rejection with the reason and drops the connection after writer flushes it.
+// Connection of stalled client is dropped at once
+func (rr *RemoteRender) Kick(reason string) {
+	select {
+	case rr.kicks <- &Reject{REJECT_KICKED, reason}:
+	default:
+		rr.conn.Close()
+	}
+}
+
+// Close drops the connection, Run returns with error soon after
+func (rr *RemoteRender) Close() error {
+	return rr.conn.Close()
+}
+
+func (rr *RemoteRender) RemoteAddr() net.Addr {
+	return rr.conn.RemoteAddr()
+}
+
+// Latency returns last measured round trip time
+func (rr *RemoteRender) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&rr.latency))
+}
+
+// Dropped returns number of snapshots which client was too slow to get
+func (rr *RemoteRender) Dropped() int64 {
+	return atomic.LoadInt64(&rr.dropped)
+}
+
+// Sent returns number of bytes written to client
+func (rr *RemoteRender) Sent() int64 {
+	return atomic.LoadInt64(&rr.sent)
+}
+
+func (rr *RemoteRender) Run() error {
+	rrenderLog.With("addr", rr.conn.RemoteAddr()).Debugf("starting up")
+	defer close(rr.done)
+	go rr.runReader()
+	go rr.runWriter()
+
+	pingTicker := time.NewTicker(rr.PingInterval)
+	defer pingTicker.Stop()
+	var pingSeq int64
+	for {
+		select {
+		// local channels
+		//case assignment := <-rr.assignments: // handled directly by writer
+		case <-rr.reset:
+			if err := rr.queueReset(); err != nil {
+				return err
+			}
+		case snap := <-rr.updates:
+			atomic.StoreInt32(&rr.xsize, int32(snap.XSize))
+			atomic.StoreInt32(&rr.ysize, int32(snap.YSize))
+			// reset of new round goes before its first snapshot
+			select {
+			case <-rr.reset:
+				if err := rr.queueReset(); err != nil {
+					return err
+				}
+			default:
+			}
+			if len(rr.localUpdates) == cap(rr.localUpdates) {
+				// writer is stuck on slow client
+				atomic.AddInt64(&rr.dropped, 1)
+				MetricDropped.Inc("remote", "snapshot")
+				continue
+			}
+			if rr.mapSent {
+				// terrain is already there, send only changed units
+				rr.localUpdates <- rr.delta.Encode(snap)
+			} else {
+				rr.mapSent = true
+				rr.delta.Reset()
+				rr.localUpdates <- (*wireSnapshot)(snap)
+			}
+		case seq := <-rr.acks:
+			rr.delta.Ack(seq)
+		case gameState := <-rr.stateUpdates:
+			select {
+			case rr.localUpdates <- (*wireGameState)(&gameState):
+			case err := <-rr.writeErrs:
+				return err
+			}
+		case <-pingTicker.C:
+			pingSeq++
+			select {
+			case rr.localUpdates <- &Ping{pingSeq, time.Now().UnixNano()}:
+			default:
+			}
+		case pong := <-rr.pongs:
+			atomic.StoreInt64(&rr.latency, time.Now().UnixNano()-pong.Time)
+
+		// orders sent directly from reader
+		// error channels
+		case err := <-rr.readErrs:
+			return err
+		case err := <-rr.writeErrs:
+			return err
+		}
+	}
+	return nil
+}
+
+// queueReset makes writer send reset, next snapshot goes with terrain
+func (rr *RemoteRender) queueReset() error {
+	rr.mapSent = false
+	select {
+	case rr.localUpdates <- &ResetMsg{}:
+		return nil
+	case err := <-rr.writeErrs:
+		return err
+	}
+}
+
+// squadOrders returns orders channel of assigned squad, nil for spectator
+func (rr *RemoteRender) squadOrders() chan engine.Order {
+	orders, _ := rr.orders.Load().(chan engine.Order)
+	return orders
+}
+
+func (rr *RemoteRender) runReader() {
+	// read remote data
+	reader := bufio.NewReader(rr.conn)
+	for {
+		rr.conn.SetReadDeadline(time.Now().Add(rr.ReadTimeout))
+		msg, err := ReadFrame(reader)
+		if err != nil {
+			rr.readErrs <- err
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *wireOrder:
+			order := engine.Order(*msg)
+			if !order.Valid(int(atomic.LoadInt32(&rr.xsize)), int(atomic.LoadInt32(&rr.ysize))) {
+				metricInvalidOrders.Inc()
+				rrenderLog.With("addr", rr.conn.RemoteAddr()).Debugf("dropping invalid order %v",
+					order)
+				continue
+			}
+			select {
+			case rr.squadOrders() <- order:
+			default:
+			}
+		case *Ack:
+			select {
+			case rr.acks <- msg.Seq:
+			default:
+			}
+		case *Pong:
+			select {
+			case rr.pongs <- msg:
+			default:
+			}
+		case *ChatLine:
+			// client can not speak for others
+			msg.From = rr.chat.Pid
+			select {
+			case rr.chat.Chat <- *msg:
+			default:
+			}
+		}
+	}
+}
+
+func (rr *RemoteRender) runWriter() {
+	for {
+		var msg WireMessage
+		var kicked bool
+		select {
+		case Assignment := <-rr.assignments:
+			msg = &Assignment
+			rr.orders.Store(Assignment.Orders)
+		case ub := <-rr.localUpdates:
+			msg = ub
+		case m := <-rr.messages:
+			msg = &m
+		case sb := <-rr.scoreboards:
+			msg = sb
+		case lb := <-rr.leaderboards:
+			msg = lb
+		case msg = <-rr.kicks:
+			kicked = true
+		case <-rr.done:
+			return
+		}
+
+		// stalled client must not hold writer forever
+		rr.conn.SetWriteDeadline(time.Now().Add(rr.WriteTimeout))
+		n, err := writeFrame(rr.conn, msg)
+		atomic.AddInt64(&rr.sent, int64(n))
+		if kicked {
+			rr.conn.Close()
+		}
+		if err != nil {
+			rr.writeErrs <- err
+			return
+		}
+	}
+}
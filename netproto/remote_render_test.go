@@ -0,0 +1,118 @@
+package netproto
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+// startRender runs remote render over pipe, client side frames are sent to returned channel
+func startRender() (*RemoteRender, net.Conn, chan WireMessage) {
+	server, client := net.Pipe()
+	rr := CreateRemoteRender(server)
+	rr.PingInterval = time.Hour
+	go rr.Run()
+
+	frames := make(chan WireMessage, 16)
+	go func() {
+		defer close(frames)
+		for {
+			msg, err := ReadFrame(client)
+			if err != nil {
+				return
+			}
+			frames <- msg
+		}
+	}()
+	return rr, client, frames
+}
+
+// waitFrame skips frames until one of given type arrives
+func waitFrame(t *testing.T, frames chan WireMessage, msgType uint8) {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case msg, ok := <-frames:
+			if !ok {
+				t.Fatalf("connection closed while waiting for message type %d", msgType)
+			}
+			if msg.MsgType() == msgType {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no message type %d from render", msgType)
+		}
+	}
+}
+
+func TestInvalidOrders(t *testing.T) {
+	rr, client, frames := startRender()
+	defer client.Close()
+
+	orders := make(chan engine.Order, 8)
+	rr.HandleUpdate(engine.NewSnapshot(engine.NewField(16, 16, nil), 0))
+	waitFrame(t, frames, MSG_SNAPSHOT)
+	rr.AssignSquad(0, orders)
+	waitFrame(t, frames, MSG_ASSIGNMENT)
+
+	for _, o := range []engine.Order{
+		{Order: engine.ORDER_MOVE, Coord: geom.CellCoord{X: 16, Y: 5}},
+		{Order: engine.ORDER_GREN, Coord: geom.CellCoord{X: 5, Y: -1}},
+		{Order: 42, Coord: geom.CellCoord{X: 5, Y: 5}},
+		{Order: engine.ORDER_MOVE, Coord: geom.CellCoord{X: 5, Y: 5}},
+	} {
+		if err := WriteFrame(client, (*wireOrder)(&o)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case o := <-orders:
+		if o.Coord != (geom.CellCoord{X: 5, Y: 5}) || o.Order != engine.ORDER_MOVE {
+			t.Fatalf("invalid order %+v is passed to squad", o)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("valid order is not passed to squad")
+	}
+}
+
+func TestAssignmentRace(t *testing.T) {
+	rr, client, frames := startRender()
+	defer client.Close()
+	rr.HandleUpdate(engine.NewSnapshot(engine.NewField(16, 16, nil), 0))
+	waitFrame(t, frames, MSG_SNAPSHOT)
+
+	// client keeps ordering while squad is reassigned on reconnects and round resets
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		order := &wireOrder{Order: engine.ORDER_MOVE, Coord: geom.CellCoord{X: 5, Y: 5}}
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if WriteFrame(client, order) != nil {
+				return
+			}
+		}
+	}()
+
+	orders := make(chan engine.Order, 1)
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			rr.AssignSquad(0, orders)
+		} else {
+			rr.Spectate()
+		}
+		waitFrame(t, frames, MSG_ASSIGNMENT)
+		select {
+		case <-orders:
+		default:
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package netproto
+
+import (
+	"fmt"
+
+	"github.com/mechmind/life-goes-on/engine"
+)
+
+type PlayerStats struct {
+	Pid          int
+	Name         string
+	ZedsKilled   int
+	DamselsSaved int
+	DamselsShot  int
+	SoldiersLost int
+	GrensThrown  int
+	Shots, Hits  int
+}
+
+// Accuracy returns percent of shots that hit something
+func (p PlayerStats) Accuracy() int {
+	if p.Shots == 0 {
+		return 0
+	}
+	return p.Hits * 100 / p.Shots
+}
+
+type Scoreboard struct {
+	Rule    string
+	Players []PlayerStats
+}
+
+func NewScoreboard(rule string, summary engine.RoundSummary) *Scoreboard {
+	sb := &Scoreboard{Rule: rule}
+	ledger := summary.Ledger
+	for _, Pid := range summary.Players {
+		stats := PlayerStats{Pid: Pid}
+		stats.ZedsKilled = ledger.KillsBy(Pid, engine.KIND_ZED)
+		stats.DamselsShot = ledger.KillsBy(Pid, engine.KIND_DAMSEL)
+		stats.SoldiersLost = ledger.LossesOf(Pid, engine.KIND_SOLDIER)
+		counters := ledger.Counters(Pid)
+		stats.Shots, stats.Hits, stats.GrensThrown = counters.Shots, counters.Hits, counters.Grens
+
+		// surviving squads share the credit for saved damsels
+		for _, survivor := range summary.Survivors {
+			if survivor == Pid {
+				stats.DamselsSaved = summary.Damsels
+				break
+			}
+		}
+		sb.Players = append(sb.Players, stats)
+	}
+	return sb
+}
+
+// Lines formats scoreboard as table, first line is a header
+func (sb *Scoreboard) Lines() []string {
+	format := "%-16s %7s %7s %7s %7s %7s %7s"
+	lines := []string{fmt.Sprintf(format, "player", "Zs", "Bs", "Bs shot", "lost", "grens",
+		"acc")}
+	for _, p := range sb.Players {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("player %d", p.Pid)
+		}
+		lines = append(lines, fmt.Sprintf(format, name,
+			fmt.Sprint(p.ZedsKilled), fmt.Sprint(p.DamselsSaved), fmt.Sprint(p.DamselsShot),
+			fmt.Sprint(p.SoldiersLost), fmt.Sprint(p.GrensThrown),
+			fmt.Sprintf("%d%%", p.Accuracy())))
+	}
+	return lines
+}
@@ -1,26 +1,30 @@
-package main
+// Package pathfind finds paths between cells of a grid with a*.
+package pathfind
 
 import (
 	"math"
-)
 
-var (
-	neighbours = [8]CellCoord{{0, 1}, {1, 1}, {1, 0}, {1, -1}, {0, -1}, {-1, -1}, {-1, 0}, {-1, 1}}
+	"github.com/mechmind/life-goes-on/geom"
 )
 
+// Grid is what pathfinder needs to know about terrain
+type Grid interface {
+	Passable(c geom.CellCoord) bool
+}
+
 type PathFinder struct {
-	source, Target CellCoord
-	field          *Field
-	Cells          map[CellCoord]PathCell
+	source, Target geom.CellCoord
+	grid           Grid
+	Cells          map[geom.CellCoord]PathCell
 	open           *WeightedList
-	path           Path
+	Path           Path
 }
 
-func NewPathFinder(f *Field) *PathFinder {
-	return &PathFinder{field: f, Cells: make(map[CellCoord]PathCell), open: &WeightedList{}}
+func NewPathFinder(g Grid) *PathFinder {
+	return &PathFinder{grid: g, Cells: make(map[geom.CellCoord]PathCell), open: &WeightedList{}}
 }
 
-func (p *PathFinder) FindPath(From, To CellCoord) Path {
+func (p *PathFinder) FindPath(From, To geom.CellCoord) Path {
 	p.source = From
 	p.Target = To
 
@@ -33,11 +37,11 @@ func (p *PathFinder) FindPath(From, To CellCoord) Path {
 	return p.findPath()
 }
 
-func (p *PathFinder) CellAt(Coord CellCoord) PathCell {
+func (p *PathFinder) CellAt(Coord geom.CellCoord) PathCell {
 	cell, ok := p.Cells[Coord]
 	if !ok {
-		cell = PathCell{Coord, CellCoord{}, math.MaxFloat32, false, false, false}
-		if p.field.CellAt(Coord).Passable {
+		cell = PathCell{Coord, geom.CellCoord{}, math.MaxFloat32, false, false, false}
+		if p.grid.Passable(Coord) {
 			cell.visible = true
 		}
 		p.Cells[Coord] = cell
@@ -46,16 +50,16 @@ func (p *PathFinder) CellAt(Coord CellCoord) PathCell {
 	return cell
 }
 
-func (p *PathFinder) closeCell(Coord CellCoord) {
+func (p *PathFinder) closeCell(Coord geom.CellCoord) {
 	pc := p.Cells[Coord]
-	pc.closed = true
+	pc.Closed = true
 	p.Cells[Coord] = pc
 	p.open.Remove(Coord)
 }
 
-func (p *PathFinder) openCell(Coord CellCoord, weight float32) {
+func (p *PathFinder) openCell(Coord geom.CellCoord, weight float32) {
 	pc := p.Cells[Coord]
-	pc.open = true
+	pc.Open = true
 	p.Cells[Coord] = pc
 	p.open.Insert(Coord, weight)
 }
@@ -63,7 +67,7 @@ func (p *PathFinder) openCell(Coord CellCoord, weight float32) {
 func (p *PathFinder) updateCell(cell PathCell) {
 	oldCell := p.Cells[cell.Coord]
 	weight := cell.cost + cell.Coord.Distance(p.Target)
-	if oldCell.open {
+	if oldCell.Open {
 		p.open.Replace(cell.Coord, weight)
 	} else {
 		p.open.Insert(cell.Coord, weight)
@@ -73,9 +77,9 @@ func (p *PathFinder) updateCell(cell PathCell) {
 	p.Cells[cell.Coord] = cell
 }
 
-func (p *PathFinder) Neighbours(center CellCoord) []PathCell {
+func (p *PathFinder) Neighbours(center geom.CellCoord) []PathCell {
 	Cells := make([]PathCell, 8)
-	for idx, delta := range neighbours {
+	for idx, delta := range geom.Neighbours {
 		Cells[idx] = p.CellAt(center.AddCoord(delta))
 	}
 	return Cells
@@ -94,7 +98,7 @@ func (p *PathFinder) findPath() Path {
 			// ok, path found
 
 			path := p.backtrackPath()
-			p.path = path
+			p.Path = path
 			return path
 		}
 
@@ -109,7 +113,7 @@ func (p *PathFinder) findPath() Path {
 		var newCost float32
 		for idx := range neighbours {
 			if neighbours[idx].visible {
-				if neighbours[idx].closed {
+				if neighbours[idx].Closed {
 					// cell already expanded
 					continue
 				}
@@ -124,7 +128,7 @@ func (p *PathFinder) findPath() Path {
 					// update path for pc
 					neighbours[idx].parent = Coord
 					neighbours[idx].cost = newCost
-					neighbours[idx].open = true
+					neighbours[idx].Open = true
 					p.updateCell(neighbours[idx])
 				}
 			}
@@ -166,9 +170,9 @@ func setVisibility(neighbours []PathCell) {
 	}
 }
 
-type Path []CellCoord
+type Path []geom.CellCoord
 
-func (p *Path) Next() (Coord CellCoord, ok bool) {
+func (p *Path) Next() (Coord geom.CellCoord, ok bool) {
 	path := *p
 	if len(path) > 1 {
 		path = path[:len(path)-1]
@@ -180,30 +184,30 @@ func (p *Path) Next() (Coord CellCoord, ok bool) {
 		*p = nil
 		return Coord, true
 	} else {
-		return CellCoord{}, false
+		return geom.CellCoord{}, false
 	}
 }
 
-func (p *Path) Current() (Coord CellCoord, ok bool) {
+func (p *Path) Current() (Coord geom.CellCoord, ok bool) {
 	if len(*p) > 0 {
 		return (*p)[len(*p)-1], true
 	} else {
-		return CellCoord{}, false
+		return geom.CellCoord{}, false
 	}
 }
 
 type PathCell struct {
-	Coord, parent CellCoord
+	Coord, parent geom.CellCoord
 	cost          float32
 	visible       bool
-	open, closed  bool
+	Open, Closed  bool
 }
 
 type WeightedList struct {
 	head *WeightedCell
 }
 
-func (w *WeightedList) Insert(Coord CellCoord, weight float32) {
+func (w *WeightedList) Insert(Coord geom.CellCoord, weight float32) {
 	wc := &WeightedCell{Coord, weight, nil}
 	if w.head == nil {
 		w.head = wc
@@ -237,13 +241,13 @@ func (w *WeightedList) Insert(Coord CellCoord, weight float32) {
 	}
 }
 
-func (w *WeightedList) Replace(Coord CellCoord, weight float32) {
+func (w *WeightedList) Replace(Coord geom.CellCoord, weight float32) {
 	// TODO: optimize
 	w.Remove(Coord)
 	w.Insert(Coord, weight)
 }
 
-func (w *WeightedList) Remove(Coord CellCoord) {
+func (w *WeightedList) Remove(Coord geom.CellCoord) {
 	curr := w.head
 
 	if curr == nil {
@@ -273,9 +277,9 @@ func (w *WeightedList) Remove(Coord CellCoord) {
 	}
 }
 
-func (w *WeightedList) Pop() (CellCoord, bool) {
+func (w *WeightedList) Pop() (geom.CellCoord, bool) {
 	if w.head == nil {
-		return CellCoord{}, false
+		return geom.CellCoord{}, false
 	}
 
 	var cell *WeightedCell
@@ -284,7 +288,7 @@ func (w *WeightedList) Pop() (CellCoord, bool) {
 }
 
 type WeightedCell struct {
-	Coord  CellCoord
+	Coord  geom.CellCoord
 	weight float32
 	next   *WeightedCell
 }
@@ -0,0 +1,100 @@
+// Package rules describes game rules: player count, versus or coop, extra Bs and Zs.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	AllRules = map[string]Rules{
+		"single":       Rules{MinPlayers: 1, MaxPlayers: 1},
+		"wild-west":    Rules{MinPlayers: 2, MaxPlayers: 2, Versus: true, Fog: true},
+		"classic":      Rules{MinPlayers: 2, MaxPlayers: 4},
+		"king-of-hill": Rules{MinPlayers: 2, MaxPlayers: 4, MoreBs: 50, Versus: true, Fog: true},
+		"crowds":       Rules{MinPlayers: 2, MaxPlayers: 4, MoreBs: 100, MoreBsP: 40},
+		"skirmish":     Rules{MinPlayers: 2, MaxPlayers: 4, MoreBs: 100, MoreZs: 15},
+		"safe-classic": Rules{MinPlayers: 2, MaxPlayers: 4, NoFriendlyFire: true},
+	}
+)
+
+type Rules struct {
+	MinPlayers     int
+	MaxPlayers     int
+	Versus         bool
+	MoreBs         int
+	MoreBsP        int
+	MoreZs         int
+	NoFriendlyFire bool
+	Fog            bool
+
+	Name string
+}
+
+func (r Rules) String() string {
+	var info = []string{r.Name}
+	var players string
+	if r.MinPlayers == r.MaxPlayers {
+		if r.MinPlayers == 1 {
+			players = fmt.Sprintf("%d player", r.MinPlayers)
+		} else {
+			players = fmt.Sprintf("%d players", r.MinPlayers)
+		}
+	} else {
+		players = fmt.Sprintf("%d..%d players", r.MinPlayers, r.MaxPlayers)
+	}
+	info = append(info, players)
+
+	if r.Versus {
+		info = append(info, "versus")
+	} else {
+		info = append(info, "coop")
+	}
+
+	if r.MoreBs > 0 {
+		info = append(info, fmt.Sprintf("+%d base Bs", r.MoreBs))
+	}
+
+	if r.MoreBsP > 0 {
+		info = append(info, fmt.Sprintf("+%d perP Bs", r.MoreBsP))
+	}
+
+	if r.MoreZs > 0 {
+		info = append(info, fmt.Sprintf("+%d start Zs", r.MoreZs))
+	}
+
+	if r.NoFriendlyFire {
+		info = append(info, "no FF")
+	}
+
+	if r.Fog {
+		info = append(info, "fog")
+	}
+
+	return joinNonEmptyStrings(info, ", ")
+}
+
+type Ruleset []Rules
+
+func (r *Ruleset) AddRules(name string) error {
+	rule, ok := AllRules[name]
+	if !ok {
+		return errors.New("no such rule: " + name)
+	}
+
+	rule.Name = name
+	// TODO: check for incompatible rules
+	*r = append(*r, rule)
+	return nil
+}
+
+func joinNonEmptyStrings(strs []string, sep string) string {
+	var dst []string
+	for _, str := range strs {
+		if str != "" {
+			dst = append(dst, str)
+		}
+	}
+	return strings.Join(dst, sep)
+}
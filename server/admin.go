@@ -0,0 +1,399 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/logging"
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+const (
+	ADMIN_PROMPT = "> "
+)
+
+var adminLog = logging.NewLogger("admin")
+
+// Admin serves console of dedicated server. Every session has its own selected room,
+// commands act on it unless they say otherwise
+type Admin struct {
+	rooms   *RoomManager
+	server  *Server
+	started time.Time
+}
+
+type adminSession struct {
+	out  io.Writer
+	room string
+}
+
+type adminCommand struct {
+	args string
+	help string
+	run  func(a *Admin, s *adminSession, args []string) error
+}
+
+var adminCommands map[string]adminCommand
+
+func init() {
+	adminCommands = map[string]adminCommand{
+		"help":    {"", "show this help", (*Admin).help},
+		"rooms":   {"", "list rooms", (*Admin).listRooms},
+		"room":    {"NAME", "select room for following commands", (*Admin).selectRoom},
+		"players": {"", "list players with their addresses", (*Admin).listPlayers},
+		"kick":    {"ID", "drop player and refuse reconnect with the same session", (*Admin).kick},
+		"ban":     {"NAME|HOST", "ban player name or host address and kick matching players", (*Admin).ban},
+		"next":    {"", "end current round and start the next one", (*Admin).next},
+		"rules":   {"[RULE...]", "show rule rotation or replace it from next round", (*Admin).rules},
+		"say":     {"TEXT", "send message to players in all rooms", (*Admin).say},
+		"pause":   {"", "pause the game", (*Admin).pause},
+		"resume":  {"", "resume paused game", (*Admin).resume},
+		"step":    {"", "advance paused game by one tick", (*Admin).step},
+		"speed":   {"N", "set game speed, " + engine.FormatSpeeds(), (*Admin).speed},
+		"stats":   {"", "show server stats", (*Admin).stats},
+	}
+}
+
+func NewAdmin(rooms *RoomManager, server *Server) *Admin {
+	return &Admin{rooms: rooms, server: server, started: time.Now()}
+}
+
+// Serve runs admin session until input ends
+func (a *Admin) Serve(in io.Reader, out io.Writer) {
+	s := &adminSession{out: out, room: ROOM_DEFAULT}
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(out, ADMIN_PROMPT)
+	for scanner.Scan() {
+		if args := strings.Fields(scanner.Text()); len(args) > 0 {
+			if err := a.exec(s, args); err != nil {
+				fmt.Fprintln(out, "error:", err)
+			}
+		}
+		fmt.Fprint(out, ADMIN_PROMPT)
+	}
+}
+
+// ServeUnix accepts admin sessions on unix socket, only owner of the process may connect
+func (a *Admin) ServeUnix(path string) error {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+			return fmt.Errorf("admin socket %s is in use", path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return err
+		}
+		// left by previous run
+		os.Remove(path)
+	}
+	listener, err := listenPrivate(path)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				adminLog.Errorf("failed to accept connection: %s", err)
+				return
+			}
+			adminLog.Infof("new session on %s", path)
+			go func() {
+				defer conn.Close()
+				a.Serve(conn, conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+func (a *Admin) exec(s *adminSession, args []string) error {
+	cmd, ok := adminCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command '%s', try help", args[0])
+	}
+	adminLog.Infof("%s", strings.Join(args, " "))
+	return cmd.run(a, s, args[1:])
+}
+
+// do runs fn on dispatcher of selected room. fn must not write to the session, slow
+// client would stall the game
+func (a *Admin) do(s *adminSession, fn func(d *Dispatcher)) error {
+	room := a.rooms.Room(s.room)
+	if room == nil || !room.dispatcher.Do(func() { fn(room.dispatcher) }) {
+		return fmt.Errorf("room '%s' is closed", s.room)
+	}
+	return nil
+}
+
+func (a *Admin) help(s *adminSession, args []string) error {
+	var names []string
+	for name := range adminCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := adminCommands[name]
+		fmt.Fprintf(s.out, "%-8s %-10s %s\n", name, cmd.args, cmd.help)
+	}
+	return nil
+}
+
+func (a *Admin) listRooms(s *adminSession, args []string) error {
+	for _, room := range a.rooms.List() {
+		mark := " "
+		if room.Name == s.room {
+			mark = "*"
+		}
+		fmt.Fprintln(s.out, mark, room)
+	}
+	return nil
+}
+
+func (a *Admin) selectRoom(s *adminSession, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: room NAME")
+	}
+	if a.rooms.Room(args[0]) == nil {
+		return fmt.Errorf("no room named '%s'", args[0])
+	}
+	s.room = args[0]
+	return nil
+}
+
+func (a *Admin) listPlayers(s *adminSession, args []string) error {
+	var players []PlayerInfo
+	err := a.do(s, func(d *Dispatcher) {
+		players = d.playerInfos()
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range players {
+		fmt.Fprintf(s.out, "%4d %-16s %-22s %-10s latency %s, dropped %d, sent %d bytes\n", p.Id,
+			p.Name, p.Addr, p.State, p.Latency, p.Dropped, p.Sent)
+	}
+	fmt.Fprintf(s.out, "%d players in room %s\n", len(players), s.room)
+	return nil
+}
+
+func (a *Admin) kick(s *adminSession, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: kick ID")
+	}
+	Id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid player id '%s'", args[0])
+	}
+
+	var token string
+	var kickErr error
+	err = a.do(s, func(d *Dispatcher) {
+		token, kickErr = d.kickPlayer(Id, "you have been kicked by admin")
+	})
+	if err == nil {
+		err = kickErr
+	}
+	if err != nil {
+		return err
+	}
+	// client reconnects on its own, the session is not welcome anymore
+	a.server.Ban(token)
+	fmt.Fprintf(s.out, "player %d is kicked\n", Id)
+	return nil
+}
+
+func (a *Admin) ban(s *adminSession, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: ban NAME|HOST")
+	}
+	who := args[0]
+	a.server.Ban(who)
+
+	var kicked int
+	for _, room := range a.rooms.Rooms() {
+		d := room.dispatcher
+		d.Do(func() {
+			for _, p := range d.playerInfos() {
+				host, _, _ := net.SplitHostPort(p.Addr)
+				if p.Name != who && host != who {
+					continue
+				}
+				if _, err := d.kickPlayer(p.Id, "you are banned on this server"); err == nil {
+					kicked++
+				}
+			}
+		})
+	}
+	fmt.Fprintf(s.out, "%s is banned, %d players kicked\n", who, kicked)
+	return nil
+}
+
+func (a *Admin) next(s *adminSession, args []string) error {
+	return a.do(s, func(d *Dispatcher) {
+		d.nextRound = true
+	})
+}
+
+func (a *Admin) rules(s *adminSession, args []string) error {
+	if len(args) == 0 {
+		var names []string
+		var current string
+		err := a.do(s, func(d *Dispatcher) {
+			for _, rules := range *d.rules {
+				names = append(names, rules.Name)
+			}
+			current = (*d.rules)[d.currentRules].Name
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "rotation: %s, current rule %s\n", strings.Join(names, " "), current)
+		return nil
+	}
+
+	var ruleset = &rules.Ruleset{}
+	for _, name := range args {
+		if err := ruleset.AddRules(name); err != nil {
+			return err
+		}
+	}
+	err := a.do(s, func(d *Dispatcher) {
+		d.pendingRules = ruleset
+	})
+	if err == nil {
+		fmt.Fprintln(s.out, "new rotation starts with the next round")
+	}
+	return err
+}
+
+func (a *Admin) say(s *adminSession, args []string) error {
+	text := sanitizeChat(strings.Join(args, " "))
+	if text == "" {
+		return errors.New("usage: say TEXT")
+	}
+	for _, room := range a.rooms.Rooms() {
+		d := room.dispatcher
+		d.Do(func() {
+			d.sendAll(netproto.MESSAGE_LEVEL_INFO, "admin: "+text)
+		})
+	}
+	return nil
+}
+
+func (a *Admin) pause(s *adminSession, args []string) error {
+	return a.setPace(s, "pause", "game is paused by admin")
+}
+
+func (a *Admin) resume(s *adminSession, args []string) error {
+	return a.setPace(s, "resume", "game is resumed")
+}
+
+func (a *Admin) step(s *adminSession, args []string) error {
+	return a.setPace(s, "step", "")
+}
+
+func (a *Admin) speed(s *adminSession, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: speed N")
+	}
+	speed, err := engine.ParseSpeed(args[0])
+	if err != nil {
+		return err
+	}
+	return a.setPace(s, "speed "+engine.FormatSpeed(speed), "game speed is set to "+engine.FormatSpeed(speed)+
+		" by admin")
+}
+
+// setPace runs pace command in selected room and announces it unless announcement is empty
+func (a *Admin) setPace(s *adminSession, command, announcement string) error {
+	var paceErr error
+	err := a.do(s, func(d *Dispatcher) {
+		if paceErr = d.setPace(command); paceErr == nil && announcement != "" {
+			d.sendAll(netproto.MESSAGE_LEVEL_INFO, announcement)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return paceErr
+}
+
+func (a *Admin) stats(s *adminSession, args []string) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	rooms := a.rooms.List()
+	fmt.Fprintf(s.out, "uptime %s, %d/%d clients, %d rooms, %d goroutines, %.1f MB heap\n",
+		time.Since(a.started).Truncate(time.Second), a.server.Clients(), a.server.maxClients,
+		len(rooms), runtime.NumGoroutine(), float64(mem.HeapAlloc)/(1<<20))
+	for _, room := range rooms {
+		fmt.Fprintln(s.out, " ", room)
+	}
+	return nil
+}
+
+// PlayerInfo describes player for admin
+type PlayerInfo struct {
+	Id      int
+	Name    string
+	Addr    string
+	State   string
+	Latency time.Duration
+	Dropped int64
+	Sent    int64
+}
+
+func (d *Dispatcher) playerInfos() []PlayerInfo {
+	var infos []PlayerInfo
+	for _, p := range d.players {
+		info := PlayerInfo{Id: p.Id, Name: p.title(), Addr: "local", State: "spectating"}
+		if remote, ok := p.render.(*netproto.RemoteRender); ok {
+			info.Addr = remote.RemoteAddr().String()
+			info.Latency, info.Dropped, info.Sent = remote.Latency(), remote.Dropped(), remote.Sent()
+		}
+		switch {
+		case !p.lostAt.IsZero():
+			info.Addr, info.State = "-", "lost"
+		case p.Orders != nil && d.roundState != engine.GAME_WAIT:
+			info.State = "playing"
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// kickPlayer drops remote player at once without waiting for reconnect and returns
+// session token of the player
+func (d *Dispatcher) kickPlayer(Id int, reason string) (string, error) {
+	for idx, p := range d.players {
+		if p.Id != Id {
+			continue
+		}
+		remote, ok := p.render.(*netproto.RemoteRender)
+		if !ok && p.lostAt.IsZero() {
+			return "", errors.New("local player can not be kicked")
+		}
+		d.log.With("player", Id).Infof("kicking player: %s", reason)
+		if ok {
+			remote.Kick(reason)
+		}
+		d.dropPlayer(idx)
+		d.updateLobby()
+		return p.Token, nil
+	}
+	return "", fmt.Errorf("no player with id %d", Id)
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+// newCheckAdmin returns admin of server whose default room waits for players
+func newCheckAdmin() (*Admin, *Dispatcher) {
+	s := newCheckServer()
+	d := s.rooms.Room("").dispatcher
+	go d.Run()
+	return NewAdmin(s.rooms, s), d
+}
+
+// runAdmin runs script in one admin session and returns its output
+func runAdmin(a *Admin, script ...string) string {
+	var out bytes.Buffer
+	a.Serve(strings.NewReader(strings.Join(script, "\n")), &out)
+	return out.String()
+}
+
+func TestAdminConsole(t *testing.T) {
+	a, d := newCheckAdmin()
+	defer d.Stop()
+	render := &checkRender{}
+	d.AttachPlayer(render, "alice")
+
+	out := runAdmin(a, "players", "say hello  all", "rules", "rules single", "frobnicate",
+		"room zoo")
+	for _, want := range []string{
+		"alice", "local", "1 players in room main",
+		"rotation: classic, current rule classic",
+		"new rotation starts with the next round",
+		"unknown command 'frobnicate'",
+		"no room named 'zoo'",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("console output lacks %q:\n%s", want, out)
+		}
+	}
+
+	var said bool
+	d.Do(func() {
+		for _, m := range render.messages {
+			said = said || m.Content == "admin: hello all"
+		}
+	})
+	if !said {
+		t.Fatalf("broadcast is not delivered")
+	}
+
+	// single rule needs only alice, so next round starts at once
+	runAdmin(a, "next")
+	deadline := time.Now().Add(5 * time.Second)
+	for d.Lobby().State != engine.GAME_RUNNING || d.Lobby().Rule != "single" {
+		if time.Now().After(deadline) {
+			t.Fatalf("round with new rule did not start, lobby is %s", d.Lobby())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	runAdmin(a, "pause")
+	if !d.time.Paused() {
+		t.Error("game is not paused")
+	}
+}
+
+func TestAdminKick(t *testing.T) {
+	a, d := newCheckAdmin()
+	defer d.Stop()
+
+	server, client := net.Pipe()
+	defer client.Close()
+	rejects := make(chan *netproto.Reject, 1)
+	go func() {
+		for {
+			msg, err := netproto.ReadFrame(client)
+			if err != nil {
+				return
+			}
+			if reject, ok := msg.(*netproto.Reject); ok {
+				rejects <- reject
+			}
+		}
+	}()
+	rr := netproto.CreateRemoteRender(server)
+	Pid := d.AttachSession(rr, "bob", "token")
+	errs := make(chan error, 1)
+	go func() {
+		errs <- rr.Run()
+	}()
+
+	if out := runAdmin(a, "kick 42"); !strings.Contains(out, "no player with id 42") {
+		t.Fatalf("kick of missing player: %s", out)
+	}
+	if out := runAdmin(a, fmt.Sprint("kick ", Pid)); !strings.Contains(out, "is kicked") {
+		t.Fatalf("kick failed: %s", out)
+	}
+	// reason is flushed after frames already queued, like first snapshot of the round
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatalf("connection of kicked player is not closed")
+	}
+	select {
+	case reject := <-rejects:
+		if reject.Code != netproto.REJECT_KICKED || !strings.Contains(reject.Reason, "kicked") {
+			t.Fatalf("kicked player got %+v", reject)
+		}
+	case <-time.After(CHECK_TIMEOUT):
+		t.Fatalf("kicked player is not told why")
+	}
+	if d.Lobby().Players != 0 {
+		t.Fatalf("kicked player is still in room")
+	}
+	if !a.server.isBanned("", "", "token") {
+		t.Error("kicked session may reconnect")
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+const (
+	// no more than CHAT_BURST lines per CHAT_WINDOW from one player
+	CHAT_BURST  = 4
+	CHAT_WINDOW = 5 * time.Second
+)
+
+// sanitizeChat drops control characters and trims text to CHAT_MAX_LEN
+func sanitizeChat(text string) string {
+	var clean []rune
+	for _, r := range text {
+		if r >= ' ' && r != 0x7f {
+			clean = append(clean, r)
+		}
+		if len(clean) == netproto.CHAT_MAX_LEN {
+			break
+		}
+	}
+	return string(clean)
+}
+
+// allowChat checks player's rate limit and records the line if it is allowed
+func (p *Player) allowChat(now time.Time) bool {
+	var recent []time.Time
+	for _, t := range p.chatTimes {
+		if now.Sub(t) < CHAT_WINDOW {
+			recent = append(recent, t)
+		}
+	}
+	p.chatTimes = recent
+	if len(recent) >= CHAT_BURST {
+		return false
+	}
+	p.chatTimes = append(p.chatTimes, now)
+	return true
+}
+
+// sameTeam tells if team message from one player reaches another. Spectators talk to
+// spectators; players talk to players unless they fight each other
+func (d *Dispatcher) sameTeam(a, b *Player) bool {
+	if a.Orders == nil || b.Orders == nil {
+		return a.Orders == nil && b.Orders == nil
+	}
+	return a.Id == b.Id || !(*d.rules)[d.currentRules].Versus
+}
+
+func (d *Dispatcher) handleChat(line netproto.ChatLine) {
+	from := d.playerById(line.From)
+	if from == nil {
+		return
+	}
+	text := sanitizeChat(line.Text)
+	if text == "" {
+		return
+	}
+	if text[0] == '/' {
+		d.handleCommand(from, text[1:])
+		return
+	}
+	if !from.allowChat(time.Now()) {
+		from.render.HandleMessage(netproto.MESSAGE_LEVEL_INFO, "you are sending messages too fast")
+		return
+	}
+
+	msg := fmt.Sprintf("%s: %s", from.title(), text)
+	if line.Team {
+		msg = "[team] " + msg
+	}
+	d.log.With("player", from.Id).Infof("chat: %s", msg)
+	for idx := range d.players {
+		p := &d.players[idx]
+		if !line.Team || d.sameTeam(from, p) {
+			p.render.HandleMessage(netproto.MESSAGE_LEVEL_CHAT, msg)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+// newChatDispatcher attaches two squad owners and one spectator
+func newChatDispatcher(rule string) (*Dispatcher, []*checkRender) {
+	ruleset := &rules.Ruleset{}
+	ruleset.AddRules(rule)
+	d := NewDispatcher(ruleset, nil)
+	var renders []*checkRender
+	for idx, name := range []string{"alice", "bob", "carol"} {
+		r := &checkRender{}
+		d.attachCheckPlayer(r, name, "")
+		if idx < 2 {
+			d.players[idx].Orders = make(chan engine.Order, engine.SQUAD_ORDER_QUEUE_LEN)
+		}
+		renders = append(renders, r)
+	}
+	return d, renders
+}
+
+func chatReceived(r *checkRender) []string {
+	var lines []string
+	for _, m := range r.messages {
+		if m.Level == netproto.MESSAGE_LEVEL_CHAT {
+			lines = append(lines, m.Content)
+		}
+	}
+	return lines
+}
+
+func TestChatTeam(t *testing.T) {
+	d, renders := newChatDispatcher("classic")
+	d.handleChat(netproto.ChatLine{From: d.players[0].Id, Text: "hi\x1b all"})
+	d.handleChat(netproto.ChatLine{From: d.players[0].Id, Team: true, Text: "cover me"})
+	d.handleChat(netproto.ChatLine{From: d.players[2].Id, Team: true, Text: "boring"})
+
+	want := [][]string{
+		{"alice: hi all", "[team] alice: cover me"},
+		{"alice: hi all", "[team] alice: cover me"},
+		{"alice: hi all", "[team] carol: boring"},
+	}
+	for idx, r := range renders {
+		if got := chatReceived(r); fmt.Sprint(got) != fmt.Sprint(want[idx]) {
+			t.Fatalf("player %d got %q, want %q", idx, got, want[idx])
+		}
+	}
+
+	// in versus team is the player alone
+	d, renders = newChatDispatcher("wild-west")
+	d.handleChat(netproto.ChatLine{From: d.players[0].Id, Team: true, Text: "flank him"})
+	if len(chatReceived(renders[0])) != 1 || len(chatReceived(renders[1])) != 0 {
+		t.Error("team message leaked to enemy in versus")
+	}
+}
+
+func TestChatRateLimit(t *testing.T) {
+	d, renders := newChatDispatcher("classic")
+	for i := 0; i <= CHAT_BURST; i++ {
+		d.handleChat(netproto.ChatLine{From: d.players[1].Id, Text: "spam"})
+	}
+	if got := len(chatReceived(renders[0])); got != CHAT_BURST {
+		t.Fatalf("%d lines delivered, want %d", got, CHAT_BURST)
+	}
+	last := renders[1].messages[len(renders[1].messages)-1]
+	if last.Level != netproto.MESSAGE_LEVEL_INFO || !strings.Contains(last.Content, "too fast") {
+		t.Fatalf("sender is not told about rate limit, got %q", last.Content)
+	}
+
+	// window passes
+	p := d.playerById(d.players[1].Id)
+	if !p.allowChat(time.Now().Add(CHAT_WINDOW)) {
+		t.Error("rate limit never ends")
+	}
+}
+
+func TestChatSender(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	chat := make(chan netproto.ChatLine, 1)
+	rr := netproto.CreateRemoteRender(server)
+	rr.PingInterval = time.Hour
+	rr.AttachChat(7, chat)
+	go rr.Run()
+	go func() {
+		// drain whatever server sends
+		for {
+			if _, err := netproto.ReadFrame(client); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := netproto.WriteFrame(client, &netproto.ChatLine{From: 1, Text: "i am player one"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case line := <-chat:
+		if line.From != 7 {
+			t.Fatalf("chat line from player %d, want 7", line.From)
+		}
+	case <-time.After(CHECK_TIMEOUT):
+		t.Fatalf("chat line is not delivered")
+	}
+}
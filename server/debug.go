@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	_ "net/http/pprof"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/logging"
+	"github.com/mechmind/life-goes-on/metrics"
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+var debugLog = logging.NewLogger("debug")
+
+// RoomDebug is what dashboard knows about a room
+type RoomDebug struct {
+	Name    string
+	Lobby   netproto.LobbyInfo
+	Players []PlayerInfo
+	Stats   engine.StatsView
+	Profile engine.TickProfile
+}
+
+// RunDebugAt serves pprof, prometheus metrics and, when there are rooms to look at, live
+// game dashboard:
+//
+//	/               html page polling the api
+//	/api/rooms      rooms with lobby, players and current tick
+//	/api/room?name= full stats of the room: tick histogram, unit history and tick profile
+func RunDebugAt(addr string, rooms *RoomManager) {
+	if err := http.ListenAndServe(addr, debugHandler(rooms)); err != nil {
+		debugLog.Errorf("cannot serve: %s", err)
+	}
+}
+
+func debugHandler(rooms *RoomManager) http.Handler {
+	mux := http.NewServeMux()
+	// pprof registers itself in default mux
+	mux.Handle("/debug/", http.DefaultServeMux)
+	mux.HandleFunc("/metrics", metrics.MetricsHandler)
+	if rooms != nil {
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(debugPage))
+		})
+		mux.HandleFunc("/api/rooms", func(w http.ResponseWriter, r *http.Request) {
+			var list []RoomDebug
+			for _, room := range rooms.Rooms() {
+				info := roomDebug(room)
+				// summary only, history is big
+				info.Stats.History = nil
+				info.Stats.TickHistogram = nil
+				info.Profile.Phases = nil
+				list = append(list, info)
+			}
+			writeJSON(w, list)
+		})
+		mux.HandleFunc("/api/room", func(w http.ResponseWriter, r *http.Request) {
+			room := rooms.Room(r.URL.Query().Get("name"))
+			if room == nil {
+				http.Error(w, "no such room", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, roomDebug(room))
+		})
+	}
+	return mux
+}
+
+func roomDebug(room *Room) RoomDebug {
+	d := room.dispatcher
+	info := RoomDebug{Name: room.Name, Lobby: d.Lobby(), Stats: d.stats.View(),
+		Profile: d.profiler.Profile()}
+	d.Do(func() {
+		info.Players = d.playerInfos()
+	})
+	return info
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		debugLog.Warnf("cannot encode response: %s", err)
+	}
+}
+
+const debugPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>lgo dashboard</title>
+<style>
+body { font-family: monospace; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: right; }
+th { background: #eee; }
+.bar { background: #6a6; display: inline-block; height: 10px; }
+</style>
+</head>
+<body>
+<h1>life goes on</h1>
+<div id="rooms"></div>
+<p><a href="/debug/pprof/">pprof</a></p>
+<script>
+// names come from players
+function esc(s) {
+  return String(s).replace(/[&<>"']/g, function(c) { return "&#" + c.charCodeAt(0) + ";"; });
+}
+
+function ms(ns) { return (ns / 1e6).toFixed(1) + "ms"; }
+
+function table(head, rows) {
+  var html = "<table><tr>" + head.map(function(h) { return "<th>" + h + "</th>"; }).join("") + "</tr>";
+  rows.forEach(function(row) {
+    html += "<tr>" + row.map(function(c) { return "<td>" + c + "</td>"; }).join("") + "</tr>";
+  });
+  return html + "</table>";
+}
+
+function renderRoom(room) {
+  var s = room.Stats;
+  var html = "<h2>" + esc(room.Name) + "</h2><p>rule " + room.Lobby.Rule + ", tick " + s.Tick +
+    ", last tick " + ms(s.TickTime) + ", " + s.PathCalls + " path searches</p>";
+  html += table(["id", "name", "address", "state", "latency", "dropped", "sent"],
+    (room.Players || []).map(function(p) {
+      return [p.Id, esc(p.Name), p.Addr, p.State, ms(p.Latency), p.Dropped, p.Sent];
+    }));
+
+  var total = 0;
+  s.TickHistogram.forEach(function(b) { total += b.Count; });
+  html += table(["tick time", "ticks", ""], s.TickHistogram.map(function(b) {
+    var width = total ? Math.round(300 * b.Count / total) : 0;
+    return [b.Le ? "&le; " + ms(b.Le) : "more", b.Count,
+      "<span class=bar style='width:" + width + "px'></span>"];
+  }));
+
+  var history = (s.History || []).slice(-20).reverse();
+  html += table(["tick", "soldiers", "damsels", "zeds", "corpses", "path searches/s"],
+    history.map(function(h) {
+      return [h.Tick, h.Soldiers, h.Damsels, h.Zeds, h.Corpses, h.PathCalls];
+    }));
+
+  var p = room.Profile;
+  html += "<p>" + p.Ticks + " ticks profiled, " + p.SlowTicks + " over budget of " + ms(p.Budget) + "</p>";
+  html += table(["phase", "total", "avg", "max"], (p.Phases || []).map(function(ph) {
+    return [esc(ph.Phase), ms(ph.Total), ms(ph.Ticks ? ph.Total / ph.Ticks : 0), ms(ph.Max)];
+  }));
+  return html;
+}
+
+function refresh() {
+  fetch("/api/rooms").then(function(r) { return r.json(); }).then(function(rooms) {
+    return Promise.all((rooms || []).map(function(room) {
+      return fetch("/api/room?name=" + encodeURIComponent(room.Name)).then(function(r) { return r.json(); });
+    }));
+  }).then(function(rooms) {
+    document.getElementById("rooms").innerHTML = rooms.map(renderRoom).join("");
+  });
+}
+
+refresh();
+setInterval(refresh, 1000);
+</script>
+</body>
+</html>
+`
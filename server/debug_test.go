@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/metrics"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+func TestTickStats(t *testing.T) {
+	f := engine.NewField(32, 32, make(chan *engine.Field, 1))
+	f.Stats = engine.NewRoomStats()
+	orders := engine.PlaceSquad(f, 0, 0, rules.AllRules["classic"])
+	orders <- engine.Order{Order: engine.ORDER_MOVE, Coord: geom.CellCoord{X: 25, Y: 5}}
+	for tick := int64(1); tick <= 2*engine.STATS_SAMPLE_TICKS; tick++ {
+		f.Tick(tick)
+	}
+
+	view := f.Stats.View()
+	var ticks int64
+	for _, b := range view.TickHistogram {
+		ticks += b.Count
+	}
+	if ticks != 2*engine.STATS_SAMPLE_TICKS || view.Tick != 2*engine.STATS_SAMPLE_TICKS {
+		t.Fatalf("%d ticks in histogram, last tick %d", ticks, view.Tick)
+	}
+	if len(view.History) != 2 {
+		t.Fatalf("%d unit samples, want 2", len(view.History))
+	}
+	last := view.History[1]
+	if last.Soldiers != len(f.Units) {
+		t.Fatalf("sample counts %d soldiers, want %d", last.Soldiers, len(f.Units))
+	}
+	if view.History[0].PathCalls <= 0 {
+		t.Error("moving squad made no path searches")
+	}
+}
+
+func TestDashboardAPI(t *testing.T) {
+	a, d := newCheckAdmin()
+	defer d.Stop()
+	d.AttachPlayer(&checkRender{}, "alice")
+	handler := debugHandler(a.rooms)
+
+	get := func(url string, v interface{}) error {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		if rec.Code != http.StatusOK {
+			return fmt.Errorf("GET %s: status %d", url, rec.Code)
+		}
+		return json.Unmarshal(rec.Body.Bytes(), v)
+	}
+
+	var rooms []RoomDebug
+	if err := get("/api/rooms", &rooms); err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 || rooms[0].Name != ROOM_DEFAULT || len(rooms[0].Players) != 1 ||
+		rooms[0].Players[0].Name != "alice" {
+		t.Fatalf("room list is %+v", rooms)
+	}
+
+	var room RoomDebug
+	if err := get("/api/room?name="+ROOM_DEFAULT, &room); err != nil {
+		t.Fatal(err)
+	}
+	if len(room.Stats.TickHistogram) != len(engine.StatsTickBuckets)+1 {
+		t.Fatalf("room histogram has %d buckets", len(room.Stats.TickHistogram))
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/room?name=zoo", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing room gives status %d", rec.Code)
+	}
+}
+
+// checkMetricsRegistered makes sure metrics of every package show up at /metrics
+func TestMetricsRegistered(t *testing.T) {
+	var out bytes.Buffer
+	metrics.WriteMetrics(&out)
+	for _, name := range []string{"lgo_rounds_started_total", "lgo_players_connected",
+		"lgo_tick_duration_seconds_count", "lgo_dropped_updates_total", "lgo_encode_errors_total"} {
+		if !strings.Contains(out.String(), name) {
+			t.Fatalf("metrics lack %s", name)
+		}
+	}
+}
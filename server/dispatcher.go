@@ -0,0 +1,624 @@
+// Package server runs rounds for players in rooms and serves remote players, admin
+// console and debug dashboard.
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/logging"
+	"github.com/mechmind/life-goes-on/metrics"
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+const (
+	DISP_ATTACH = iota
+	DISP_DETACH
+)
+
+const (
+	GAMEOVER_COUNTDOWN = 5
+)
+
+const (
+	// time for player to reconnect before his squad is wiped
+	DISP_RECONNECT_GRACE = netproto.SESSION_GRACE
+	DISP_REAP_INTERVAL   = time.Second
+)
+
+var (
+	metricRoundsStarted = metrics.NewCounter("lgo_rounds_started_total",
+		"Rounds started.", "rule")
+	metricRoundsFinished = metrics.NewCounter("lgo_rounds_finished_total",
+		"Rounds finished, outcome is won, lost or aborted.", "rule", "outcome")
+	metricPlayers = metrics.NewGauge("lgo_players_connected",
+		"Players in all rooms, including ones waiting for reconnect.")
+)
+
+type Dispatcher struct {
+	field        *engine.Field
+	players      []Player
+	rules        *rules.Ruleset
+	currentRules int
+	lastid       int
+	playerQueue  chan PlayerReq
+	chat         chan netproto.ChatLine
+	time         *engine.Time
+	gameState    chan engine.GameState
+	profiles     *ProfileStore
+	stop         chan struct{}
+	stats        *engine.RoomStats
+	vote         *paceVote
+	profiler     *engine.TickProfiler
+	log          *logging.Logger
+	round        int
+
+	// admin requests run on dispatcher goroutine
+	admin        chan func()
+	nextRound    bool
+	pendingRules *rules.Ruleset
+
+	// current round results
+	scoreboard *netproto.Scoreboard
+	results    map[int]*RoundResult
+
+	// lobby is read by server goroutines during handshake
+	lobbyLock  sync.Mutex
+	lobby      netproto.LobbyInfo
+	roundState int
+}
+
+type Player struct {
+	render netproto.Render
+	Id     int
+	Name   string
+	Orders chan engine.Order
+	Token  string
+	lostAt time.Time // zero while connected
+
+	chatTimes []time.Time
+}
+
+func (p *Player) title() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return fmt.Sprint(p.Id)
+}
+
+type PlayerReq struct {
+	p    Player
+	op   int
+	Id   int
+	resp chan int
+}
+
+func NewDispatcher(r *rules.Ruleset, profiles *ProfileStore) *Dispatcher {
+	d := &Dispatcher{rules: r, playerQueue: make(chan PlayerReq), chat: make(chan netproto.ChatLine, netproto.CHAT_QUEUE),
+		time: engine.NewTime(engine.TIME_TICKS_PER_SEC), profiles: profiles, roundState: engine.GAME_WAIT,
+		stop: make(chan struct{}), admin: make(chan func()), stats: engine.NewRoomStats(),
+		log: logging.NewLogger("dispatcher")}
+	d.profiler = engine.NewTickProfiler(d.log)
+	d.updateLobby()
+	return d
+}
+
+// Lobby returns current lobby state, safe to call from any goroutine
+func (d *Dispatcher) Lobby() netproto.LobbyInfo {
+	d.lobbyLock.Lock()
+	defer d.lobbyLock.Unlock()
+	return d.lobby
+}
+
+func (d *Dispatcher) setRoundState(state int) {
+	d.roundState = state
+	d.updateLobby()
+}
+
+func (d *Dispatcher) updateLobby() {
+	var lobby = netproto.LobbyInfo{Players: d.countPlayers(), State: d.roundState}
+	if len(*d.rules) > 0 {
+		rules := (*d.rules)[d.currentRules]
+		lobby.Rule = rules.Name
+		lobby.MaxPlayers = rules.MaxPlayers
+		if lobby.Players < rules.MinPlayers {
+			lobby.Needed = rules.MinPlayers - lobby.Players
+		}
+	}
+
+	d.lobbyLock.Lock()
+	d.lobby = lobby
+	d.lobbyLock.Unlock()
+}
+
+// setRoom names dispatcher in logs, must be called before Run
+func (d *Dispatcher) setRoom(name string) {
+	d.log = d.log.With("room", name)
+	d.profiler.Log = d.log
+}
+
+func (d *Dispatcher) roundLog() *logging.Logger {
+	return d.log.With("round", d.round)
+}
+
+// Stop ends the match, Run returns soon after. Players must be detached by then
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+}
+
+func (d *Dispatcher) stopped() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Do runs fn on dispatcher goroutine and waits for it, false if dispatcher is stopped
+func (d *Dispatcher) Do(fn func()) bool {
+	done := make(chan struct{})
+	select {
+	case d.admin <- func() { fn(); close(done) }:
+	case <-d.stop:
+		return false
+	}
+	<-done
+	return true
+}
+
+func (d *Dispatcher) AttachPlayer(r netproto.Render, name string) int {
+	return d.AttachSession(r, name, "")
+}
+
+// AttachSession attaches remote player. Player which lost connection during the round
+// gets his Pid and squad back if he comes with the same token in time
+func (d *Dispatcher) AttachSession(r netproto.Render, name, token string) int {
+	req := PlayerReq{Player{render: r, Name: name, Token: token}, DISP_ATTACH, -1,
+		make(chan int, 1)}
+	d.playerQueue <- req
+	return <-req.resp
+}
+
+func (d *Dispatcher) DetachPlayer(Id int) {
+	d.DetachSession(Id, nil)
+}
+
+// DetachSession detaches player only if he still uses given render, so stale connection
+// of resumed player does not kick him
+func (d *Dispatcher) DetachSession(Id int, r netproto.Render) {
+	req := PlayerReq{Player{render: r}, DISP_DETACH, Id, make(chan int, 1)}
+	d.playerQueue <- req
+	<-req.resp
+}
+
+// handlePlayerReq returns true if attached player is resumed one
+func (d *Dispatcher) handlePlayerReq(r PlayerReq) bool {
+	switch r.op {
+	case DISP_ATTACH:
+		if p := d.sessionPlayer(r.p.Token); p != nil {
+			d.resumePlayer(p, r.p.render)
+			r.resp <- p.Id
+			return true
+		}
+
+		d.players = append(d.players, r.p)
+		Pid := d.lastid
+		d.lastid++
+		d.players[len(d.players)-1].Id = Pid
+		metricPlayers.Add(1)
+		r.p.render.AttachChat(Pid, d.chat)
+		r.resp <- Pid
+		if r.p.Name != "" {
+			d.sendAll(netproto.MESSAGE_LEVEL_INFO, fmt.Sprintf("player %s joined the match", r.p.Name))
+		} else {
+			d.sendAll(netproto.MESSAGE_LEVEL_INFO, "player joined the match")
+		}
+		if d.profiles != nil {
+			r.p.render.HandleLeaderboard(d.profiles.Leaderboard())
+		}
+		r.p.render.HandleMessage(netproto.MESSAGE_LEVEL_SPEED, d.time.Status())
+		d.log.With("player", Pid).Infof("attached new player %s", r.p.title())
+	case DISP_DETACH:
+		defer func() { r.resp <- 0 }()
+		for idx, p := range d.players {
+			if p.Id == r.Id {
+				if r.p.render != nil && p.render != r.p.render {
+					d.log.With("player", p.Id).Infof("stale connection is closed")
+					return false
+				}
+				if p.Orders != nil && p.Token != "" && d.roundState == engine.GAME_RUNNING {
+					d.loseConnection(idx)
+				} else {
+					d.dropPlayer(idx)
+				}
+				d.updateLobby()
+				return false
+			}
+		}
+		d.log.With("player", r.Id).Warnf("cannot detach player: no player with id %d", r.Id)
+	}
+	d.log.Debugf("total players now: %d", d.countPlayers())
+	d.updateLobby()
+	return false
+}
+
+func (d *Dispatcher) dropPlayer(idx int) {
+	p := d.players[idx]
+	if p.Orders != nil {
+		// kill squad
+		p.Orders <- engine.Order{Order: engine.ORDER_SUICIDE, Coord: geom.CellCoord{}}
+	}
+	copy(d.players[idx:], d.players[idx+1:])
+	d.players = d.players[:len(d.players)-1]
+	metricPlayers.Add(-1)
+	d.sendAll(netproto.MESSAGE_LEVEL_INFO, "player left the match")
+	d.log.With("player", p.Id).Infof("detached player")
+}
+
+// loseConnection keeps player's squad holding position until he reconnects
+func (d *Dispatcher) loseConnection(idx int) {
+	p := &d.players[idx]
+	p.render = netproto.NopRender{}
+	p.lostAt = time.Now()
+	select {
+	case p.Orders <- engine.Order{Order: engine.ORDER_HOLD, Coord: geom.CellCoord{}}:
+	default:
+	}
+	d.sendAll(netproto.MESSAGE_LEVEL_INFO, fmt.Sprintf("player %s lost connection", p.title()))
+	d.log.With("player", p.Id).Infof("lost connection, waiting for reconnect")
+}
+
+// sessionPlayer finds player with given token. He may still look connected if client
+// noticed broken connection before server did
+func (d *Dispatcher) sessionPlayer(token string) *Player {
+	if token == "" {
+		return nil
+	}
+	for idx := range d.players {
+		if d.players[idx].Token == token {
+			return &d.players[idx]
+		}
+	}
+	return nil
+}
+
+// HasSession returns true if token belongs to connected or lost player
+func (d *Dispatcher) HasSession(token string) bool {
+	var found bool
+	d.Do(func() { found = d.sessionPlayer(token) != nil })
+	return found
+}
+
+func (d *Dispatcher) resumePlayer(p *Player, r netproto.Render) {
+	d.log.With("player", p.Id).Infof("reconnected")
+	p.render = r
+	p.lostAt = time.Time{}
+	r.AttachChat(p.Id, d.chat)
+	r.HandleGameState(engine.GameState{State: d.roundState, Player: -1})
+	r.HandleMessage(netproto.MESSAGE_LEVEL_SPEED, d.time.Status())
+	if p.Orders != nil && d.roundState != engine.GAME_WAIT {
+		r.AssignSquad(p.Id, p.Orders)
+	} else {
+		r.Spectate()
+	}
+	d.sendAll(netproto.MESSAGE_LEVEL_INFO, fmt.Sprintf("player %s is back", p.title()))
+}
+
+// reapLost drops players which did not come back in time
+func (d *Dispatcher) reapLost(grace time.Duration) {
+	for idx := len(d.players) - 1; idx >= 0; idx-- {
+		p := d.players[idx]
+		if !p.lostAt.IsZero() && time.Since(p.lostAt) >= grace {
+			d.log.With("player", p.Id).Infof("have not reconnected, dropping")
+			d.dropPlayer(idx)
+		}
+	}
+	d.updateLobby()
+}
+
+func (d *Dispatcher) countPlayers() int {
+	return len(d.players)
+}
+
+func (d *Dispatcher) playerById(Id int) *Player {
+	for idx := range d.players {
+		if d.players[idx].Id == Id {
+			return &d.players[idx]
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) Run() {
+	d.log.Infof("starting up")
+rounds:
+	for {
+		rules := (*d.rules)[d.currentRules]
+
+		d.round++
+		d.roundLog().Infof("starting new round with rule '%s', generating field", rules.Name)
+		// generate field
+		d.field = engine.GenerateField(rules)
+		d.field.Stats = d.stats
+		d.field.Profiler = d.profiler
+		d.gameState = d.field.GameState
+		d.setRoundState(engine.GAME_WAIT)
+		// squads of lost players are gone with old field
+		d.reapLost(0)
+
+		// reset state of existing players
+		d.roundLog().Debugf("resetting state for all connected players")
+		for _, p := range d.players {
+			p.render.Reset()
+			p.render.Spectate()
+			p.render.HandleUpdate(engine.SnapshotFor(engine.CopyField(d.field), p.Id, rules))
+			p.render.HandleGameState(engine.GameState{State: engine.GAME_WAIT, Player: -1})
+		}
+
+		// wait for desired amount of players to join
+		d.roundLog().Infof("waiting for players")
+		for {
+			if d.countPlayers() >= rules.MinPlayers {
+				break
+			}
+			d.sendAll(netproto.MESSAGE_LEVEL_INFO,
+				fmt.Sprintf("waiting for %d players to join...",
+					rules.MinPlayers-d.countPlayers()))
+			var req PlayerReq
+			select {
+			case req = <-d.playerQueue:
+			case line := <-d.chat:
+				d.handleChat(line)
+				continue
+			case fn := <-d.admin:
+				fn()
+				if d.nextRound {
+					d.nextRound = false
+					d.rotateRules()
+					continue rounds
+				}
+				continue
+			case <-d.stop:
+				d.roundLog().Infof("stopped while waiting for players")
+				return
+			}
+			resumed := d.handlePlayerReq(req)
+			switch {
+			case resumed:
+				p := d.sessionPlayer(req.p.Token)
+				d.roundLog().With("player", p.Id).Debugf("player resumed in wait stage")
+			case req.op == DISP_ATTACH:
+				newPlayer := d.players[len(d.players)-1]
+				d.roundLog().With("player", newPlayer.Id).Debugf("new player in wait stage, set it up")
+				newPlayer.render.Spectate()
+				newPlayer.render.HandleUpdate(engine.SnapshotFor(engine.CopyField(d.field), newPlayer.Id, rules))
+			default:
+				d.roundLog().With("player", req.Id).Debugf("player detached in wait stage")
+			}
+		}
+		// run game
+		d.runGame()
+		if d.stopped() {
+			d.roundLog().Infof("stopped")
+			return
+		}
+		// cleanup
+		d.rotateRules()
+	}
+}
+
+// rotateRules picks rules for the next round, rotation set by admin starts from beginning
+func (d *Dispatcher) rotateRules() {
+	if d.pendingRules != nil {
+		d.rules, d.pendingRules = d.pendingRules, nil
+		d.currentRules = 0
+		return
+	}
+	d.currentRules = (d.currentRules + 1) % len(*d.rules)
+}
+
+func (d *Dispatcher) runGame() {
+	// bind players to squads
+	log := d.roundLog()
+	log.Infof("starting game")
+	rules := (*d.rules)[d.currentRules]
+	for idx, Player := range d.players {
+		Player.render.HandleGameState(engine.GameState{State: engine.GAME_RUNNING, Player: -1})
+		if idx < rules.MaxPlayers {
+			log.With("player", Player.Id).Infof("player now controls squad")
+			Player.Orders = engine.PlaceSquad(d.field, idx, Player.Id, rules)
+			Player.render.AssignSquad(Player.Id, Player.Orders)
+			d.players[idx].Orders = Player.Orders
+		} else {
+			log.With("player", Player.Id).Infof("player is spectating")
+			Player.render.Spectate()
+			d.players[idx].Orders = nil
+		}
+	}
+
+	d.setRoundState(engine.GAME_RUNNING)
+
+	log.Debugf("populating field")
+	engine.PopulateField(d.field, rules)
+	metricRoundsStarted.Inc(rules.Name)
+	// round stopped before game over is aborted
+	var won bool
+	var outcome = "aborted"
+	defer func() { metricRoundsFinished.Inc(rules.Name, outcome) }()
+
+	// reset round results
+	d.scoreboard = nil
+	d.results = make(map[int]*RoundResult)
+	for _, p := range d.players {
+		if p.Orders != nil && p.Name != "" {
+			d.results[p.Id] = &RoundResult{Name: p.Name, Rule: rules.Name}
+		}
+	}
+
+	// pace voted in previous round does not carry over
+	d.vote = nil
+	d.time.SetPaused(false)
+	d.time.SetSpeed(1)
+
+	// start game timer
+	d.time.SetTicker(d.field)
+	var countdownTicker <-chan time.Time
+	var reapTicker = time.NewTicker(DISP_REAP_INTERVAL)
+	defer reapTicker.Stop()
+	go d.time.Run()
+	defer d.time.Stop()
+
+	d.sendAll(netproto.MESSAGE_LEVEL_RULE, rules.String())
+	d.sendAll(netproto.MESSAGE_LEVEL_SPEED, d.time.Status())
+
+	var countdownMsg = "new round in "
+	var countdown = GAMEOVER_COUNTDOWN
+	// game time keeps ticking during countdown, survivors are recorded with time of game over
+	var roundTime time.Duration
+	log.Debugf("entering game")
+	for {
+		select {
+		case field := <-d.field.Updates:
+			// sanitize field and send it to all players
+			for _, p := range d.players {
+				p.render.HandleUpdate(engine.SnapshotFor(field, p.Id, rules))
+			}
+		case pr := <-d.playerQueue:
+			resumed := d.handlePlayerReq(pr)
+			if resumed {
+				continue
+			}
+			if pr.op == DISP_ATTACH {
+				log.With("player", d.players[len(d.players)-1].Id).Infof("new player in middle of round, spectate")
+				d.players[len(d.players)-1].render.Spectate()
+			} else {
+				log.With("player", pr.Id).Infof("player have quit in middle of round")
+			}
+		case summary := <-d.field.Summary:
+			sb := netproto.NewScoreboard(rules.Name, summary)
+			for idx := range sb.Players {
+				if p := d.playerById(sb.Players[idx].Pid); p != nil {
+					sb.Players[idx].Name = p.Name
+				}
+			}
+			d.scoreboard = sb
+			log.Debugf("sending scoreboard to all players")
+			for _, p := range d.players {
+				p.render.HandleScoreboard(sb)
+			}
+		case State := <-d.gameState:
+			if State.Player >= 0 {
+				Player := d.playerById(State.Player)
+				if Player == nil {
+					continue
+				}
+				Player.render.HandleGameState(State)
+				d.trackResult(State)
+				switch {
+				case State.State&engine.GAME_LOSE > 0:
+					d.sendAll(netproto.MESSAGE_LEVEL_INFO,
+						fmt.Sprintf("player %s has been exterminated", Player.title()))
+				case State.State&engine.GAME_WIN > 0:
+					won = true
+					d.sendAll(netproto.MESSAGE_LEVEL_INFO, fmt.Sprintf("player %s has won!", Player.title()))
+				}
+			} else {
+				for _, p := range d.players {
+					p.render.HandleGameState(State)
+				}
+			}
+
+			if State.State == engine.GAME_OVER {
+				// game is over
+				log.Infof("game is over")
+				if roundTime == 0 {
+					roundTime = d.time.Elapsed()
+				}
+				d.setRoundState(engine.GAME_OVER)
+				if outcome = "lost"; won {
+					outcome = "won"
+				}
+				countdownTicker = time.Tick(time.Second)
+			}
+		case line := <-d.chat:
+			d.handleChat(line)
+		case <-reapTicker.C:
+			d.reapLost(DISP_RECONNECT_GRACE)
+		case fn := <-d.admin:
+			fn()
+			if d.nextRound {
+				d.nextRound = false
+				log.Infof("round is ended by admin")
+				d.sendAll(netproto.MESSAGE_LEVEL_INFO, "round is ended by admin")
+				return
+			}
+		case <-d.stop:
+			return
+		case <-countdownTicker:
+			countdown--
+			countdownMsg += fmt.Sprintf("%d... ", countdown)
+			d.sendAll(netproto.MESSAGE_LEVEL_INFO, countdownMsg)
+			if countdown == 0 {
+				d.recordResults(roundTime)
+				return
+			}
+		}
+	}
+}
+
+// trackResult updates results of current round for player
+func (d *Dispatcher) trackResult(State engine.GameState) {
+	result, ok := d.results[State.Player]
+	if !ok {
+		return
+	}
+	switch {
+	case State.State&engine.GAME_LOSE > 0:
+		result.Survived = d.time.Elapsed()
+	case State.State&engine.GAME_WIN > 0:
+		result.Won = true
+	}
+}
+
+// recordResults saves round results into player profiles, roundTime is game time
+// at which round was over
+func (d *Dispatcher) recordResults(roundTime time.Duration) {
+	if d.profiles == nil {
+		return
+	}
+
+	for Pid, result := range d.results {
+		if result.Survived == 0 {
+			// still alive at the end of the round
+			result.Survived = roundTime
+		}
+		if d.scoreboard != nil {
+			for _, stats := range d.scoreboard.Players {
+				if stats.Pid == Pid {
+					result.Stats = stats
+				}
+			}
+		}
+		d.profiles.Record(*result)
+	}
+
+	if err := d.profiles.Save(); err != nil {
+		d.log.Errorf("failed to save profiles: %s", err)
+	}
+
+	lb := d.profiles.Leaderboard()
+	for _, p := range d.players {
+		p.render.HandleLeaderboard(lb)
+	}
+}
+
+func (d *Dispatcher) sendAll(lvl int, msg string) {
+	for _, p := range d.players {
+		p.render.HandleMessage(lvl, msg)
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+// newCheckServer returns server with idle dispatcher, suitable for handshakes only
+func newCheckServer() *Server {
+	ruleset := &rules.Ruleset{}
+	ruleset.AddRules("classic")
+	return newServer(NewRoomManager(NewDispatcher(ruleset, nil), nil), "check")
+}
+
+// checkHandshake runs server side of handshake against netproto.SendHandshake over pipe
+func checkHandshake(s *Server, hello *netproto.Hello) (*netproto.Welcome, error) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	go s.handshake(server, "10.0.0.1")
+	return netproto.SendHandshake(client, hello)
+}
+
+func TestProtoMismatch(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		_, _, err := newCheckServer().handshake(server, "10.0.0.1")
+		errs <- err
+	}()
+
+	header := netproto.ProtoHeader
+	if _, err := client.Write(header[:]); err != nil {
+		t.Fatal(err)
+	}
+	hello := &netproto.Hello{Version: netproto.PROTO_VERSION - 1, Name: "old"}
+	if err := netproto.WriteFrame(client, hello); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := netproto.ReadFrame(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reject, ok := msg.(*netproto.Reject)
+	if !ok || reject.Code != netproto.REJECT_VERSION {
+		t.Fatalf("old client got %+v, want version rejection", msg)
+	}
+	if !strings.Contains(reject.Reason, "version mismatch") {
+		t.Fatalf("unclear rejection reason %q", reject.Reason)
+	}
+	if <-errs == nil {
+		t.Error("server accepted mismatched client")
+	}
+}
+
+func TestProtoHandshake(t *testing.T) {
+	s := newCheckServer()
+	info, err := checkHandshake(s, &netproto.Hello{Name: "bob\x07"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Token) != SERVER_TOKEN_LEN*2 {
+		t.Fatalf("bad session token %q",
+			info.Token)
+	}
+	want := &netproto.Welcome{Version: netproto.PROTO_VERSION, Server: "check", Room: ROOM_DEFAULT, Lobby: netproto.LobbyInfo{Rule: "classic", Players: 0, Needed: 2, MaxPlayers: 4, State: engine.GAME_WAIT},
+		Token: info.Token}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("got welcome %+v, want %+v", info, want)
+	}
+}
+
+func TestProtoRejects(t *testing.T) {
+	for _, banned := range []string{"mallory", "10.0.0.1"} {
+		s := newCheckServer()
+		s.Ban(banned)
+		_, err := checkHandshake(s, &netproto.Hello{Name: "mallory"})
+		if r, ok := err.(*netproto.Reject); !ok || r.Code != netproto.REJECT_BANNED {
+			t.Fatalf("player banned as %s got %v, want ban rejection", banned, err)
+		}
+	}
+
+	s := newCheckServer()
+	s.maxClients = 1
+	if _, err := checkHandshake(s, &netproto.Hello{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := checkHandshake(s, &netproto.Hello{Name: "bob"})
+	if r, ok := err.(*netproto.Reject); !ok || r.Code != netproto.REJECT_FULL {
+		t.Fatalf("extra player got %v, want full server rejection", err)
+	}
+}
+
+func TestOversizedHello(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		_, _, err := newCheckServer().handshake(server, "10.0.0.1")
+		errs <- err
+	}()
+
+	header := netproto.ProtoHeader
+	if _, err := client.Write(header[:]); err != nil {
+		t.Fatal(err)
+	}
+	hello := &netproto.Hello{Name: strings.Repeat("z", netproto.PROTO_MAX_HELLO_SIZE)}
+	go netproto.WriteFrame(client, hello)
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "invalid frame length") {
+		t.Errorf("oversized hello got %v, want invalid frame length", err)
+	}
+}
+
+func TestSessionToken(t *testing.T) {
+	s := newCheckServer()
+	defer s.rooms.reap(0)
+
+	info, err := checkHandshake(s, &netproto.Hello{Name: "alice", Room: "zoo", Rule: "single",
+		Token: "forged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Token == "forged" || len(info.Token) != SERVER_TOKEN_LEN*2 {
+		t.Fatalf("unknown token is accepted as %q", info.Token)
+	}
+
+	room, err := s.rooms.Join("zoo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.rooms.Leave(room)
+	room.dispatcher.AttachSession(&checkRender{}, "alice", info.Token)
+	resumed, err := checkHandshake(s, &netproto.Hello{Name: "alice", Room: "zoo", Token: info.Token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Token != info.Token {
+		t.Errorf("live session token %q is replaced with %q", info.Token, resumed.Token)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+const (
+	// vote fails if majority is not reached in time
+	VOTE_TIMEOUT = 30 * time.Second
+)
+
+const paceUsage = "commands are /pause, /resume, /step, /speed faster|slower|N and /yes"
+
+// paceVote is a pending vote of players for changing pace of the game. Every player with
+// a squad has a vote, command passes when more than half of them agree
+type paceVote struct {
+	command string
+	voters  map[int]bool
+	started time.Time
+}
+
+// handleCommand handles chat line starting with slash
+func (d *Dispatcher) handleCommand(from *Player, text string) {
+	command, err := d.paceCommand(strings.Fields(text))
+	if err == nil && from.Orders == nil {
+		err = errors.New("spectators cannot vote")
+	}
+	if err != nil {
+		from.render.HandleMessage(netproto.MESSAGE_LEVEL_INFO, err.Error())
+		return
+	}
+	d.castVote(from, command, time.Now())
+}
+
+// paceCommand turns chat command into pace command with absolute speed
+func (d *Dispatcher) paceCommand(fields []string) (string, error) {
+	if len(fields) == 0 {
+		return "", errors.New(paceUsage)
+	}
+	switch fields[0] {
+	case "yes":
+		if d.vote == nil {
+			return "", errors.New("nothing to vote for")
+		}
+		return d.vote.command, nil
+	case "pause", "resume":
+		return fields[0], nil
+	case "step":
+		if !d.time.Paused() {
+			return "", errors.New("game must be paused to step")
+		}
+		return "step", nil
+	case "speed":
+		if len(fields) != 2 {
+			return "", errors.New("usage: /speed faster|slower|N")
+		}
+		var speed float64
+		switch fields[1] {
+		case "faster":
+			speed = engine.ShiftSpeed(d.time.Speed(), 1)
+		case "slower":
+			speed = engine.ShiftSpeed(d.time.Speed(), -1)
+		default:
+			var err error
+			if speed, err = engine.ParseSpeed(fields[1]); err != nil {
+				return "", err
+			}
+		}
+		return "speed " + engine.FormatSpeed(speed), nil
+	}
+	return "", fmt.Errorf("unknown command /%s, %s", fields[0], paceUsage)
+}
+
+// electorate is number of connected players with squads
+func (d *Dispatcher) electorate() int {
+	var count int
+	for _, p := range d.players {
+		if p.Orders != nil && p.lostAt.IsZero() {
+			count++
+		}
+	}
+	return count
+}
+
+func (d *Dispatcher) castVote(from *Player, command string, now time.Time) {
+	if d.vote == nil || d.vote.command != command || now.Sub(d.vote.started) > VOTE_TIMEOUT {
+		d.vote = &paceVote{command: command, voters: make(map[int]bool), started: now}
+	}
+	d.vote.voters[from.Id] = true
+
+	needed := d.electorate()/2 + 1
+	if len(d.vote.voters) >= needed {
+		d.vote = nil
+		if err := d.setPace(command); err != nil {
+			from.render.HandleMessage(netproto.MESSAGE_LEVEL_INFO, err.Error())
+			return
+		}
+		if needed > 1 && command != "step" {
+			d.sendAll(netproto.MESSAGE_LEVEL_INFO, fmt.Sprintf("vote passed: %s", command))
+		}
+		return
+	}
+	// only unfinished votes are announced, so they count as chat
+	if !from.allowChat(now) {
+		from.render.HandleMessage(netproto.MESSAGE_LEVEL_INFO, "you are sending messages too fast")
+		return
+	}
+	d.sendAll(netproto.MESSAGE_LEVEL_INFO, fmt.Sprintf("%s votes for %s (%d/%d), /yes to agree",
+		from.title(), command, len(d.vote.voters), needed))
+}
+
+// setPace runs pace command: pause, resume, step or speed N, and tells players new pace
+func (d *Dispatcher) setPace(command string) error {
+	fields := strings.Fields(command)
+	switch {
+	case command == "pause":
+		d.time.SetPaused(true)
+	case command == "resume":
+		d.time.SetPaused(false)
+	case command == "step":
+		if !d.time.Step() {
+			return errors.New("game must be paused to step")
+		}
+		return nil
+	case len(fields) == 2 && fields[0] == "speed":
+		speed, err := engine.ParseSpeed(fields[1])
+		if err != nil {
+			return err
+		}
+		d.time.SetSpeed(speed)
+	default:
+		return fmt.Errorf("unknown pace command '%s'", command)
+	}
+	d.log.Infof("pace is %s", d.time.Status())
+	d.sendAll(netproto.MESSAGE_LEVEL_SPEED, d.time.Status())
+	return nil
+}
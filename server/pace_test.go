@@ -0,0 +1,145 @@
+package server
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+type countingTicker struct {
+	ticks int64
+}
+
+func (c *countingTicker) Tick(tick int64) {
+	atomic.AddInt64(&c.ticks, 1)
+}
+
+func (c *countingTicker) count() int64 {
+	return atomic.LoadInt64(&c.ticks)
+}
+
+// waitTicks waits until ticker counts at least n ticks
+func (c *countingTicker) waitTicks(n int64, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for c.count() < n {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+func TestTimePace(t *testing.T) {
+	clock := engine.NewTime(engine.TIME_TICKS_PER_SEC)
+	ticker := &countingTicker{}
+	clock.SetTicker(ticker)
+	if clock.Step() {
+		t.Fatalf("running game can be stepped")
+	}
+	clock.SetPaused(true)
+	go clock.Run()
+	defer clock.Stop()
+
+	for i := 0; i < 3; i++ {
+		clock.Step()
+	}
+	if !ticker.waitTicks(3, time.Second) {
+		t.Fatalf("%d ticks after 3 steps", ticker.count())
+	}
+	time.Sleep(3 * time.Second / engine.TIME_TICKS_PER_SEC)
+	if ticker.count() != 3 || clock.Status() != "paused" {
+		t.Fatalf("%d ticks while paused, status %s", ticker.count(), clock.Status())
+	}
+	if elapsed := clock.Elapsed(); elapsed != 3*time.Second/engine.TIME_TICKS_PER_SEC {
+		t.Fatalf("%s of game time after 3 steps", elapsed)
+	}
+
+	if err := clock.SetSpeed(3); err == nil {
+		t.Fatalf("speed 3x is accepted")
+	}
+	clock.SetSpeed(8)
+	clock.SetPaused(false)
+	// 8x is 80 ticks per second, normal speed would need two seconds
+	if !ticker.waitTicks(3+2*engine.TIME_TICKS_PER_SEC, time.Second) {
+		t.Fatalf("%d ticks at 8x speed", ticker.count())
+	}
+	if clock.Status() != "8x" {
+		t.Errorf("status is %s", clock.Status())
+	}
+}
+
+func lastPace(r *checkRender) string {
+	var pace string
+	for _, m := range r.messages {
+		if m.Level == netproto.MESSAGE_LEVEL_SPEED {
+			pace = m.Content
+		}
+	}
+	return pace
+}
+
+func TestPaceVotes(t *testing.T) {
+	// alice and bob have squads, carol is a spectator
+	d, renders := newChatDispatcher("classic")
+	alice, bob, carol := d.players[0].Id, d.players[1].Id, d.players[2].Id
+
+	d.handleChat(netproto.ChatLine{From: carol, Text: "/pause"})
+	d.handleChat(netproto.ChatLine{From: alice, Text: "/pause"})
+	if d.time.Paused() {
+		t.Fatalf("game is paused by half of players")
+	}
+	d.handleChat(netproto.ChatLine{From: bob, Text: "/yes"})
+	if !d.time.Paused() || lastPace(renders[2]) != "paused" {
+		t.Fatalf("vote did not pause the game, status %q", lastPace(renders[2]))
+	}
+
+	// relative speed is fixed when vote starts
+	d.handleChat(netproto.ChatLine{From: alice, Text: "/speed faster"})
+	d.handleChat(netproto.ChatLine{From: bob, Text: "/speed 2x"})
+	if d.time.Speed() != 2 {
+		t.Fatalf("speed is %v after vote for 2x", d.time.Speed())
+	}
+
+	// stale vote is not finished by late agreement
+	d.castVote(&d.players[0], "resume", time.Now().Add(-2*VOTE_TIMEOUT))
+	d.handleChat(netproto.ChatLine{From: bob, Text: "/resume"})
+	if !d.time.Paused() {
+		t.Fatalf("game resumed by expired vote")
+	}
+
+	// lone player does not need to wait for anybody
+	d, renders = newChatDispatcher("classic")
+	d.players[1].Orders = nil
+	d.handleChat(netproto.ChatLine{From: d.players[0].Id, Text: "/speed 0.25"})
+	if lastPace(renders[1]) != "0.25x" {
+		t.Errorf("single player vote gave status %q",
+			lastPace(renders[1]))
+	}
+}
+
+func TestPaceNewRound(t *testing.T) {
+	ruleset := &rules.Ruleset{}
+	ruleset.AddRules("single")
+	d := NewDispatcher(ruleset, nil)
+	d.time.SetPaused(true)
+	d.time.SetSpeed(8)
+	go d.Run()
+	defer d.Stop()
+	render := &checkRender{}
+	d.AttachPlayer(render, "alice")
+
+	var pace string
+	deadline := time.Now().Add(5 * time.Second)
+	for pace != "1x" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		d.Do(func() { pace = lastPace(render) })
+	}
+	if pace != "1x" {
+		t.Errorf("new round pace is %q, want 1x", pace)
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+// Profile holds persistent stats of named player
+type Profile struct {
+	Name         string
+	Rounds       int
+	Wins         map[string]int // per rule
+	ZedsKilled   int
+	DamselsSaved int
+	BestSurvival map[string]int // per rule, in seconds
+}
+
+func (p *Profile) TotalWins() int {
+	var total int
+	for _, wins := range p.Wins {
+		total += wins
+	}
+	return total
+}
+
+func (p *Profile) BestSurvivalTime() int {
+	var best int
+	for _, secs := range p.BestSurvival {
+		if secs > best {
+			best = secs
+		}
+	}
+	return best
+}
+
+// RoundResult is what player have achieved in a single round
+type RoundResult struct {
+	Name     string
+	Rule     string
+	Won      bool
+	Stats    netproto.PlayerStats
+	Survived time.Duration
+}
+
+// ProfileStore keeps profiles in json file. It is shared by dispatchers of all rooms
+type ProfileStore struct {
+	path     string
+	lock     sync.Mutex
+	Profiles map[string]*Profile
+}
+
+func OpenProfileStore(path string) (*ProfileStore, error) {
+	ps := &ProfileStore{path: path, Profiles: make(map[string]*Profile)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ps, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, &ps.Profiles); err != nil {
+		return nil, fmt.Errorf("profiles: cannot parse '%s': %s", path, err)
+	}
+	return ps, nil
+}
+
+func (ps *ProfileStore) profile(name string) *Profile {
+	p, ok := ps.Profiles[name]
+	if !ok {
+		p = &Profile{Name: name}
+		ps.Profiles[name] = p
+	}
+	if p.Wins == nil {
+		p.Wins = make(map[string]int)
+	}
+	if p.BestSurvival == nil {
+		p.BestSurvival = make(map[string]int)
+	}
+	return p
+}
+
+func (ps *ProfileStore) Record(r RoundResult) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	p := ps.profile(r.Name)
+	p.Rounds++
+	if r.Won {
+		p.Wins[r.Rule]++
+	}
+	p.ZedsKilled += r.Stats.ZedsKilled
+	p.DamselsSaved += r.Stats.DamselsSaved
+
+	secs := int(r.Survived / time.Second)
+	if secs > p.BestSurvival[r.Rule] {
+		p.BestSurvival[r.Rule] = secs
+	}
+}
+
+// Save atomically writes store back to the file
+func (ps *ProfileStore) Save() error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	data, err := json.MarshalIndent(ps.Profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := ps.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ps.path)
+}
+
+func (ps *ProfileStore) Leaderboard() *netproto.Leaderboard {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	lb := &netproto.Leaderboard{}
+	for _, p := range ps.Profiles {
+		// profile keeps changing after lock is released, hand out a copy
+		wins := make(map[string]int, len(p.Wins))
+		for rule, count := range p.Wins {
+			wins[rule] = count
+		}
+		lb.Entries = append(lb.Entries, netproto.LeaderboardEntry{Name: p.Name, Wins: p.TotalWins(), WinsByRule: wins,
+			ZedsKilled: p.ZedsKilled, BestSurvival: p.BestSurvivalTime()})
+	}
+	sort.Sort(lb)
+	return lb
+}
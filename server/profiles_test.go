@@ -0,0 +1,38 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+func TestRecordSurvival(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lgo-profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	profiles, err := OpenProfileStore(filepath.Join(dir, "profiles.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(&rules.Ruleset{}, profiles)
+	d.results = map[int]*RoundResult{
+		0: {Name: "bob", Rule: "check", Survived: 20 * time.Second},
+		1: {Name: "alice", Rule: "check"},
+	}
+	// survivors get game time of game over, not of countdown end
+	d.recordResults(90 * time.Second)
+
+	if secs := profiles.Profiles["bob"].BestSurvival["check"]; secs != 20 {
+		t.Errorf("exterminated player survived %ds, want 20s", secs)
+	}
+	if secs := profiles.Profiles["alice"].BestSurvival["check"]; secs != 90 {
+		t.Errorf("survivor survived %ds, want 90s", secs)
+	}
+}
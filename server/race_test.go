@@ -0,0 +1,73 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+type snapshotReader struct {
+	netproto.NopRender
+	snaps  chan *engine.Snapshot
+	orders chan chan engine.Order
+}
+
+func (r *snapshotReader) HandleUpdate(s *engine.Snapshot) {
+	select {
+	case r.snaps <- s:
+	default:
+	}
+}
+
+func (r *snapshotReader) AssignSquad(Id int, Orders chan engine.Order) {
+	r.orders <- Orders
+}
+
+// TestSnapshotRace lets render read snapshots while squad moves and shoots, run it with
+// go test -race to see if snapshots share anything with simulation
+func TestSnapshotRace(t *testing.T) {
+	ruleset := &rules.Ruleset{}
+	ruleset.AddRules("single")
+	d := NewDispatcher(ruleset, nil)
+	go d.Run()
+	defer d.Stop()
+	r := &snapshotReader{snaps: make(chan *engine.Snapshot, 1), orders: make(chan chan engine.Order, 1)}
+	d.AttachPlayer(r, "alice")
+
+	var orders chan engine.Order
+	select {
+	case orders = <-r.orders:
+	case <-time.After(5 * time.Second):
+		t.Fatal("squad is not assigned")
+	}
+	orders <- engine.Order{Order: engine.ORDER_FIRE, Coord: geom.CellCoord{}}
+	orders <- engine.Order{Order: engine.ORDER_MOVE, Coord: geom.CellCoord{X: 64, Y: 64}}
+
+	var morale float32
+	for i := 0; i < 2*engine.TIME_TICKS_PER_SEC; i++ {
+		var s *engine.Snapshot
+		select {
+		case s = <-r.snaps:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no snapshot after %d", i)
+		}
+		f := s.Field()
+		for _, up := range f.Units {
+			if sol, ok := up.Unit.(*engine.Soldier); ok {
+				sol.IsPanicking()
+			}
+		}
+		for _, squad := range s.Squads {
+			for _, m := range squad.Morale {
+				morale += m
+			}
+		}
+	}
+	if morale <= 0 {
+		t.Error("render saw no squad morale")
+	}
+}
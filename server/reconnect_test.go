@@ -0,0 +1,109 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+// checkRender records what dispatcher tells to the player
+type checkRender struct {
+	netproto.NopRender
+	assigned  int
+	orders    chan engine.Order
+	spectated bool
+	messages  []netproto.Message
+}
+
+func (r *checkRender) HandleMessage(lvl int, msg string) {
+	r.messages = append(r.messages, netproto.Message{Level: lvl, Content: msg})
+}
+
+func (r *checkRender) AssignSquad(Id int, Orders chan engine.Order) {
+	r.assigned, r.orders = Id, Orders
+}
+
+func (r *checkRender) Spectate() {
+	r.spectated = true
+}
+
+// newCheckDispatcher returns dispatcher in the middle of the round with one remote player
+// owning a squad
+func newCheckDispatcher() (*Dispatcher, *checkRender, int) {
+	ruleset := &rules.Ruleset{}
+	ruleset.AddRules("classic")
+	d := NewDispatcher(ruleset, nil)
+	d.setRoundState(engine.GAME_RUNNING)
+
+	render := &checkRender{}
+	Pid := d.attachCheckPlayer(render, "alice", "token")
+	d.players[0].Orders = make(chan engine.Order, engine.SQUAD_ORDER_QUEUE_LEN)
+	return d, render, Pid
+}
+
+func (d *Dispatcher) attachCheckPlayer(r netproto.Render, name, token string) int {
+	req := PlayerReq{Player{render: r, Name: name, Token: token}, DISP_ATTACH, -1,
+		make(chan int, 1)}
+	d.handlePlayerReq(req)
+	return <-req.resp
+}
+
+func (d *Dispatcher) detachCheckPlayer(Id int, r netproto.Render) {
+	req := PlayerReq{Player{render: r}, DISP_DETACH, Id, make(chan int, 1)}
+	d.handlePlayerReq(req)
+	<-req.resp
+}
+
+func TestSessionResume(t *testing.T) {
+	d, old, Pid := newCheckDispatcher()
+	orders := d.players[0].Orders
+
+	d.detachCheckPlayer(Pid, old)
+	if len(d.players) != 1 || d.players[0].lostAt.IsZero() {
+		t.Fatalf("player is not kept after connection loss")
+	}
+	if o := <-orders; o.Order != engine.ORDER_HOLD {
+		t.Fatalf("squad got order %d, want hold", o.Order)
+	}
+
+	render := &checkRender{}
+	if got := d.attachCheckPlayer(render, "alice", "token"); got != Pid {
+		t.Fatalf("reconnected as player %d, want %d", got, Pid)
+	}
+	if render.assigned != Pid || render.orders != orders {
+		t.Fatalf("squad is not given back to reconnected player")
+	}
+
+	// server notices old connection only now, it must not kick resumed player
+	d.detachCheckPlayer(Pid, old)
+	if len(d.players) != 1 || !d.players[0].lostAt.IsZero() ||
+		d.players[0].render != render {
+		t.Error("stale connection detached resumed player")
+	}
+}
+
+func TestSessionExpire(t *testing.T) {
+	d, render, Pid := newCheckDispatcher()
+	orders := d.players[0].Orders
+
+	d.detachCheckPlayer(Pid, render)
+	<-orders
+	d.reapLost(DISP_RECONNECT_GRACE)
+	if len(d.players) != 1 {
+		t.Fatalf("player is dropped before grace period ends")
+	}
+	d.reapLost(0)
+	if len(d.players) != 0 {
+		t.Fatalf("lost player is not dropped after grace period")
+	}
+	if o := <-orders; o.Order != engine.ORDER_SUICIDE {
+		t.Fatalf("squad got order %d, want suicide", o.Order)
+	}
+
+	// token is forgotten, reconnect is a new player
+	if got := d.attachCheckPlayer(&checkRender{}, "alice", "token"); got == Pid {
+		t.Fatalf("expired session is resumed")
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mechmind/life-goes-on/logging"
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+const (
+	// room of the server's own dispatcher, it is never reaped
+	ROOM_DEFAULT = "main"
+	ROOM_MAX     = 8
+	// empty room waits for lost players to reconnect before it is closed
+	ROOM_IDLE_TIMEOUT  = 2 * DISP_RECONNECT_GRACE
+	ROOM_REAP_INTERVAL = 5 * time.Second
+)
+
+// Room is a match with its own dispatcher
+type Room struct {
+	Name       string
+	dispatcher *Dispatcher
+
+	// guarded by RoomManager lock
+	clients    int
+	emptySince time.Time
+}
+
+// RoomManager keeps rooms of the server. Rooms are created by clients on demand and closed
+// when nobody plays in them for a while
+type RoomManager struct {
+	profiles *ProfileStore
+	maxRooms int
+
+	lock  sync.Mutex
+	rooms map[string]*Room
+}
+
+var roomsLog = logging.NewLogger("rooms")
+
+// NewRoomManager makes manager with dispatcher as default room, other rooms play single rule
+// chosen by their creator. Dispatcher must not be running yet
+func NewRoomManager(main *Dispatcher, profiles *ProfileStore) *RoomManager {
+	main.setRoom(ROOM_DEFAULT)
+	return &RoomManager{profiles: profiles, maxRooms: ROOM_MAX,
+		rooms: map[string]*Room{ROOM_DEFAULT: &Room{Name: ROOM_DEFAULT, dispatcher: main}}}
+}
+
+// Join takes place in room with given name, creating it with rule if it does not exist.
+// Empty name means default room. Every Join must be paired with Leave
+func (rm *RoomManager) Join(name, rule string) (*Room, error) {
+	if name == "" {
+		name = ROOM_DEFAULT
+	}
+
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	room, ok := rm.rooms[name]
+	if !ok {
+		var err error
+		if room, err = rm.createRoom(name, rule); err != nil {
+			return nil, err
+		}
+	}
+	room.clients++
+	return room, nil
+}
+
+// createRoom must be called with lock held
+func (rm *RoomManager) createRoom(name, rule string) (*Room, error) {
+	if rule == "" {
+		return nil, fmt.Errorf("no room named '%s'", name)
+	}
+	if len(rm.rooms) >= rm.maxRooms {
+		return nil, fmt.Errorf("too many rooms, %d are open", len(rm.rooms))
+	}
+
+	var ruleset = &rules.Ruleset{}
+	if err := ruleset.AddRules(rule); err != nil {
+		return nil, fmt.Errorf("cannot create room '%s': %s", name, err)
+	}
+
+	room := &Room{Name: name, dispatcher: NewDispatcher(ruleset, rm.profiles)}
+	room.dispatcher.setRoom(name)
+	rm.rooms[name] = room
+	go room.dispatcher.Run()
+	roomsLog.With("room", name).Infof("created room with rule '%s'", rule)
+	return room, nil
+}
+
+func (rm *RoomManager) Leave(room *Room) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	room.clients--
+	if room.clients == 0 {
+		room.emptySince = time.Now()
+	}
+}
+
+// List returns rooms sorted by name
+func (rm *RoomManager) List() []netproto.RoomInfo {
+	var list []netproto.RoomInfo
+	for _, room := range rm.Rooms() {
+		list = append(list, netproto.RoomInfo{Name: room.Name, Lobby: room.dispatcher.Lobby()})
+	}
+	return list
+}
+
+// Room returns room with given name or nil, empty name means default room
+func (rm *RoomManager) Room(name string) *Room {
+	if name == "" {
+		name = ROOM_DEFAULT
+	}
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	return rm.rooms[name]
+}
+
+// Rooms returns all rooms sorted by name
+func (rm *RoomManager) Rooms() []*Room {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	var rooms []*Room
+	for _, room := range rm.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	return rooms
+}
+
+func (rm *RoomManager) Run() {
+	for range time.Tick(ROOM_REAP_INTERVAL) {
+		rm.reap(ROOM_IDLE_TIMEOUT)
+	}
+}
+
+// reap closes rooms which stay empty longer than idle
+func (rm *RoomManager) reap(idle time.Duration) {
+	rm.lock.Lock()
+	defer rm.lock.Unlock()
+	for name, room := range rm.rooms {
+		if name == ROOM_DEFAULT || room.clients > 0 || time.Since(room.emptySince) < idle {
+			continue
+		}
+		roomsLog.With("room", name).Infof("room is empty, closing")
+		delete(rm.rooms, name)
+		room.dispatcher.Stop()
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mechmind/life-goes-on/netproto"
+	"github.com/mechmind/life-goes-on/rules"
+)
+
+func newCheckRooms() *RoomManager {
+	ruleset := &rules.Ruleset{}
+	ruleset.AddRules("classic")
+	return NewRoomManager(NewDispatcher(ruleset, nil), nil)
+}
+
+func TestRoomsJoin(t *testing.T) {
+	rm := newCheckRooms()
+	defer rm.reap(0)
+	rm.maxRooms = 2
+
+	if _, err := rm.Join("zoo", ""); err == nil {
+		t.Fatalf("joined room which does not exist")
+	}
+	if _, err := rm.Join("zoo", "no-such-rule"); err == nil {
+		t.Fatalf("created room with unknown rule")
+	}
+	zoo, err := rm.Join("zoo", "wild-west")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm.Leave(zoo)
+	again, err := rm.Join("zoo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rm.Leave(again)
+	if again != zoo {
+		t.Fatalf("second player got another room")
+	}
+	if _, err := rm.Join("park", "classic"); err == nil {
+		t.Fatalf("created room over the limit")
+	}
+
+	list := rm.List()
+	if len(list) != 2 || list[0].Name != ROOM_DEFAULT || list[1].Name != "zoo" ||
+		list[1].Lobby.Rule != "wild-west" {
+		t.Errorf("room list is %v", list)
+	}
+}
+
+func TestRoomsReap(t *testing.T) {
+	rm := newCheckRooms()
+	zoo, err := rm.Join("zoo", "classic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, _ := rm.Join("", "")
+	rm.reap(0)
+	if len(rm.List()) != 2 {
+		t.Fatalf("room with players is closed")
+	}
+
+	rm.Leave(zoo)
+	rm.Leave(main)
+	rm.reap(time.Hour)
+	if len(rm.List()) != 2 {
+		t.Fatalf("room is closed before idle timeout")
+	}
+	rm.reap(0)
+	list := rm.List()
+	if len(list) != 1 || list[0].Name != ROOM_DEFAULT {
+		t.Fatalf("rooms after reaping: %v", list)
+	}
+	if !zoo.dispatcher.stopped() {
+		t.Error("dispatcher of closed room is not stopped")
+	}
+}
+
+func TestDispatcherStop(t *testing.T) {
+	ruleset := &rules.Ruleset{}
+	ruleset.AddRules("classic")
+	d := NewDispatcher(ruleset, nil)
+	done := make(chan struct{})
+	go func() {
+		d.Run()
+		close(done)
+	}()
+
+	d.Stop()
+	select {
+	case <-done:
+		return
+	case <-time.After(5 * time.Second):
+		t.Fatalf("dispatcher is still running")
+	}
+}
+
+func TestRoomsHandshake(t *testing.T) {
+	s := newCheckServer()
+	defer s.rooms.reap(0)
+
+	_, err := checkHandshake(s, &netproto.Hello{Name: "alice", Room: "zoo"})
+	if r, ok := err.(*netproto.Reject); !ok || r.Code != netproto.REJECT_ROOM {
+		t.Fatalf("join to missing room got %v, want room rejection", err)
+	}
+	info, err := checkHandshake(s, &netproto.Hello{Name: "alice", Room: "zoo", Rule: "single"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Room != "zoo" || info.Lobby.Rule != "single" {
+		t.Fatalf("created room welcome is %s", info)
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		s.handshake(server, "10.0.0.1")
+	}()
+	msg, err := netproto.SendHello(client, &netproto.Hello{List: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, ok := msg.(*netproto.RoomList)
+	if !ok || len(list.Rooms) != 2 || list.Rooms[1].Name != "zoo" {
+		t.Errorf("got %+v, want room list", msg)
+	}
+}
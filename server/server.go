@@ -0,0 +1,236 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mechmind/life-goes-on/logging"
+	"github.com/mechmind/life-goes-on/metrics"
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+var serverLog = logging.NewLogger("server")
+
+var metricClients = metrics.NewGauge("lgo_clients_connected",
+	"Network clients connected to the server.")
+
+const (
+	SERVER_MAX_CLIENTS = 16
+	SERVER_TOKEN_LEN   = 16
+	// client which can not say hello in time is not worth waiting
+	SERVER_HANDSHAKE_TIMEOUT = 10 * time.Second
+)
+
+type Server struct {
+	rooms      *RoomManager
+	listener   *net.TCPListener
+	name       string
+	maxClients int
+
+	// guards clients and bans, handshakes run concurrently
+	lock    sync.Mutex
+	clients int
+	bans    map[string]bool
+}
+
+func CreateServer(rooms *RoomManager, straddr, name string) (*Server, error) {
+	addr, err := net.ResolveTCPAddr("tcp4", straddr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := newServer(rooms, name)
+	server.listener, err = net.ListenTCP("tcp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+func newServer(rooms *RoomManager, name string) *Server {
+	return &Server{rooms: rooms, name: name, maxClients: SERVER_MAX_CLIENTS,
+		bans: make(map[string]bool)}
+}
+
+// Ban denies access to player name, host address or session token
+func (s *Server) Ban(who string) {
+	if who == "" {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.bans[who] = true
+}
+
+func (s *Server) isBanned(name, host, token string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.bans[name] || s.bans[host] || s.bans[token]
+}
+
+// Clients returns number of connected clients
+func (s *Server) Clients() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.clients
+}
+
+// takeSlot reserves place for new client, false if server is full
+func (s *Server) takeSlot() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.clients >= s.maxClients {
+		return false
+	}
+	s.clients++
+	metricClients.Add(1)
+	return true
+}
+
+func (s *Server) releaseSlot() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.clients--
+	metricClients.Add(-1)
+}
+
+func (s *Server) Serve() {
+	serverLog.Infof("accepting connections")
+	for {
+		conn, err := s.listener.AcceptTCP()
+		if err != nil {
+			serverLog.Errorf("failed to accept connection: %s", err)
+		} else {
+			serverLog.With("addr", conn.RemoteAddr()).Infof("new connection")
+			go s.serveConn(conn)
+		}
+	}
+}
+
+func (s *Server) serveConn(conn *net.TCPConn) {
+	defer conn.Close()
+	log := serverLog.With("addr", conn.RemoteAddr())
+
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	conn.SetDeadline(time.Now().Add(SERVER_HANDSHAKE_TIMEOUT))
+	hello, room, err := s.handshake(conn, host)
+	if err != nil {
+		log.Warnf("handshake failed: %s", err)
+		return
+	}
+	if room == nil {
+		log.Infof("sent room list")
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	defer s.releaseSlot()
+	defer s.rooms.Leave(room)
+
+	render := netproto.CreateRemoteRender(conn)
+	pid := room.dispatcher.AttachSession(render, hello.Name, hello.Token)
+	log = log.With("room", room.Name).With("player", pid)
+	log.Infof("connection is bound to player %s", hello.Name)
+	err = render.Run()
+	if err != nil {
+		log.Warnf("remote render error: %s", err)
+	}
+
+	room.dispatcher.DetachSession(pid, render)
+	log.Infof("connection have ended")
+}
+
+// handshake reads client hello, checks protocol version and that client may join.
+// Client gets either welcome with server and lobby info or rejection with the reason.
+// Returned hello has sanitized name and session token to attach with, returned room is
+// joined and must be left. Client which only asks for room list gets it and nil room
+func (s *Server) handshake(conn io.ReadWriter, host string) (*netproto.Hello, *Room, error) {
+	hello, err := readHello(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := netproto.CheckVersion(hello); err != nil {
+		return nil, nil, reject(conn, netproto.REJECT_VERSION, err.Error())
+	}
+
+	hello.Name = netproto.SanitizeName(hello.Name)
+	if s.isBanned(hello.Name, host, hello.Token) {
+		return nil, nil, reject(conn, netproto.REJECT_BANNED, "you are banned on this server")
+	}
+	if hello.List {
+		return hello, nil, netproto.WriteFrame(conn, &netproto.RoomList{Rooms: s.rooms.List()})
+	}
+	if !s.takeSlot() {
+		return nil, nil, reject(conn, netproto.REJECT_FULL,
+			fmt.Sprintf("server is full, %d players connected", s.maxClients))
+	}
+
+	room, err := s.rooms.Join(netproto.SanitizeName(hello.Room), hello.Rule)
+	if err != nil {
+		s.releaseSlot()
+		return nil, nil, reject(conn, netproto.REJECT_ROOM, err.Error())
+	}
+
+	// token resumes only a session this room knows, anything else gets a fresh one
+	if hello.Token == "" || !room.dispatcher.HasSession(hello.Token) {
+		hello.Token, err = newSessionToken()
+	}
+	if err == nil {
+		err = netproto.WriteFrame(conn, &netproto.Welcome{Version: netproto.PROTO_VERSION, Server: s.name, Room: room.Name, Lobby: room.dispatcher.Lobby(),
+			Token: hello.Token})
+	}
+	if err != nil {
+		s.rooms.Leave(room)
+		s.releaseSlot()
+		return nil, nil, err
+	}
+	return hello, room, nil
+}
+
+func newSessionToken() (string, error) {
+	var token [SERVER_TOKEN_LEN]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token[:]), nil
+}
+
+// readHello reads client header and hello
+func readHello(conn io.Reader) (*netproto.Hello, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != 'L' || header[1] != 'G' || header[2] != 'O' {
+		return nil, errors.New("invalid header")
+	}
+	if header[3] != netproto.PROTO_FRAMED {
+		// old clients can not read frames, just drop them
+		return nil, fmt.Errorf("client speaks legacy protocol version %d", header[3])
+	}
+
+	msg, err := netproto.ReadFrameLimit(conn, netproto.PROTO_MAX_HELLO_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	hello, ok := msg.(*netproto.Hello)
+	if !ok {
+		return nil, fmt.Errorf("expected hello, got message type %d", msg.MsgType())
+	}
+	return hello, nil
+}
+
+// reject sends rejection to client and returns it as error
+func reject(conn io.Writer, code int, reason string) error {
+	r := &netproto.Reject{Code: code, Reason: reason}
+	if err := netproto.WriteFrame(conn, r); err != nil {
+		return err
+	}
+	return r
+}
@@ -0,0 +1,74 @@
+//go:build !windows
+// +build !windows
+
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListenPrivate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "admin.sock")
+	listener, err := listenPrivate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("admin socket is created with mode %o", perm)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("%d files left next to admin socket", len(files))
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestAdminSocketInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, d := newCheckAdmin()
+	defer d.Stop()
+
+	path := filepath.Join(dir, "admin.sock")
+	if err := a.ServeUnix(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ServeUnix(path); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("live admin socket is taken over: %v", err)
+	}
+
+	// socket left by crashed server is reused
+	stale := filepath.Join(dir, "stale.sock")
+	listener, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if err := a.ServeUnix(stale); err != nil {
+		t.Errorf("stale admin socket is not replaced: %v", err)
+	}
+}
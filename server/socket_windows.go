@@ -0,0 +1,10 @@
+package server
+
+import (
+	"net"
+)
+
+// listenPrivate creates unix socket, windows checks access by directory acl only
+func listenPrivate(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+const (
+	CHECK_TIMEOUT = 50 * time.Millisecond
+)
+
+// fakeConn is net.Conn which never has anything to read. Writes take writeDelay each,
+// and block until deadline if stalled
+type fakeConn struct {
+	lock                        sync.Mutex
+	readDeadline, writeDeadline time.Time
+	writeDelay                  time.Duration
+	stalled                     bool
+	written                     bytes.Buffer
+	closed                      chan struct{}
+}
+
+type fakeTimeout struct{}
+
+func (fakeTimeout) Error() string   { return "fake i/o timeout" }
+func (fakeTimeout) Timeout() bool   { return true }
+func (fakeTimeout) Temporary() bool { return true }
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{closed: make(chan struct{})}
+}
+
+// waitDeadline blocks until deadline passes or connection is closed
+func (c *fakeConn) waitDeadline(deadline *time.Time) error {
+	for {
+		c.lock.Lock()
+		expired := !deadline.IsZero() && time.Now().After(*deadline)
+		c.lock.Unlock()
+		if expired {
+			return fakeTimeout{}
+		}
+		select {
+		case <-c.closed:
+			return net.ErrClosed
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (c *fakeConn) Read(b []byte) (int, error) {
+	return 0, c.waitDeadline(&c.readDeadline)
+}
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	stalled, delay := c.stalled, c.writeDelay
+	c.lock.Unlock()
+	if stalled {
+		return 0, c.waitDeadline(&c.writeDeadline)
+	}
+	time.Sleep(delay)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.written.Write(b)
+}
+
+func (c *fakeConn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+
+func (c *fakeConn) LocalAddr() net.Addr  { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+func (c *fakeConn) RemoteAddr() net.Addr { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 2)} }
+
+func (c *fakeConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *fakeConn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+// runCheckRender starts remote render over conn with short timeouts
+func runCheckRender(conn net.Conn) (*netproto.RemoteRender, chan error) {
+	rr := netproto.CreateRemoteRender(conn)
+	rr.WriteTimeout = CHECK_TIMEOUT
+	rr.ReadTimeout = time.Hour
+	rr.PingInterval = time.Hour
+	errs := make(chan error, 1)
+	go func() {
+		errs <- rr.Run()
+	}()
+	return rr, errs
+}
+
+// feedRender pushes snapshots to render like dispatcher does and fails if any call blocks
+func feedRender(rr *netproto.RemoteRender, snap *engine.Snapshot, count int) error {
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		rr.HandleUpdate(snap)
+		rr.HandleGameState(engine.GameState{State: engine.GAME_RUNNING, Player: -1})
+		rr.HandleMessage(netproto.MESSAGE_LEVEL_INFO, "tick")
+		rr.AssignSquad(i, nil)
+		rr.Reset()
+		if time.Since(start) > CHECK_TIMEOUT/2 {
+			return fmt.Errorf("render blocked dispatcher for %s", time.Since(start))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+func TestStalledClient(t *testing.T) {
+	conn := newFakeConn()
+	conn.stalled = true
+	rr, errs := runCheckRender(conn)
+	defer conn.Close()
+
+	if err := feedRender(rr, engine.NewSnapshot(engine.NewField(16, 16, nil), 0), 20); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if _, ok := err.(fakeTimeout); !ok {
+			t.Fatalf("render failed with %v, want write timeout", err)
+		}
+	case <-time.After(CHECK_TIMEOUT * 10):
+		t.Fatalf("stalled client is not disconnected")
+	}
+
+	// dispatcher still may talk to disconnected render
+	done := make(chan struct{})
+	go func() {
+		rr.Reset()
+		rr.Spectate()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-time.After(CHECK_TIMEOUT):
+		t.Fatalf("disconnected render blocks dispatcher")
+	}
+}
+
+func TestSlowClient(t *testing.T) {
+	conn := newFakeConn()
+	conn.writeDelay = 5 * time.Millisecond
+	rr, errs := runCheckRender(conn)
+	defer conn.Close()
+
+	if err := feedRender(rr, engine.NewSnapshot(engine.NewField(16, 16, nil), 0), 50); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("slow client is disconnected: %v", err)
+	default:
+	}
+	if rr.Dropped() <= 0 {
+		t.Error("no frames dropped for slow client")
+	}
+}
+
+func TestDeadPeer(t *testing.T) {
+	conn := newFakeConn()
+	rr := netproto.CreateRemoteRender(conn)
+	rr.ReadTimeout = CHECK_TIMEOUT
+	defer conn.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- rr.Run()
+	}()
+	select {
+	case err := <-errs:
+		if err != (fakeTimeout{}) {
+			t.Fatalf("render failed with %v, want read timeout", err)
+		}
+	case <-time.After(CHECK_TIMEOUT * 10):
+		t.Fatalf("silent peer is not disconnected")
+	}
+}
+
+func TestPingLatency(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	rr := netproto.CreateRemoteRender(server)
+	rr.PingInterval = time.Millisecond
+	go rr.Run()
+	defer server.Close()
+
+	// answer the ping after a delay like remote game does
+	for {
+		msg, err := netproto.ReadFrame(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ping, ok := msg.(*netproto.Ping); ok {
+			time.Sleep(CHECK_TIMEOUT)
+			pong := netproto.Pong(*ping)
+			if err := netproto.WriteFrame(client, &pong); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+
+	deadline := time.Now().Add(CHECK_TIMEOUT * 10)
+	for rr.Latency() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	latency := rr.Latency()
+	if latency < CHECK_TIMEOUT || latency >= CHECK_TIMEOUT*10 {
+		t.Errorf("measured latency %s, want about %s", latency, CHECK_TIMEOUT)
+	}
+}
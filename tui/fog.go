@@ -0,0 +1,31 @@
+package tui
+
+import (
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+// fogMemory keeps terrain which player have seen at least once
+type fogMemory struct {
+	xsize int
+	seen  []bool
+}
+
+func (m *fogMemory) reset(f *engine.Field) {
+	if m.xsize != f.XSize || len(m.seen) != len(f.Cells) {
+		m.xsize = f.XSize
+		m.seen = make([]bool, len(f.Cells))
+		return
+	}
+	for idx := range m.seen {
+		m.seen[idx] = false
+	}
+}
+
+func (m *fogMemory) see(c geom.CellCoord) {
+	m.seen[c.Y*m.xsize+c.X] = true
+}
+
+func (m *fogMemory) wasSeen(c geom.CellCoord) bool {
+	return m.seen[c.Y*m.xsize+c.X]
+}
@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+const (
+	// units which moved further than that between snapshots are not interpolated
+	RENDER_MAX_LERP = 2
+	// longer gap between snapshots means game was paused
+	RENDER_MAX_INTERVAL = 4 * time.Second / engine.TIME_TICKS_PER_SEC
+)
+
+// interpolator keeps last two snapshots and makes frames with units placed between them,
+// so view moves smoothly while simulation ticks at its own pace. Frames lag one tick behind
+type interpolator struct {
+	prev, next *engine.Field
+	arrived    time.Time
+	interval   time.Duration
+	settled    bool // frame with alpha 1 is made
+}
+
+func (ip *interpolator) push(f *engine.Field, now time.Time) {
+	ip.interval = now.Sub(ip.arrived)
+	if ip.interval > RENDER_MAX_INTERVAL {
+		ip.interval = time.Second / engine.TIME_TICKS_PER_SEC
+	}
+	ip.prev, ip.next = ip.next, f
+	ip.arrived = now
+	ip.settled = false
+}
+
+// animating tells if there are frames to draw before next snapshot arrives
+func (ip *interpolator) animating() bool {
+	return ip.prev != nil && !ip.settled
+}
+
+func (ip *interpolator) frame(now time.Time) *engine.Field {
+	// visibility under fog is too costly to recompute every frame
+	if ip.prev == nil || ip.next.Fog || ip.interval <= 0 {
+		ip.settled = true
+		return ip.next
+	}
+	alpha := float32(now.Sub(ip.arrived)) / float32(ip.interval)
+	if alpha >= 1 {
+		ip.settled = true
+		return ip.next
+	}
+	return interpolateField(ip.prev, ip.next, alpha)
+}
+
+// interpolateField returns copy of next with units and grens moved back toward their places in
+// prev, alpha 0 is prev and 1 is next
+func interpolateField(prev, next *engine.Field, alpha float32) *engine.Field {
+	frame := *next
+	frame.Units = append([]engine.UnitPresence(nil), next.Units...)
+	for idx := range frame.Units {
+		if idx >= len(prev.Units) {
+			break
+		}
+		from, to := prev.Units[idx], &frame.Units[idx]
+		if from.Unit == nil || to.Unit == nil || engine.UnitKind(from.Unit) != engine.UnitKind(to.Unit) {
+			continue
+		}
+		to.Coord = lerpCoord(from.Coord, to.Coord, alpha)
+	}
+
+	frame.Grens = append([]engine.FlyingGren(nil), next.Grens...)
+	for idx := range frame.Grens {
+		gren := &frame.Grens[idx]
+		for _, old := range prev.Grens {
+			if old.To == gren.To && old.Booming == 0 {
+				gren.From = lerpCoord(old.From, gren.From, alpha)
+				break
+			}
+		}
+	}
+	return &frame
+}
+
+func lerpCoord(from, to geom.UnitCoord, alpha float32) geom.UnitCoord {
+	if from.Distance(to) > RENDER_MAX_LERP {
+		return to
+	}
+	return from.AddCoord(to.AddCoord(from.Mult(-1)).Mult(alpha))
+}
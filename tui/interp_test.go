@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+)
+
+// renderField is a snapshot as render sees it, with units of given kinds at given places
+func renderField(units ...engine.UnitView) *engine.Field {
+	s := &engine.Snapshot{XSize: 32, YSize: 32, Cells: make([]engine.Cell, 32*32), Units: units}
+	return s.Field()
+}
+
+func TestInterpolation(t *testing.T) {
+	var ip interpolator
+	start := time.Now()
+	tick := time.Second / engine.TIME_TICKS_PER_SEC
+	ip.push(renderField(engine.UnitView{Kind: engine.KIND_ZED, Coord: geom.UnitCoord{X: 10, Y: 10}},
+		engine.UnitView{Kind: engine.KIND_DAMSEL, Coord: geom.UnitCoord{X: 5, Y: 5}},
+		engine.UnitView{Kind: engine.KIND_SOLDIER, Coord: geom.UnitCoord{X: 20, Y: 20}}), start)
+	ip.push(renderField(engine.UnitView{Kind: engine.KIND_ZED, Coord: geom.UnitCoord{X: 11, Y: 10}},
+		engine.UnitView{Kind: engine.KIND_CORPSE, Dead: engine.KIND_DAMSEL, Coord: geom.UnitCoord{X: 6, Y: 5}},
+		engine.UnitView{Kind: engine.KIND_SOLDIER, Coord: geom.UnitCoord{X: 2, Y: 2}},
+		engine.UnitView{Kind: engine.KIND_ZED, Coord: geom.UnitCoord{X: 1, Y: 1}}), start.Add(tick))
+
+	frame := ip.frame(start.Add(tick + tick/2))
+	want := []geom.UnitCoord{
+		{X: 10.5, Y: 10}, // moving zed is half way
+		{X: 6, Y: 5},     // dead damsel is not the same unit anymore
+		{X: 2, Y: 2},     // teleported soldier jumps
+		{X: 1, Y: 1},     // new zed is where it is
+	}
+	for idx, coord := range want {
+		if frame.Units[idx].Coord != coord {
+			t.Fatalf("unit %d is at %s, want %s", idx, frame.Units[idx].Coord, coord)
+		}
+	}
+	if !ip.animating() || ip.next.Units[0].Coord != (geom.UnitCoord{X: 11, Y: 10}) {
+		t.Fatalf("frame changed snapshot or stopped animation")
+	}
+
+	frame = ip.frame(start.Add(3 * tick))
+	if frame != ip.next || ip.animating() {
+		t.Error("late frame is not the snapshot itself")
+	}
+}
+
+func TestNewestSnapshots(t *testing.T) {
+	lr := NewLocalRender(0)
+	// size tells snapshots apart
+	for size := 1; size <= 5; size++ {
+		lr.HandleUpdate(&engine.Snapshot{XSize: size})
+	}
+	var got []int
+	for len(lr.updates) > 0 {
+		got = append(got, (<-lr.updates).XSize)
+	}
+	if fmt.Sprint(got) != "[3 4 5]" {
+		t.Errorf("render got snapshots %v, want [3 4 5]", got)
+	}
+}
@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+const (
+	MSGLOG_LEN = 100
+)
+
+// messageLog keeps recent messages for the local render. Fresh messages are shown for a
+// while, older ones are visible when log is opened or scrolled back
+type messageLog struct {
+	lines  []netproto.Message
+	scroll int // lines scrolled back from the newest one
+}
+
+func (l *messageLog) add(m netproto.Message) {
+	l.lines = append(l.lines, m)
+	if len(l.lines) > MSGLOG_LEN {
+		l.lines = l.lines[len(l.lines)-MSGLOG_LEN:]
+	}
+	l.scroll = 0
+}
+
+func (l *messageLog) tick() {
+	for idx := range l.lines {
+		if l.lines[idx].TTL > 0 {
+			l.lines[idx].TTL--
+		}
+	}
+}
+
+func (l *messageLog) scrollBy(n int) {
+	if len(l.lines) > 0 {
+		l.scroll = geom.Ibound(l.scroll+n, 0, len(l.lines))
+	}
+}
+
+func (l *messageLog) scrolled() bool {
+	return l.scroll > 0
+}
+
+// visible returns up to n lines, oldest first, ending at the scroll position. Closed log
+// shows only fresh lines
+func (l *messageLog) visible(n int, open bool) []netproto.Message {
+	end := len(l.lines) - l.scroll
+	start := geom.Imax(end-n, 0)
+	lines := l.lines[start:end]
+	if open || l.scrolled() {
+		return lines
+	}
+	for idx := range lines {
+		if lines[idx].TTL > 0 {
+			return lines[idx:]
+		}
+	}
+	return nil
+}
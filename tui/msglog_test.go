@@ -0,0 +1,36 @@
+package tui
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+func TestMessageLog(t *testing.T) {
+	var l messageLog
+	l.scrollBy(-1)
+	for i := 0; i < MSGLOG_LEN+10; i++ {
+		l.add(netproto.Message{Content: fmt.Sprint(i), TTL: 1})
+	}
+	if len(l.lines) != MSGLOG_LEN {
+		t.Fatalf("log keeps %d lines, want %d", len(l.lines), MSGLOG_LEN)
+	}
+	l.tick()
+	if got := l.visible(3, false); len(got) != 0 {
+		t.Fatalf("closed log shows %d stale lines", len(got))
+	}
+	l.scrollBy(2)
+	got := l.visible(3, false)
+	if len(got) != 3 || got[2].Content != fmt.Sprint(MSGLOG_LEN+7) {
+		t.Fatalf("scrolled log shows %v", got)
+	}
+	l.scrollBy(1000)
+	if got := l.visible(3, true); len(got) != 1 || got[0].Content != "10" {
+		t.Fatalf("log scrolled to the top shows %v", got)
+	}
+	l.add(netproto.Message{Content: "new"})
+	if l.scrolled() {
+		t.Error("new message does not reset scroll")
+	}
+}
@@ -1,5 +1,6 @@
+//go:build !windows
 // +build !windows
 
-package main
+package tui
 
 func prepareTerminal() {}
@@ -0,0 +1,880 @@
+// Package tui is termbox frontend of the game.
+package tui
+
+import (
+	"fmt"
+	"github.com/nsf/termbox-go"
+	"strings"
+	"time"
+
+	"github.com/mechmind/life-goes-on/engine"
+	"github.com/mechmind/life-goes-on/geom"
+	"github.com/mechmind/life-goes-on/logging"
+	"github.com/mechmind/life-goes-on/netproto"
+)
+
+const (
+	TUI_DEFAULT_BG = termbox.ColorBlack
+	TUI_DEFAULT_FG = termbox.ColorWhite
+
+	TUI_SOLDIER_CHAR       = '@'
+	TUI_SOLDIER_FG         = termbox.ColorRed | termbox.AttrBold
+	TUI_ANOTHER_SOLDIER_FG = termbox.ColorMagenta | termbox.AttrBold
+	TUI_PANIC_SOLDIER_FG   = termbox.ColorYellow | termbox.AttrBold
+
+	TUI_DAMSEL_CHAR = 'B'
+	TUI_DAMSEL_FG   = termbox.ColorYellow
+
+	TUI_FASTDAMSEL_CHAR = 'B'
+	TUI_FASTDAMSEL_FG   = termbox.ColorYellow | termbox.AttrBold
+
+	TUI_ZED_CHAR = 'Z'
+	TUI_ZED_FG   = termbox.ColorGreen
+
+	TUI_FASTZED_CHAR = 'Z'
+	TUI_FASTZED_FG   = termbox.ColorGreen | termbox.AttrBold
+
+	TUI_CORPSE_BG = termbox.ColorRed
+	TUI_CORPSE_FG = termbox.ColorBlack
+
+	TUI_WALL_CHAR      = '#'
+	TUI_FLAT_CHAR      = ' '
+	TUI_BUSH_CHAR      = '"'
+	TUI_BARR_CHAR      = 'X'
+	TUI_OFFSCREEN_CHAR = TUI_WALL_CHAR
+
+	// remembered but currently unseen terrain
+	TUI_FOG_FG        = termbox.ColorBlue
+	TUI_FOG_FLAT_CHAR = '.'
+	TUI_UNKNOWN_CHAR  = ' '
+
+	TUI_POS_STEP = 5
+
+	// squad HUD
+	TUI_MOVE_TARGET_CHAR = '+'
+	TUI_MOVE_TARGET_FG   = termbox.ColorGreen | termbox.AttrBold
+
+	TUI_GREN_TARGET_CHAR = '*'
+	TUI_GREN_TARGET_FG   = termbox.ColorRed | termbox.AttrBold
+
+	TUI_FLYING_GREN_TARGET_CHAR = '*'
+	TUI_FLYING_GREN_TARGET_FG   = termbox.ColorYellow
+
+	// FIXME(pathfind)
+	TUI_PATHFIND_OPEN_BG   = termbox.ColorCyan
+	TUI_PATHFIND_CLOSED_BG = termbox.ColorYellow
+	TUI_PATHFIND_PATH_BG   = termbox.ColorRed
+
+	TUI_CURSOR_MARGIN = 5
+
+	// status
+	TUI_STATUS_FIRE_FG   = termbox.ColorRed
+	TUI_STATUS_INFO_FG   = termbox.ColorWhite | termbox.AttrBold
+	TUI_STATUS_PAUSED_FG = termbox.ColorYellow | termbox.AttrBold
+
+	TUI_MORALE_GOOD_FG   = termbox.ColorGreen
+	TUI_MORALE_SHAKEN_FG = termbox.ColorYellow
+	TUI_MORALE_PANIC_FG  = termbox.ColorRed | termbox.AttrBold
+
+	// message log and chat
+	TUI_MSGLOG_LINES  = 5
+	TUI_CHAT_FG       = termbox.ColorCyan
+	TUI_CHAT_INPUT_FG = termbox.ColorCyan | termbox.AttrBold
+)
+
+var renderLog = logging.NewLogger("render")
+
+var boomingColors = [engine.SOL_GREN_TICK_CAP + 1]struct {
+	fg, bg termbox.Attribute
+	ch     rune
+}{
+	{},
+	{termbox.ColorWhite, termbox.ColorWhite, ' '},
+	{termbox.ColorYellow, termbox.ColorYellow, ' '},
+	{termbox.ColorRed, termbox.ColorDefault, '*'},
+}
+
+type LocalRender struct {
+	updates      chan *engine.Snapshot
+	Orders       chan engine.Order
+	messages     chan netproto.Message
+	squad        int
+	stateUpdates chan engine.GameState
+	assignments  chan netproto.Assignment
+
+	scoreboards  chan *netproto.Scoreboard
+	scoreboard   *netproto.Scoreboard
+	leaderboards chan *netproto.Leaderboard
+	leaderboard  *netproto.Leaderboard
+	showLeaders  bool
+	fog          fogMemory
+
+	msgLog    messageLog
+	showLog   bool
+	chatBinds chan netproto.ChatBinding
+	chat      netproto.ChatBinding
+	input     *chatInput
+	pace      string
+
+	events chan termbox.Event
+	reset  chan struct{}
+
+	fps    int
+	frames interpolator
+}
+
+// chatInput is a line player is typing
+type chatInput struct {
+	team bool
+	text []rune
+}
+
+// NewLocalRender makes render drawing fps frames per second, 0 to redraw only on updates
+func NewLocalRender(fps int) *LocalRender {
+	return &LocalRender{updates: make(chan *engine.Snapshot, 3), stateUpdates: make(chan engine.GameState, 3),
+		squad: -1, assignments: make(chan netproto.Assignment, 1), events: make(chan termbox.Event),
+		reset: make(chan struct{}, 1), messages: make(chan netproto.Message, netproto.CHAT_QUEUE),
+		scoreboards: make(chan *netproto.Scoreboard, 1), leaderboards: make(chan *netproto.Leaderboard, 1),
+		chatBinds: make(chan netproto.ChatBinding, 1), fps: fps}
+}
+
+// HandleUpdate keeps the newest snapshots, oldest one is dropped when render falls behind
+func (lr *LocalRender) HandleUpdate(s *engine.Snapshot) {
+	for {
+		select {
+		case lr.updates <- s:
+			return
+		default:
+		}
+		select {
+		case <-lr.updates:
+			netproto.MetricDropped.Inc("local", "snapshot")
+		default:
+		}
+	}
+}
+
+func (lr *LocalRender) HandleGameState(s engine.GameState) {
+	select {
+	case lr.stateUpdates <- s:
+	default:
+		netproto.MetricDropped.Inc("local", "game-state")
+	}
+}
+
+func (lr *LocalRender) HandleMessage(lvl int, msg string) {
+	select {
+	case lr.messages <- netproto.Message{Level: lvl, Content: msg, TTL: netproto.MESSAGE_TTL}:
+	default:
+		netproto.MetricDropped.Inc("local", "message")
+	}
+}
+
+func (lr *LocalRender) AssignSquad(Id int, Orders chan engine.Order) {
+	lr.assignments <- netproto.Assignment{Id: Id, Orders: Orders}
+}
+
+func (lr *LocalRender) HandleScoreboard(sb *netproto.Scoreboard) {
+	select {
+	case lr.scoreboards <- sb:
+	default:
+		netproto.MetricDropped.Inc("local", "scoreboard")
+	}
+}
+
+func (lr *LocalRender) HandleLeaderboard(lb *netproto.Leaderboard) {
+	select {
+	case lr.leaderboards <- lb:
+	default:
+		netproto.MetricDropped.Inc("local", "leaderboard")
+	}
+}
+
+func (lr *LocalRender) Spectate() {
+	lr.assignments <- netproto.Assignment{Id: -1, Orders: nil}
+}
+
+func (lr *LocalRender) Reset() {
+	lr.reset <- struct{}{}
+}
+
+func (lr *LocalRender) AttachChat(Pid int, chat chan netproto.ChatLine) {
+	lr.chatBinds <- netproto.ChatBinding{Pid: Pid, Chat: chat}
+}
+
+// handleInput handles keys while player types chat line
+func (lr *LocalRender) handleInput(ev termbox.Event) {
+	switch {
+	case ev.Key == termbox.KeyEnter:
+		if len(lr.input.text) > 0 && lr.chat.Chat != nil {
+			line := netproto.ChatLine{From: lr.chat.Pid, Team: lr.input.team, Text: string(lr.input.text)}
+			select {
+			case lr.chat.Chat <- line:
+			default:
+			}
+		}
+		lr.input = nil
+	case ev.Key == termbox.KeyEsc:
+		lr.input = nil
+	case ev.Key == termbox.KeyBackspace || ev.Key == termbox.KeyBackspace2:
+		if len(lr.input.text) > 0 {
+			lr.input.text = lr.input.text[:len(lr.input.text)-1]
+		}
+	case len(lr.input.text) >= netproto.CHAT_MAX_LEN:
+	case ev.Key == termbox.KeySpace:
+		lr.input.text = append(lr.input.text, ' ')
+	case ev.Ch != 0:
+		lr.input.text = append(lr.input.text, ev.Ch)
+	}
+}
+
+// sendCommand sends chat command to dispatcher
+func (lr *LocalRender) sendCommand(text string) {
+	if lr.chat.Chat == nil {
+		return
+	}
+	select {
+	case lr.chat.Chat <- netproto.ChatLine{From: lr.chat.Pid, Team: false, Text: text}:
+	default:
+	}
+}
+
+func (lr *LocalRender) Init() {
+	go pollEvents(lr.events)
+
+	prepareTerminal()
+
+	termbox.Init()
+	termbox.SetInputMode(termbox.InputEsc | termbox.InputMouse)
+
+}
+
+func (lr *LocalRender) Run() {
+	defer termbox.Close()
+
+	renderLog.Debugf("starting up")
+
+	var currentPos geom.CellCoord
+	var doSquadFocus bool
+
+	// FIXME: hardcoded squad values
+	var sv = squadView{FireState: engine.ORDER_FIRE}
+
+	// recieve field view first
+	renderLog.Debugf("recieving very first field update")
+	var field = (<-lr.updates).Field()
+	lr.frames.push(field, time.Now())
+
+	var gameState = engine.GameState{State: engine.GAME_WAIT}
+	var rulesMsg string
+
+	// frames are drawn between updates while units are moving
+	var frameTicks <-chan time.Time
+	if lr.fps > 0 {
+		frameTicker := time.NewTicker(time.Second / time.Duration(lr.fps))
+		defer frameTicker.Stop()
+		frameTicks = frameTicker.C
+	}
+	draw := func() {
+		lr.drawField(lr.frames.frame(time.Now()), currentPos, sv, gameState, rulesMsg)
+	}
+
+	draw()
+	renderLog.Debugf("starting main loop")
+	for {
+		select {
+		case newMsg := <-lr.messages:
+			if newMsg.Level == netproto.MESSAGE_LEVEL_RULE {
+				rulesMsg = newMsg.Content
+			} else if newMsg.Level == netproto.MESSAGE_LEVEL_SPEED {
+				lr.pace = newMsg.Content
+			} else {
+				lr.msgLog.add(newMsg)
+			}
+			draw()
+		case newGameState := <-lr.stateUpdates:
+			if newGameState.State == engine.GAME_OVER {
+				gameState.State |= newGameState.State
+			} else {
+				gameState = newGameState
+			}
+			renderLog.Debugf("game state changed to %v", gameState)
+		case Assignment := <-lr.assignments:
+			lr.squad = Assignment.Id
+			lr.Orders = Assignment.Orders
+			doSquadFocus = true
+			renderLog.Debugf("got new assignment: %v", Assignment)
+		case binding := <-lr.chatBinds:
+			lr.chat = binding
+		case sb := <-lr.scoreboards:
+			lr.scoreboard = sb
+			renderLog.Debugf("got scoreboard")
+			draw()
+		case lb := <-lr.leaderboards:
+			lr.leaderboard = lb
+			renderLog.Debugf("got leaderboard")
+			draw()
+		case <-lr.reset:
+			sv = squadView{FireState: engine.ORDER_FIRE}
+			lr.scoreboard = nil
+			lr.fog.reset(field)
+			renderLog.Debugf("resetting state")
+		case <-frameTicks:
+			if lr.frames.animating() {
+				draw()
+			}
+			// messages fade with updates, not frames
+			continue
+		case snap := <-lr.updates:
+			field = snap.Field()
+			lr.frames.push(field, time.Now())
+
+			// update rendering state
+			// handle grens
+			for _, gren := range field.Grens {
+				if gren.From.Cell() == sv.GrenTo {
+					sv.GrenTo = geom.CellCoord{X: 0, Y: 0}
+					break
+				}
+			}
+			if doSquadFocus && lr.squad >= 0 {
+				// center view on our squad
+				for _, a := range field.Agents {
+					if squad, ok := a.(*engine.Squad); ok {
+						if squad.Pid == lr.squad {
+							if len(squad.Units) > 0 {
+								centerOn, _ := field.UnitByID(squad.Units[0].Id)
+								size := tb2cell()
+								currentPos = centerOn.Cell().Add(-size.X/2, -size.Y/2)
+								doSquadFocus = false
+							}
+							break
+						}
+					}
+				}
+			}
+			draw()
+		case ev := <-lr.events:
+			switch ev.Type {
+			case termbox.EventMouse:
+				cursorPos := currentPos.Add(ev.MouseX, ev.MouseY)
+				switch {
+				case ev.Key == termbox.MouseLeft:
+					if (lr.squad >= 0 &&
+						geom.CheckCellCoordBounds(cursorPos, geom.CellCoord{X: 0, Y: 0}, geom.CellCoord{X: 1024, Y: 1024}) &&
+						field.CellAt(cursorPos).Passable) {
+						sendOrder(lr.Orders, engine.Order{Order: engine.ORDER_MOVE, Coord: cursorPos})
+						sv.movingTo = cursorPos
+					}
+				case ev.Key == termbox.MouseRight:
+					if lr.squad >= 0 {
+						sendOrder(lr.Orders, engine.Order{Order: engine.ORDER_GREN, Coord: cursorPos})
+						sv.GrenTo = cursorPos
+					}
+				}
+
+			case termbox.EventKey:
+				if lr.input != nil {
+					lr.handleInput(ev)
+					draw()
+					break
+				}
+				switch {
+				// direct moving window
+				case ev.Key == termbox.KeyArrowLeft:
+					fallthrough
+				case ev.Ch == 'a':
+					currentPos = currentPos.Add(-TUI_POS_STEP, 0)
+
+				case ev.Key == termbox.KeyArrowRight:
+					fallthrough
+				case ev.Ch == 'd':
+					currentPos = currentPos.Add(TUI_POS_STEP, 0)
+
+				case ev.Key == termbox.KeyArrowDown:
+					fallthrough
+				case ev.Ch == 's':
+					currentPos = currentPos.Add(0, TUI_POS_STEP)
+
+				case ev.Key == termbox.KeyArrowUp:
+					fallthrough
+				case ev.Ch == 'w':
+					currentPos = currentPos.Add(0, -TUI_POS_STEP)
+
+				case ev.Ch == 'f':
+					fallthrough
+				case ev.Ch == 'F':
+					if lr.squad >= 0 {
+						sv.FireState = engine.ToggleFireState(sv.FireState)
+						sendOrder(lr.Orders, engine.Order{Order: sv.FireState, Coord: geom.CellCoord{X: 0, Y: 0}})
+					}
+
+				case ev.Ch == 'p':
+					fallthrough
+				case ev.Ch == 'P':
+					if lr.squad >= 0 {
+						sendOrder(lr.Orders, engine.Order{Order: engine.ORDER_AUTOMOVE, Coord: geom.CellCoord{X: 0, Y: 0}})
+						sv.Automove = true
+					}
+
+				case ev.Ch == 'l':
+					fallthrough
+				case ev.Ch == 'L':
+					lr.showLeaders = !lr.showLeaders
+
+				// chat and message log
+				case ev.Key == termbox.KeyEnter:
+					lr.input = &chatInput{}
+				case ev.Ch == 't':
+					fallthrough
+				case ev.Ch == 'T':
+					lr.input = &chatInput{team: true}
+				case ev.Ch == 'm':
+					fallthrough
+				case ev.Ch == 'M':
+					lr.showLog = !lr.showLog
+				case ev.Key == termbox.KeyPgup:
+					lr.msgLog.scrollBy(TUI_MSGLOG_LINES)
+				case ev.Key == termbox.KeyPgdn:
+					lr.msgLog.scrollBy(-TUI_MSGLOG_LINES)
+
+				// pace of the game, in multiplayer these are votes
+				case ev.Key == termbox.KeySpace:
+					if lr.pace == "paused" {
+						lr.sendCommand("/resume")
+					} else {
+						lr.sendCommand("/pause")
+					}
+				case ev.Ch == '.':
+					lr.sendCommand("/step")
+				case ev.Ch == '-':
+					lr.sendCommand("/speed slower")
+				case ev.Ch == '+' || ev.Ch == '=':
+					lr.sendCommand("/speed faster")
+
+				// quit
+				case ev.Key == termbox.KeyF10:
+					return
+				}
+				draw()
+			case termbox.EventResize:
+				draw()
+			}
+		}
+		lr.msgLog.tick()
+	}
+}
+
+// render field chunk that we currently looking at
+func (lr *LocalRender) drawField(f *engine.Field, pos geom.CellCoord, sv squadView, gameState engine.GameState,
+	rulesMsg string) {
+	// 2 lines are reserved for messages and status bars
+	upperBound := tb2cell().Add(-1, -3).AddCoord(pos)
+
+	termbox.Clear(TUI_DEFAULT_FG, TUI_DEFAULT_BG)
+
+	var fieldZero = geom.CellCoord{X: 0, Y: 0}
+	var fieldMax = geom.CellCoord{X: f.XSize - 1, Y: f.YSize - 1}
+
+	// with fog on we see only through eyes of soldiers
+	var eyes []geom.UnitCoord
+	if f.Fog {
+		eyes = engine.ViewersFor(f, lr.squad, false)
+		if len(lr.fog.seen) != len(f.Cells) {
+			lr.fog.reset(f)
+		}
+	}
+
+	// render walls
+	for i := pos.X; i <= upperBound.X; i++ {
+		for j := pos.Y; j <= upperBound.Y; j++ {
+			tileCell := geom.CellCoord{X: i, Y: j}
+			screenPos := tileCell.AddCoord(pos.Mult(-1))
+			if !geom.CheckCellCoordBounds(tileCell, fieldZero, fieldMax) {
+				termbox.SetCell(screenPos.X, screenPos.Y, TUI_OFFSCREEN_CHAR,
+					TUI_DEFAULT_FG, TUI_DEFAULT_BG)
+				continue
+			}
+
+			fg := TUI_DEFAULT_FG
+			cell := f.CellAt(tileCell)
+			flatChar := TUI_FLAT_CHAR
+			if len(eyes) > 0 {
+				if f.CanSee(eyes, tileCell.UnitCenter()) {
+					lr.fog.see(tileCell)
+				} else if lr.fog.wasSeen(tileCell) {
+					fg = TUI_FOG_FG
+					flatChar = TUI_FOG_FLAT_CHAR
+				} else {
+					termbox.SetCell(screenPos.X, screenPos.Y, TUI_UNKNOWN_CHAR,
+						TUI_DEFAULT_FG, TUI_DEFAULT_BG)
+					continue
+				}
+			}
+
+			switch cell.Type {
+			case engine.OBJECT_EMPTY:
+				termbox.SetCell(screenPos.X, screenPos.Y, flatChar, fg, TUI_DEFAULT_BG)
+			case engine.OBJECT_WALL:
+				termbox.SetCell(screenPos.X, screenPos.Y, TUI_WALL_CHAR, fg, TUI_DEFAULT_BG)
+			case engine.OBJECT_BUSH:
+				termbox.SetCell(screenPos.X, screenPos.Y, TUI_BUSH_CHAR, fg, TUI_DEFAULT_BG)
+			case engine.OBJECT_BARRICADE:
+				termbox.SetCell(screenPos.X, screenPos.Y, TUI_BARR_CHAR, fg, TUI_DEFAULT_BG)
+			}
+		}
+	}
+
+	// render units
+	for _, up := range f.Units {
+		if up.Unit == nil {
+			// hidden by fog
+			continue
+		}
+		unitCell := up.Coord.Cell()
+		if !geom.CheckCellCoordBounds(unitCell, pos, upperBound) {
+			// unit is not visible
+			continue
+		}
+		ch, fg, bg := getUnitView(f, lr.squad, up.Unit)
+		screenPos := unitCell.AddCoord(pos.Mult(-1))
+
+		termbox.SetCell(screenPos.X, screenPos.Y, ch, fg, bg)
+	}
+
+	// render squad state
+	if (sv.movingTo != geom.CellCoord{X: 0, Y: 0}) && geom.CheckCellCoordBounds(sv.movingTo, pos, upperBound) {
+		screenPos := sv.movingTo.AddCoord(pos.Mult(-1))
+		termbox.SetCell(screenPos.X, screenPos.Y, TUI_MOVE_TARGET_CHAR,
+			TUI_MOVE_TARGET_FG, TUI_DEFAULT_BG)
+	}
+
+	// render grens
+	for _, gren := range f.Grens {
+		if gren.Booming == 0 {
+			// flying gren
+			if geom.CheckCellCoordBounds(gren.From.Cell(), pos, upperBound) {
+				screenPos := gren.From.Cell().AddCoord(pos.Mult(-1))
+				termbox.SetCell(screenPos.X, screenPos.Y, TUI_FLYING_GREN_TARGET_CHAR,
+					TUI_FLYING_GREN_TARGET_FG, TUI_DEFAULT_BG)
+			}
+		} else {
+			// explosion
+			center := gren.To
+			for i := -engine.SOL_GREN_RADIUS; i <= engine.SOL_GREN_RADIUS; i++ {
+				for j := -engine.SOL_GREN_RADIUS; j <= engine.SOL_GREN_RADIUS; j++ {
+					cellCoord := center.Cell().Add(i, j)
+					screenPos := cellCoord.AddCoord(pos.Mult(-1))
+					if geom.CheckCellCoordBounds(cellCoord, pos, upperBound) &&
+						center.Distance(cellCoord.UnitCenter()) < engine.SOL_GREN_RADIUS &&
+						f.HaveDirectPath(center, cellCoord.UnitCenter()) {
+						// in a range and visible
+						boomingView := boomingColors[gren.Booming]
+						termbox.SetCell(screenPos.X, screenPos.Y,
+							boomingView.ch, boomingView.fg, boomingView.bg)
+					}
+				}
+			}
+		}
+	}
+
+	if (sv.GrenTo != geom.CellCoord{X: 0, Y: 0}) && geom.CheckCellCoordBounds(sv.GrenTo, pos, upperBound) {
+		screenPos := sv.GrenTo.AddCoord(pos.Mult(-1))
+		termbox.SetCell(screenPos.X, screenPos.Y, TUI_GREN_TARGET_CHAR,
+			TUI_GREN_TARGET_FG, TUI_DEFAULT_BG)
+	}
+	// render pathfind
+	// FIXME(pathfind)
+	if p := f.Pathfinder; p != nil {
+		for Coord, cell := range p.Cells {
+			break
+			if !geom.CheckCellCoordBounds(Coord, pos, upperBound) {
+				continue
+			}
+			screenPos := Coord.AddCoord(pos.Mult(-1))
+			switch {
+			case cell.Closed:
+				termbox.SetCell(screenPos.X, screenPos.Y, TUI_FLAT_CHAR,
+					TUI_DEFAULT_FG, TUI_PATHFIND_CLOSED_BG)
+			case cell.Open:
+				termbox.SetCell(screenPos.X, screenPos.Y, TUI_FLAT_CHAR,
+					TUI_DEFAULT_FG, TUI_PATHFIND_OPEN_BG)
+			}
+		}
+		for _, Coord := range p.Path {
+			break
+			if !geom.CheckCellCoordBounds(Coord, pos, upperBound) {
+				continue
+			}
+			screenPos := Coord.AddCoord(pos.Mult(-1))
+			termbox.SetCell(screenPos.X, screenPos.Y, TUI_FLAT_CHAR,
+				TUI_DEFAULT_FG, TUI_PATHFIND_PATH_BG)
+		}
+	}
+
+	// render status and message bars
+	var statusPos int
+	yPos := tb2cell().Y - 1
+	if lr.squad >= 0 {
+		var FireState = "[ %s ]"
+		switch sv.FireState {
+		case engine.ORDER_FIRE:
+			FireState = fmt.Sprintf(FireState, "STAY_FIRE")
+		case engine.ORDER_SEMIFIRE:
+			FireState = fmt.Sprintf(FireState, "RUN_FIRE")
+		case engine.ORDER_NOFIRE:
+			FireState = fmt.Sprintf(FireState, "NO_FIRE")
+		}
+		statusPos = writeTermString(FireState, TUI_STATUS_FIRE_FG, TUI_DEFAULT_BG,
+			statusPos, yPos)
+
+		if morale, ok := squadMorale(f, lr.squad); ok {
+			moraleFg := TUI_MORALE_GOOD_FG
+			switch {
+			case morale < engine.SOL_MORALE_PANIC:
+				moraleFg = TUI_MORALE_PANIC_FG
+			case morale < engine.SOL_MORALE_SHAKEN:
+				moraleFg = TUI_MORALE_SHAKEN_FG
+			}
+			statusPos = writeTermString(fmt.Sprintf("Morale: %d%%", int(morale)), moraleFg,
+				TUI_DEFAULT_BG, statusPos+1, yPos)
+		}
+	} else {
+		// spectator mode
+		statusPos = writeTermString("[spectator] ", TUI_STATUS_INFO_FG, TUI_DEFAULT_BG,
+			statusPos, yPos)
+	}
+
+	// render chat input and message log above the status bar
+	logPos := yPos - 1
+	if lr.input != nil {
+		prompt := "say: "
+		if lr.input.team {
+			prompt = "team: "
+		}
+		writeTermString(prompt+string(lr.input.text)+"_", TUI_CHAT_INPUT_FG, TUI_DEFAULT_BG,
+			0, logPos)
+		logPos--
+	}
+	lines := lr.msgLog.visible(TUI_MSGLOG_LINES, lr.showLog || lr.input != nil)
+	for idx := len(lines) - 1; idx >= 0; idx-- {
+		fg := TUI_STATUS_INFO_FG
+		if lines[idx].Level == netproto.MESSAGE_LEVEL_CHAT {
+			fg = TUI_CHAT_FG
+		}
+		writeTermString(lines[idx].Content, fg, TUI_DEFAULT_BG, 0, logPos)
+		logPos--
+	}
+	if lr.msgLog.scrolled() {
+		writeTermString(fmt.Sprintf("[-%d]", lr.msgLog.scroll), TUI_STATUS_INFO_FG,
+			TUI_DEFAULT_BG, 0, logPos)
+	}
+
+	// count Zs and Bs and show that count in status
+	var Zs, Bs int
+	for _, up := range f.Units {
+		// units hidden by fog are not counted
+		switch up.Unit.(type) {
+		case *engine.Zed:
+			Zs++
+		case *engine.Damsel:
+			Bs++
+		}
+	}
+
+	statusPos = writeTermString(fmt.Sprintf("Zs: %d", Zs), TUI_ZED_FG, TUI_DEFAULT_BG,
+		statusPos+1, yPos)
+	statusPos = writeTermString(fmt.Sprintf("Bs: %d", Bs), TUI_DAMSEL_FG, TUI_DEFAULT_BG,
+		statusPos+1, yPos)
+
+	statusPos = writeTermString(rulesMsg, TUI_STATUS_INFO_FG, TUI_DEFAULT_BG,
+		statusPos+1, yPos)
+	if lr.pace != "" {
+		paceFg := TUI_STATUS_INFO_FG
+		if lr.pace == "paused" {
+			paceFg = TUI_STATUS_PAUSED_FG
+		}
+		statusPos = writeTermString("["+lr.pace+"]", paceFg, TUI_DEFAULT_BG, statusPos+1, yPos)
+	}
+
+	// render gameover block if nesessary
+	var banner string
+	switch {
+	case gameState.State&engine.GAME_WIN > 0:
+		banner = "YOU WIN"
+	case gameState.State&engine.GAME_LOSE > 0:
+		banner = "YOU LOSE"
+	case gameState.State&engine.GAME_DRAW > 0:
+		banner = "DRAW"
+	}
+	if gameState.State&engine.GAME_OVER > 0 {
+		if banner == "" {
+			banner = "GAME OVER"
+		} else {
+			banner += " | GAME OVER"
+		}
+	}
+	if banner != "" {
+		writeBanner(banner)
+	}
+	if gameState.State&engine.GAME_OVER > 0 && lr.scoreboard != nil {
+		writeScoreboard(lr.scoreboard, lr.squad)
+	}
+	if lr.showLeaders {
+		writeLeaderboard(lr.leaderboard)
+	}
+	termbox.Flush()
+}
+
+func getUnitView(f *engine.Field, pid int, u engine.Unit) (ch rune, fg, bg termbox.Attribute) {
+	switch u.(type) {
+	case *engine.Soldier:
+		var solColor termbox.Attribute = TUI_ANOTHER_SOLDIER_FG
+		s := u.(*engine.Soldier)
+		agent := f.AgentForUnitID(s.Id)
+
+		if squad, ok := agent.(*engine.Squad); ok {
+			if squad.Pid == pid {
+				solColor = TUI_SOLDIER_FG
+				if s.IsPanicking() {
+					solColor = TUI_PANIC_SOLDIER_FG
+				}
+			}
+		}
+		return TUI_SOLDIER_CHAR, solColor, TUI_DEFAULT_BG
+	case *engine.Damsel:
+		dam := u.(*engine.Damsel)
+		if dam.Adrenaline > 0 {
+			return TUI_FASTDAMSEL_CHAR, TUI_FASTDAMSEL_FG, TUI_DEFAULT_BG
+		} else {
+			return TUI_DAMSEL_CHAR, TUI_DAMSEL_FG, TUI_DEFAULT_BG
+		}
+	case *engine.Zed:
+		zed := u.(*engine.Zed)
+		if zed.Nutrition > engine.ZED_NUTRITION_FULL {
+			return TUI_FASTZED_CHAR, TUI_FASTZED_FG, TUI_DEFAULT_BG
+		} else {
+			return TUI_ZED_CHAR, TUI_ZED_FG, TUI_DEFAULT_BG
+		}
+	case *engine.Corpse:
+		corpse := u.(*engine.Corpse)
+		ch, _, _ := getUnitView(f, pid, corpse.Unit)
+		return ch, TUI_CORPSE_FG, TUI_CORPSE_BG
+	}
+	return ' ', TUI_DEFAULT_FG, TUI_DEFAULT_BG
+}
+
+// squadMorale returns average morale of player's squad
+func squadMorale(f *engine.Field, pid int) (float32, bool) {
+	for _, a := range f.Agents {
+		if squad, ok := a.(*engine.Squad); ok && squad.Pid == pid {
+			if len(squad.Units) == 0 {
+				return 0, false
+			}
+			var total float32
+			for _, sol := range squad.Units {
+				total += sol.Morale
+			}
+			return total / float32(len(squad.Units)), true
+		}
+	}
+	return 0, false
+}
+
+func writeTermString(str string, fg, bg termbox.Attribute, startX, startY int) (newPos int) {
+	for _, r := range str {
+		termbox.SetCell(startX, startY, r, fg, bg)
+		startX++
+	}
+	return startX
+}
+
+func writeBanner(str string) {
+	strlen := len(str)
+	totalLen := strlen + 4
+
+	size := tb2cell()
+	xs := (size.X - totalLen) / 2
+	ys := size.Y/2 - 1
+
+	msg := fmt.Sprintf("* %s *", str)
+	line := strings.Repeat("*", totalLen)
+	writeTermString(line, TUI_DEFAULT_FG, TUI_DEFAULT_BG, xs, ys)
+	writeTermString(msg, TUI_DEFAULT_FG, TUI_DEFAULT_BG, xs, ys+1)
+	writeTermString(line, TUI_DEFAULT_FG, TUI_DEFAULT_BG, xs, ys+2)
+}
+
+// writeScoreboard draws scoreboard table right below the banner
+func writeScoreboard(sb *netproto.Scoreboard, pid int) {
+	lines := sb.Lines()
+	size := tb2cell()
+	ys := size.Y/2 + 3
+	for idx, line := range lines {
+		fg := TUI_DEFAULT_FG
+		if idx == 0 || (pid >= 0 && sb.Players[idx-1].Pid == pid) {
+			fg = TUI_STATUS_INFO_FG
+		}
+		xs := (size.X - len(line)) / 2
+		writeTermString(line, fg, TUI_DEFAULT_BG, xs, ys+idx)
+	}
+}
+
+// writeLeaderboard draws leaderboard table at the top of the screen
+func writeLeaderboard(lb *netproto.Leaderboard) {
+	var lines = []string{"LEADERBOARD", ""}
+	if lb == nil || len(lb.Entries) == 0 {
+		lines = append(lines, "no records yet")
+	} else {
+		lines = append(lines, lb.Lines()...)
+	}
+
+	size := tb2cell()
+	for idx, line := range lines {
+		xs := (size.X - len(line)) / 2
+		writeTermString(line, TUI_STATUS_INFO_FG, TUI_DEFAULT_BG, xs, 2+idx)
+	}
+}
+
+func pollEvents(events chan termbox.Event) {
+	for {
+		events <- termbox.PollEvent()
+	}
+}
+
+func tb2cell() geom.CellCoord {
+	x, y := termbox.Size()
+	return geom.CellCoord{X: x, Y: y}
+}
+
+func handleCursorMove(size, pos, cursor geom.CellCoord) geom.CellCoord {
+	low := pos.Add(TUI_CURSOR_MARGIN, TUI_CURSOR_MARGIN)
+	high := pos.AddCoord(size).Add(-TUI_CURSOR_MARGIN, -TUI_CURSOR_MARGIN)
+	if !geom.CheckCellCoordBounds(cursor, low, high) {
+		// cursor is too close to border, move window
+		switch {
+		case cursor.X < low.X:
+			pos.X -= TUI_POS_STEP
+		case cursor.X > high.X:
+			pos.X += TUI_POS_STEP
+		case cursor.Y < low.Y:
+			pos.Y -= TUI_POS_STEP
+		case cursor.Y > high.Y:
+			pos.Y += TUI_POS_STEP
+		}
+	}
+	return pos
+}
+
+func sendOrder(Orders chan engine.Order, o engine.Order) {
+	select {
+	case Orders <- o:
+	default:
+	}
+}
+
+type squadView struct {
+	FireState int
+	movingTo  geom.CellCoord
+	GrenTo    geom.CellCoord
+	Automove  bool
+}